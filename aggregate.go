@@ -0,0 +1,125 @@
+package slsh
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 聚合后的日志中保存重复次数与首末时间 (RFC3339) 的字段, 仅在重复次数大于 1 时附加
+const (
+	AggregateCountKey = "count"
+	AggregateFirstKey = "first_time"
+	AggregateLastKey  = "last_time"
+)
+
+// 默认的调用栈字段, 同一错误从不同位置抛出时分别聚合
+const DefaultStackKey = "stack"
+
+// Aggregator 在每个发送批次内将指纹 (Keys 字段值) 相同的错误日志合并为一条, 保留首条日志的完整内容,
+// 附加重复次数与首末时间, 用于在崩溃循环等场景下减少重复的错误日志
+type Aggregator struct {
+	Keys     []string       // 组成指纹的字段, 可选, 默认为 MessageKey, "error" 与 StackKey, 均缺失的日志不参与聚合
+	StackKey string         // 调用栈字段, 可选, 默认为 "stack", 仅在 Keys 为空时使用
+	Levels   []logrus.Level // 参与聚合的日志级别, 可选, 默认为 Error 及以上
+}
+
+type aggregator struct {
+	keys     []string
+	levelKey string
+	levels   map[string]bool // 参与聚合的 LevelKey 字段值
+}
+
+func newAggregator(a *Aggregator, messageKey, levelKey string, mapping LevelMapping) *aggregator {
+	keys := a.Keys
+	if len(keys) == 0 {
+		stackKey := a.StackKey
+		if stackKey == "" {
+			stackKey = DefaultStackKey
+		}
+		keys = []string{messageKey, logrus.ErrorKey, stackKey}
+	}
+	levels := a.Levels
+	if len(levels) == 0 {
+		levels = []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+	}
+
+	agg := &aggregator{keys: keys, levelKey: levelKey, levels: make(map[string]bool, len(levels))}
+	for _, level := range levels {
+		agg.levels[strconv.Itoa(mapping(level))] = true
+	}
+	return agg
+}
+
+// fingerprint 返回日志的聚合指纹, 不参与聚合时返回 false
+func (a *aggregator) fingerprint(m Message) (string, bool) {
+	if !a.levels[m.Contents[a.levelKey]] {
+		return "", false
+	}
+
+	// 不同主题或来源的日志分别聚合
+	var b strings.Builder
+	b.WriteString(strconv.Quote(m.Topic))
+	b.WriteString(strconv.Quote(m.Source))
+	found := false
+	for _, k := range a.keys {
+		v, ok := m.Contents[k]
+		found = found || ok
+		b.WriteString(strconv.Quote(v))
+	}
+	return b.String(), found
+}
+
+// aggregate 合并指纹相同的日志, 保持首次出现的顺序, 不修改原日志
+func (a *aggregator) aggregate(messages []Message) []Message {
+	type group struct {
+		index       int
+		count       int
+		first, last time.Time
+	}
+
+	out := make([]Message, 0, len(messages))
+	groups := make(map[string]*group)
+	for _, m := range messages {
+		fp, ok := a.fingerprint(m)
+		if !ok {
+			out = append(out, m)
+			continue
+		}
+		if g, ok := groups[fp]; ok {
+			g.count++
+			if m.Time.Before(g.first) {
+				g.first = m.Time
+			}
+			if m.Time.After(g.last) {
+				g.last = m.Time
+			}
+			continue
+		}
+		groups[fp] = &group{index: len(out), count: 1, first: m.Time, last: m.Time}
+		out = append(out, m)
+	}
+	if len(out) == len(messages) {
+		return messages
+	}
+
+	for _, g := range groups {
+		if g.count == 1 {
+			continue
+		}
+		out[g.index] = withContents(out[g.index], map[string]string{
+			AggregateCountKey: strconv.Itoa(g.count),
+			AggregateFirstKey: g.first.Format(time.RFC3339Nano),
+			AggregateLastKey:  g.last.Format(time.RFC3339Nano),
+		})
+	}
+	return out
+}
+
+func (a *aggregator) wrap(flush func(...Message) error) func(...Message) error {
+	return func(messages ...Message) error {
+		return flush(a.aggregate(messages)...)
+	}
+}
@@ -0,0 +1,85 @@
+package slsh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregator(t *testing.T) {
+	agg := newAggregator(&Aggregator{}, DefaultMessageKey, DefaultLevelKey, SyslogLevelMapping)
+	errLevel := "3"
+	st := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newMessage := func(sec int, level, msg string) Message {
+		return Message{
+			Time:     st.Add(time.Duration(sec) * time.Second),
+			Contents: map[string]string{DefaultMessageKey: msg, DefaultLevelKey: level, "error": "EOF"},
+		}
+	}
+
+	t.Run("aggregate", func(t *testing.T) {
+		first := newMessage(1, errLevel, "boom")
+		messages := []Message{
+			first,
+			newMessage(0, errLevel, "other"),
+			newMessage(3, errLevel, "boom"),
+			newMessage(2, errLevel, "boom"),
+			newMessage(4, "6", "boom"),
+		}
+
+		out := agg.aggregate(messages)
+		if assert.Len(t, out, 3) {
+			assert.Equal(t, "boom", out[0].Contents[DefaultMessageKey])
+			assert.Equal(t, "3", out[0].Contents[AggregateCountKey])
+			assert.Equal(t, st.Add(time.Second).Format(time.RFC3339Nano), out[0].Contents[AggregateFirstKey])
+			assert.Equal(t, st.Add(3*time.Second).Format(time.RFC3339Nano), out[0].Contents[AggregateLastKey])
+			assert.Equal(t, first.Time, out[0].Time)
+
+			assert.NotContains(t, out[1].Contents, AggregateCountKey)
+			assert.Equal(t, "6", out[2].Contents[DefaultLevelKey])
+		}
+		assert.NotContains(t, first.Contents, AggregateCountKey)
+	})
+
+	t.Run("unique", func(t *testing.T) {
+		messages := []Message{newMessage(0, errLevel, "a"), newMessage(0, errLevel, "b")}
+		assert.Equal(t, messages, agg.aggregate(messages))
+	})
+
+	t.Run("stack", func(t *testing.T) {
+		a, b := newMessage(0, errLevel, "boom"), newMessage(0, errLevel, "boom")
+		a.Contents[DefaultStackKey] = "main.go:10"
+		b.Contents[DefaultStackKey] = "main.go:20"
+		assert.Len(t, agg.aggregate([]Message{a, b}), 2)
+
+		agg := newAggregator(&Aggregator{StackKey: "trace"}, DefaultMessageKey, DefaultLevelKey, SyslogLevelMapping)
+		a.Contents["trace"], b.Contents["trace"] = "x", "y"
+		assert.Len(t, agg.aggregate([]Message{a, b}), 2)
+		b.Contents["trace"] = "x"
+		assert.Len(t, agg.aggregate([]Message{a, b}), 1)
+	})
+
+	t.Run("missing keys", func(t *testing.T) {
+		m := Message{Contents: map[string]string{DefaultLevelKey: errLevel}}
+		assert.Len(t, agg.aggregate([]Message{m, m}), 2)
+	})
+
+	t.Run("levels", func(t *testing.T) {
+		agg := newAggregator(&Aggregator{Keys: []string{"error"}, Levels: []logrus.Level{logrus.WarnLevel}},
+			DefaultMessageKey, DefaultLevelKey, SyslogLevelMapping)
+		messages := []Message{newMessage(0, "4", "a"), newMessage(0, "4", "b"), newMessage(0, errLevel, "c")}
+		out := agg.aggregate(messages)
+		if assert.Len(t, out, 2) {
+			assert.Equal(t, "2", out[0].Contents[AggregateCountKey])
+		}
+	})
+
+	t.Run("wrap", func(t *testing.T) {
+		var flushed []Message
+		flush := agg.wrap(func(messages ...Message) error { flushed = messages; return nil })
+		assert.NoError(t, flush(newMessage(0, errLevel, "x"), newMessage(1, errLevel, "x")))
+		assert.Len(t, flushed, 1)
+	})
+}
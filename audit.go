@@ -0,0 +1,101 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultAuditAttempts = 3
+	DefaultAuditBackoff  = 100 * time.Millisecond
+	DefaultAuditTimeout  = 10 * time.Second
+)
+
+// 审计日志写入失败, 重试次数耗尽或遇到不可重试的错误
+type AuditError struct {
+	Attempts int
+	Err      error // 最后一次失败的原因
+}
+
+func (e *AuditError) Error() string {
+	return fmt.Sprintf("audit log not acknowledged after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *AuditError) Unwrap() error { return e.Err }
+
+// AuditWriter 同步写入审计日志: 不缓存, 不采样, 仅在阿里云日志确认接收后返回成功,
+// 失败时按指数退避重试, 重试耗尽后返回 *AuditError. 每次请求都受 ctx 约束, 服务端无响应时 ctx 结束即返回
+type AuditWriter struct {
+	writer      OptionsWriter
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func NewAuditWriter(writer OptionsWriter, maxAttempts int, backoff time.Duration) *AuditWriter {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultAuditAttempts
+	}
+	if backoff <= 0 {
+		backoff = DefaultAuditBackoff
+	}
+	return &AuditWriter{writer: writer, maxAttempts: maxAttempts, backoff: backoff}
+}
+
+func (a *AuditWriter) WriteMessage(messages ...Message) error {
+	return a.WriteMessageContext(context.Background(), messages...)
+}
+
+func (a *AuditWriter) WriteMessageContext(ctx context.Context, messages ...Message) error {
+	backoff := a.backoff
+	for attempt := 1; ; attempt++ {
+		err := a.writer.WriteMessageOptions(ctx, RequestOptions{}, messages...)
+		if err == nil {
+			return nil
+		}
+		if attempt >= a.maxAttempts || !retryable(err) {
+			return &AuditError{Attempts: attempt, Err: err}
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &AuditError{Attempts: attempt, Err: err}
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// retryable 判断错误是否值得重试: 除 TLS 外的网络错误, 限流 (含配额超限) 及服务端错误可重试, 其余客户端错误不重试
+func retryable(err error) bool {
+	var dErr *DeliveryError
+	if errors.As(err, &dErr) {
+		return dErr.Retryable()
+	}
+	var aErr *AliyunError
+	if errors.As(err, &aErr) {
+		return aErr.Throttled() || aErr.HTTPCode == http.StatusTooManyRequests || aErr.HTTPCode >= http.StatusInternalServerError
+	}
+	var hErr *HTTPError
+	if errors.As(err, &hErr) {
+		return hErr.StatusCode == http.StatusTooManyRequests || hErr.StatusCode >= http.StatusInternalServerError
+	}
+	var nErr net.Error
+	return errors.As(err, &nErr)
+}
+
+type syncService struct {
+	writer *AuditWriter
+}
+
+func (s syncService) Push(ctx context.Context, message Message) error {
+	return s.writer.WriteMessageContext(ctx, message)
+}
+
+func (s syncService) Start()                         {}
+func (s syncService) Stop(ctx context.Context) error { return nil }
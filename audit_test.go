@@ -0,0 +1,108 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditWriter(t *testing.T) {
+	newWriter := func(t *testing.T, statuses ...int) (*AuditWriter, *int32, func()) {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			n := atomic.AddInt32(&calls, 1)
+			status := statuses[len(statuses)-1]
+			if int(n) <= len(statuses) {
+				status = statuses[n-1]
+			}
+			w.WriteHeader(status)
+			if status >= http.StatusBadRequest {
+				_, _ = w.Write([]byte(`{"errorCode":"any","errorMessage":"any"}`))
+			}
+		}))
+		u, _ := url.Parse(srv.URL)
+		w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient)
+		return NewAuditWriter(w, 3, time.Millisecond), &calls, srv.Close
+	}
+
+	t.Run("retry until acknowledged", func(t *testing.T) {
+		w, calls, done := newWriter(t, http.StatusServiceUnavailable, http.StatusOK)
+		defer done()
+
+		assert.NoError(t, w.WriteMessage(ShortMessage))
+		assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		w, calls, done := newWriter(t, http.StatusInternalServerError)
+		defer done()
+
+		err := w.WriteMessage(ShortMessage)
+		var auditErr *AuditError
+		if assert.True(t, errors.As(err, &auditErr)) {
+			assert.Equal(t, 3, auditErr.Attempts)
+		}
+		var aErr *AliyunError
+		assert.True(t, errors.As(err, &aErr))
+		assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		w, calls, done := newWriter(t, http.StatusUnauthorized)
+		defer done()
+
+		err := w.WriteMessage(ShortMessage)
+		var auditErr *AuditError
+		if assert.True(t, errors.As(err, &auditErr)) {
+			assert.Equal(t, 1, auditErr.Attempts)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		w, _, done := newWriter(t, http.StatusServiceUnavailable)
+		defer done()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := w.WriteMessageContext(ctx, ShortMessage)
+		var auditErr *AuditError
+		if assert.True(t, errors.As(err, &auditErr)) {
+			assert.Equal(t, 1, auditErr.Attempts)
+		}
+	})
+	t.Run("server stalls", func(t *testing.T) {
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			select {
+			case <-release:
+			case <-req.Context().Done():
+			}
+		}))
+		defer srv.Close()
+		defer close(release)
+		u, _ := url.Parse(srv.URL)
+		w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient)
+
+		converter := &MockConverter{onMessage: func(entry *logrus.Entry) Message { return ShortMessage }}
+		service := syncService{writer: NewAuditWriter(w, 3, time.Millisecond)}
+		hook := NewCustom(50*time.Millisecond, DefaultVisibleLevels, converter, w, service)
+
+		start := time.Now()
+		err := hook.Fire(&logrus.Entry{Level: logrus.InfoLevel})
+		assert.True(t, time.Since(start) < time.Second, time.Since(start))
+		var auditErr *AuditError
+		if assert.True(t, errors.As(err, &auditErr), err) {
+			assert.Equal(t, 1, auditErr.Attempts)
+			assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package slsh
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 启动日志的字段前缀
+const BannerKeyPrefix = "slsh_"
+
+// banner 返回汇总生效配置的启动日志, 密钥只保留首尾字符
+func (c Config) banner() Message {
+	contents := map[string]string{
+		c.MessageKey:                    "aliyun log hook started",
+		c.LevelKey:                      strconv.Itoa(c.LevelMapping(logrus.InfoLevel)),
+		BannerKeyPrefix + "version":     Version,
+		BannerKeyPrefix + "endpoint":    c.uri.Scheme + "://" + c.Endpoint,
+		BannerKeyPrefix + "project":     c.Project,
+		BannerKeyPrefix + "store":       c.Store,
+		BannerKeyPrefix + "buffer_size": strconv.Itoa(c.BufferSize),
+		BannerKeyPrefix + "interval":    c.Interval.String(),
+		BannerKeyPrefix + "timeout":     c.Timeout.String(),
+		BannerKeyPrefix + "compression": strconv.Itoa(c.CompressionLevel),
+		BannerKeyPrefix + "max_flight":  strconv.Itoa(c.MaxInFlight),
+		BannerKeyPrefix + "attempts":    strconv.Itoa(c.MaxAttempts),
+		BannerKeyPrefix + "audit":       strconv.FormatBool(c.Audit),
+		BannerKeyPrefix + "spill":       strconv.FormatBool(c.SpillDir != ""),
+		BannerKeyPrefix + "credentials": fmt.Sprintf("%T", c.Credentials),
+	}
+	if c.Preset != "" {
+		contents[BannerKeyPrefix+"preset"] = string(c.Preset)
+	}
+	if c.MaxRetryElapsed > 0 {
+		contents[BannerKeyPrefix+"retry_elapsed"] = c.MaxRetryElapsed.String()
+	}
+	if c.AccessKey != "" {
+		contents[BannerKeyPrefix+"access_key"] = maskKey(c.AccessKey)
+	}
+	if len(c.VisibleLevels) > 0 {
+		levels := make([]string, len(c.VisibleLevels))
+		for i, level := range c.VisibleLevels {
+			levels[i] = level.String()
+		}
+		contents[BannerKeyPrefix+"levels"] = strings.Join(levels, ",")
+	}
+	return Message{Time: time.Now(), Contents: contents}
+}
+
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:4] + strings.Repeat("*", len(key)-8) + key[len(key)-4:]
+}
@@ -0,0 +1,35 @@
+package slsh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBanner(t *testing.T) {
+	c := Config{
+		Endpoint:     "cn-hangzhou.log.aliyuncs.com",
+		AccessKey:    "LTAI5tExampleKey1234",
+		AccessSecret: "very-secret",
+		Project:      "project",
+		Store:        "store",
+		Topic:        "topic",
+		HTTPS:        true,
+	}
+	if !assert.NoError(t, c.validate()) {
+		return
+	}
+
+	m := c.banner()
+	assert.Equal(t, Version, m.Contents[BannerKeyPrefix+"version"])
+	assert.Equal(t, "https://cn-hangzhou.log.aliyuncs.com", m.Contents[BannerKeyPrefix+"endpoint"])
+	assert.Equal(t, "100", m.Contents[BannerKeyPrefix+"buffer_size"])
+	assert.Equal(t, "LTAI************1234", m.Contents[BannerKeyPrefix+"access_key"])
+	assert.Equal(t, "panic,fatal,error,warning,info", m.Contents[BannerKeyPrefix+"levels"])
+	for _, v := range m.Contents {
+		assert.NotContains(t, v, "very-secret")
+		assert.NotContains(t, v, "Example")
+	}
+
+	assert.Equal(t, "****", maskKey("abcd"))
+}
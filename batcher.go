@@ -0,0 +1,74 @@
+package slsh
+
+import "time"
+
+// Batcher 决定缓存中的日志何时发送, 不含协程与通道, 由调用方按顺序驱动, 配合假时钟可在测试中确定地验证发送时机.
+// Service 的发送协程使用 Batcher 实现批量发送, 非并发安全
+type Batcher struct {
+	BufferSize int
+	Interval   time.Duration
+	MinGap     time.Duration // 两次发送的最小间隔, 非强制发送时生效
+	IdleFlush  bool          // 距上一条日志超过 Interval 时立即发送新日志
+	Clock      Clock
+	buffer     []Message
+	flushTime  time.Time
+	pushTime   time.Time
+	urgent     bool
+}
+
+func NewBatcher(bufferSize int, interval time.Duration, clock Clock) *Batcher {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &Batcher{
+		BufferSize: bufferSize,
+		Interval:   interval,
+		Clock:      clock,
+		buffer:     make([]Message, 0, bufferSize),
+		flushTime:  clock.Now(),
+	}
+}
+
+// Add 将日志加入缓存
+func (b *Batcher) Add(message Message) {
+	now := b.Clock.Now()
+	b.buffer = append(b.buffer, message)
+	if b.IdleFlush && now.Sub(b.pushTime) >= b.Interval {
+		b.urgent = true
+	}
+	b.pushTime = now
+}
+
+// Len 返回缓存中的日志条数
+func (b *Batcher) Len() int { return len(b.buffer) }
+
+// Ready 在需要发送时返回缓存中的日志, 否则返回 nil. force 为 true 时只要缓存非空即返回.
+// 返回的切片在调用 Done 之前有效, 发送结束 (无论成功与否) 后需调用 Done
+func (b *Batcher) Ready(force bool) []Message {
+	size := len(b.buffer)
+	if size == 0 {
+		return nil
+	}
+	if !force {
+		elapsed := b.Clock.Now().Sub(b.flushTime)
+		if !b.urgent && size < b.BufferSize && elapsed < b.Interval || elapsed < b.MinGap {
+			return nil
+		}
+	}
+	return b.buffer
+}
+
+// Done 清空缓存并记录发送完成时间
+func (b *Batcher) Done() {
+	b.flushTime = b.Clock.Now()
+	b.buffer = b.buffer[:0]
+	b.urgent = false
+}
+
+// Release 清空底层数组中已发送的部分, 使已发送的日志可以被回收
+func (b *Batcher) Release() {
+	unused := b.buffer[len(b.buffer):cap(b.buffer)]
+	for i := range unused {
+		unused[i] = Message{}
+	}
+}
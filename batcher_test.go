@@ -0,0 +1,29 @@
+package slsh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatcher(t *testing.T) {
+	t.Run("default clock", func(t *testing.T) {
+		b := NewBatcher(2, time.Hour, nil)
+		assert.Equal(t, SystemClock, b.Clock)
+
+		b.Add(Message{})
+		assert.Nil(t, b.Ready(false))
+		b.Add(Message{})
+		assert.Len(t, b.Ready(false), 2)
+	})
+
+	t.Run("release", func(t *testing.T) {
+		b := NewBatcher(2, time.Hour, nil)
+		b.Add(Message{Contents: map[string]string{"k": "v"}})
+		b.Done()
+
+		b.Release()
+		assert.Nil(t, b.buffer[:1][0].Contents)
+	})
+}
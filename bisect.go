@@ -0,0 +1,91 @@
+package slsh
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// RejectedError 表示单条日志被服务端拒绝, 由批次二分隔离得到, 该日志已被丢弃
+type RejectedError struct {
+	Message Message
+	Err     error
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("slsh: message rejected: %v", e.Err)
+}
+
+func (e *RejectedError) Unwrap() error { return e.Err }
+
+// partialError 表示批次的一部分已经发送, pending 为尚未发送的日志, 落盘时只保存这部分
+type partialError struct {
+	pending []Message
+	err     error
+}
+
+func (e *partialError) Error() string { return e.err.Error() }
+func (e *partialError) Unwrap() error { return e.err }
+
+// 可能由批次中个别日志引起的错误码: 内容无法解析, 日志时间超出范围, 批次过大.
+// 其他 HTTP 400 错误 (例如主题, 来源或压缩格式非法) 与批次整体有关, 拆分重发无法解决
+var rejectCodes = map[string]bool{
+	"PostBodyInvalid":  true,
+	"PostBodyTooLarge": true,
+	"InvalidTimestamp": true,
+}
+
+// rejected 判断错误是否可能由批次中的个别日志引起, 鉴权, 限流, 服务端错误与批次整体的错误不在此列.
+// 没有错误码的响应 (例如代理返回的 400) 无法判断原因, 不拆分
+func rejected(err error) bool {
+	var aErr *AliyunError
+	return errors.As(err, &aErr) && aErr.HTTPCode == http.StatusBadRequest && rejectCodes[aErr.Code]
+}
+
+// bisector 在批次因个别日志被拒绝时二分拆分重发, 隔离出被拒绝的单条日志并丢弃, 其余日志照常发送.
+// 其他错误原样返回, 批次按发送失败处理 (设置 SpillDir 时落盘)
+type bisector struct {
+	report    func(err error, count int)
+	lifecycle Lifecycle
+	metrics   Metrics
+}
+
+func (b *bisector) wrap(flush func(...Message) error) func(...Message) error {
+	return func(messages ...Message) error {
+		pending, err := b.bisect(flush, messages)
+		if err != nil && len(pending) < len(messages) {
+			return &partialError{pending: pending, err: err}
+		}
+		return err
+	}
+}
+
+// bisect 发送 messages, 返回未发送的日志 (被隔离丢弃的日志视为已处理), 遇到不可拆分的错误时停止
+func (b *bisector) bisect(flush func(...Message) error, messages []Message) ([]Message, error) {
+	err := flush(messages...)
+	if err == nil {
+		return nil, nil
+	}
+	var pErr *partialError
+	if errors.As(err, &pErr) {
+		messages = pErr.pending
+	}
+	if !rejected(err) {
+		return messages, err
+	}
+	if len(messages) == 1 {
+		b.metrics.Count(MetricRejected, 1)
+		b.lifecycle.drop(DropRejected, 1)
+		if b.report != nil {
+			b.report(&RejectedError{Message: messages[0], Err: err}, 1)
+		}
+		return nil, nil
+	}
+
+	mid := len(messages) / 2
+	pending, err := b.bisect(flush, messages[:mid])
+	if err != nil {
+		return append(append([]Message(nil), pending...), messages[mid:]...), err
+	}
+	return b.bisect(flush, messages[mid:])
+}
@@ -0,0 +1,150 @@
+package slsh
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBisect(t *testing.T) {
+	messages := make([]Message, 8)
+	for i := range messages {
+		messages[i] = Message{Contents: map[string]string{"n": strconv.Itoa(i)}}
+	}
+	messages[2].Contents["bad"] = "1"
+	messages[5].Contents["bad"] = "1"
+
+	var delivered []Message
+	unavailable := ""
+	flush := func(messages ...Message) error {
+		for _, m := range messages {
+			if m.Contents["n"] == unavailable {
+				return &AliyunError{HTTPCode: http.StatusServiceUnavailable, Code: "ServiceUnavailable"}
+			}
+			if m.Contents["bad"] != "" {
+				return &AliyunError{HTTPCode: http.StatusBadRequest, Code: "PostBodyInvalid"}
+			}
+		}
+		delivered = append(delivered, messages...)
+		return nil
+	}
+
+	t.Run("isolate", func(t *testing.T) {
+		delivered = nil
+		var reported []error
+		var drops int
+		metrics := &MockMetrics{}
+		b := &bisector{
+			report:    func(err error, count int) { reported = append(reported, err) },
+			lifecycle: Lifecycle{OnDrop: func(reason string, count int) { drops += count }},
+			metrics:   metrics,
+		}
+
+		assert.NoError(t, b.wrap(flush)(messages...))
+		assert.Len(t, delivered, 6)
+		assert.Equal(t, 2, drops)
+		assert.Equal(t, int64(2), metrics.counter(MetricRejected))
+		if assert.Len(t, reported, 2) {
+			var rErr *RejectedError
+			if assert.True(t, errors.As(reported[1], &rErr)) {
+				assert.Equal(t, "5", rErr.Message.Contents["n"])
+			}
+			var aErr *AliyunError
+			assert.True(t, errors.As(reported[0], &aErr))
+		}
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		delivered, unavailable = nil, "6"
+		defer func() { unavailable = "" }()
+
+		err := (&bisector{metrics: nopMetrics{}}).wrap(flush)(messages...)
+		var pErr *partialError
+		if assert.True(t, errors.As(err, &pErr)) {
+			assert.Equal(t, messages[6:], pErr.pending)
+		}
+		assert.False(t, rejected(err))
+		assert.Len(t, delivered, 4)
+	})
+
+	t.Run("spill", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "bisect")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+		s, err := newSpill(dir, nil, &MockMetrics{})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		delivered, unavailable = nil, "6"
+		wrapped := s.wrap((&bisector{metrics: nopMetrics{}}).wrap(flush))
+		assert.Error(t, wrapped(messages...))
+
+		delivered, unavailable = nil, ""
+		assert.NoError(t, wrapped())
+		if assert.Len(t, delivered, 2) {
+			assert.Equal(t, messages[6:], delivered)
+		}
+	})
+
+	t.Run("sharded", func(t *testing.T) {
+		// 分片写入时, 未包含问题日志的分片照常发送, 只对被拒绝的分片继续二分
+		delivered = nil
+		var reported int
+		err := (&bisector{metrics: nopMetrics{}, report: func(error, int) { reported++ }}).wrap(func(messages ...Message) error {
+			var bad []Message
+			for _, m := range messages {
+				if m.Contents["bad"] != "" {
+					bad = append(bad, m)
+				} else {
+					delivered = append(delivered, m)
+				}
+			}
+			if len(bad) == 0 {
+				return nil
+			}
+			err := &AliyunError{HTTPCode: http.StatusBadRequest, Code: "PostBodyInvalid"}
+			if len(bad) < len(messages) {
+				return &partialError{pending: bad, err: err}
+			}
+			return err
+		})(messages...)
+		assert.NoError(t, err)
+		assert.Len(t, delivered, 6)
+		assert.Equal(t, 2, reported)
+	})
+
+	t.Run("not rejected", func(t *testing.T) {
+		calls := 0
+		err := (&bisector{metrics: nopMetrics{}}).wrap(func(...Message) error {
+			calls++
+			return &AliyunError{HTTPCode: http.StatusUnauthorized, Code: "Unauthorized"}
+		})(messages...)
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+	t.Run("batch error", func(t *testing.T) {
+		// 与批次整体有关的错误不拆分, 原样返回使批次落盘
+		for _, err := range []error{
+			&AliyunError{HTTPCode: http.StatusBadRequest, Code: "InvalidTopic"},
+			&HTTPError{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"},
+		} {
+			calls := 0
+			var reported int
+			got := (&bisector{metrics: nopMetrics{}, report: func(error, int) { reported++ }}).wrap(func(...Message) error {
+				calls++
+				return err
+			})(messages...)
+			assert.Equal(t, err, got)
+			assert.Equal(t, 1, calls)
+			assert.Zero(t, reported)
+		}
+	})
+}
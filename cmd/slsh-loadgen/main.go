@@ -0,0 +1,198 @@
+// slsh-loadgen 以指定速率向模拟日志服务写入日志, 校验投递条数, 丢弃统计与内存上限, 用于发现性能回退.
+//
+//	go run ./cmd/slsh-loadgen -rate 50000 -duration 30s -max-heap 256
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+	"github.com/kyochou/go-logrus-aliyun-log-hook/slshooktest"
+)
+
+// 压测参数
+type options struct {
+	Rate        int           // 每秒写入的日志条数
+	Duration    time.Duration // 写入时长
+	Fields      int           // 每条日志的附加字段数
+	MessageSize int           // message 字段长度
+	ErrorRatio  float64       // Error 级别日志的比例
+	Preset      slsh.Preset
+	BufferSize  int
+	Interval    time.Duration
+	MinDelivery float64 // 最低投递比例, 取值 [0, 1]
+	MaxHeapMB   int     // 堆内存上限 (MB), 为 0 时不检查
+}
+
+// 压测结果
+type report struct {
+	Sent      int
+	Delivered int
+	Dropped   map[string]int
+	Elapsed   time.Duration
+	PeakHeap  uint64
+}
+
+func (r report) String() string {
+	drops := make([]string, 0, len(r.Dropped))
+	for reason, n := range r.Dropped {
+		drops = append(drops, fmt.Sprintf("%s=%d", reason, n))
+	}
+	return fmt.Sprintf("sent=%d delivered=%d dropped=[%s] rate=%.0f/s peak_heap=%dMB",
+		r.Sent, r.Delivered, strings.Join(drops, ","),
+		float64(r.Sent)/r.Elapsed.Seconds(), r.PeakHeap>>20)
+}
+
+// check 校验投递比例, 丢弃统计与内存上限
+func (r report) check(opts options) error {
+	dropped := 0
+	for _, n := range r.Dropped {
+		dropped += n
+	}
+	if r.Delivered+dropped != r.Sent {
+		return fmt.Errorf("accounting mismatch: delivered %d + dropped %d != sent %d", r.Delivered, dropped, r.Sent)
+	}
+	if ratio := float64(r.Delivered) / float64(r.Sent); ratio < opts.MinDelivery {
+		return fmt.Errorf("delivery ratio %.4f below %.4f", ratio, opts.MinDelivery)
+	}
+	if opts.MaxHeapMB > 0 && r.PeakHeap > uint64(opts.MaxHeapMB)<<20 {
+		return fmt.Errorf("peak heap %dMB exceeds %dMB", r.PeakHeap>>20, opts.MaxHeapMB)
+	}
+	return nil
+}
+
+func main() {
+	var opts options
+	preset := ""
+	flag.IntVar(&opts.Rate, "rate", 50000, "entries per second")
+	flag.DurationVar(&opts.Duration, "duration", 10*time.Second, "how long to generate load")
+	flag.IntVar(&opts.Fields, "fields", 5, "extra fields per entry")
+	flag.IntVar(&opts.MessageSize, "message-size", 100, "length of the message field")
+	flag.Float64Var(&opts.ErrorRatio, "error-ratio", 0.01, "fraction of entries logged at error level")
+	flag.StringVar(&preset, "preset", "", "config preset: low_latency, high_throughput or durable")
+	flag.IntVar(&opts.BufferSize, "buffer", 0, "Config.BufferSize, 0 for default")
+	flag.DurationVar(&opts.Interval, "interval", 0, "Config.Interval, 0 for default")
+	flag.Float64Var(&opts.MinDelivery, "min-delivery", 0.999, "minimum fraction of entries that must be delivered")
+	flag.IntVar(&opts.MaxHeapMB, "max-heap", 0, "heap ceiling in MB, 0 to disable")
+	flag.Parse()
+	opts.Preset = slsh.Preset(preset)
+
+	r, err := run(opts)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fmt.Println(r)
+	if err := r.check(opts); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "FAIL:", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options) (report, error) {
+	srv := slshooktest.NewServer(nil)
+	defer srv.Close()
+
+	var mu sync.Mutex
+	dropped := map[string]int{}
+	hook, err := slsh.New(slsh.Config{
+		Endpoint:     "loadgen.example.com",
+		AccessKey:    "loadgen",
+		AccessSecret: "loadgen",
+		Project:      "loadgen",
+		Store:        "loadgen",
+		Topic:        "loadgen",
+		HttpClient:   srv.Client(),
+		Preset:       opts.Preset,
+		BufferSize:   opts.BufferSize,
+		Interval:     opts.Interval,
+		Lifecycle: slsh.Lifecycle{
+			OnDrop: func(reason string, count int) {
+				mu.Lock()
+				dropped[reason] += count
+				mu.Unlock()
+			},
+		},
+	})
+	if err != nil {
+		return report{}, err
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	logger.SetLevel(logrus.InfoLevel)
+	logger.AddHook(hook)
+
+	chStop := make(chan struct{})
+	chPeak := make(chan uint64)
+	go func() { chPeak <- samplePeakHeap(chStop) }()
+
+	fields := make(logrus.Fields, opts.Fields)
+	for i := 0; i < opts.Fields; i++ {
+		fields[fmt.Sprintf("field%d", i)] = strings.Repeat("v", 16)
+	}
+	message := strings.Repeat("m", opts.MessageSize)
+	entry := logger.WithFields(fields)
+	errorEvery := 0
+	if opts.ErrorRatio > 0 {
+		errorEvery = int(1 / opts.ErrorRatio)
+	}
+
+	// 每毫秒补齐应写入的条数, 落后时追赶
+	st := time.Now()
+	sent := 0
+	for elapsed := time.Duration(0); elapsed < opts.Duration; elapsed = time.Since(st) {
+		for target := int(elapsed.Seconds() * float64(opts.Rate)); sent < target; sent++ {
+			if errorEvery > 0 && sent%errorEvery == 0 {
+				entry.Error(message)
+			} else {
+				entry.Info(message)
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	elapsed := time.Since(st)
+
+	if err := hook.Close(); err != nil {
+		return report{}, err
+	}
+	close(chStop)
+
+	mu.Lock()
+	defer mu.Unlock()
+	return report{
+		Sent:      sent,
+		Delivered: srv.Logs(),
+		Dropped:   dropped,
+		Elapsed:   elapsed,
+		PeakHeap:  <-chPeak,
+	}, nil
+}
+
+// samplePeakHeap 定期采样堆内存占用, chStop 关闭后返回峰值
+func samplePeakHeap(chStop <-chan struct{}) uint64 {
+	var peak uint64
+	var stats runtime.MemStats
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		runtime.ReadMemStats(&stats)
+		if stats.HeapInuse > peak {
+			peak = stats.HeapInuse
+		}
+		select {
+		case <-chStop:
+			return peak
+		case <-ticker.C:
+		}
+	}
+}
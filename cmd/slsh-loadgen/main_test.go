@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	opts := options{
+		Rate:        20000,
+		Duration:    500 * time.Millisecond,
+		Fields:      3,
+		MessageSize: 50,
+		ErrorRatio:  0.1,
+		Interval:    100 * time.Millisecond,
+		MinDelivery: 1,
+	}
+	if testing.Short() {
+		opts.Rate = 2000
+	}
+
+	r, err := run(opts)
+	if assert.NoError(t, err) {
+		assert.True(t, r.Sent > 0)
+		assert.NoError(t, r.check(opts), r.String())
+	}
+}
+
+func TestReportCheck(t *testing.T) {
+	opts := options{MinDelivery: 0.9, MaxHeapMB: 1}
+
+	assert.NoError(t, report{Sent: 10, Delivered: 9, Dropped: map[string]int{"timeout": 1}}.check(opts))
+	assert.Error(t, report{Sent: 10, Delivered: 9}.check(opts))
+	assert.Error(t, report{Sent: 10, Delivered: 8, Dropped: map[string]int{"timeout": 2}}.check(opts))
+	assert.Error(t, report{Sent: 10, Delivered: 10, PeakHeap: 2 << 20}.check(opts))
+}
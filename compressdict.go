@@ -0,0 +1,194 @@
+package slsh
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// 字典压缩协商默认使用的请求头: 服务端在响应中以 X-Slsh-Accept-Dict 声明已加载的字典 ID (逗号分隔),
+// 客户端使用字典压缩时以 X-Slsh-Compress-Dict 标注字典 ID. 这不是 SLS 协议的一部分, SLS 官方接入点不支持字典压缩,
+// 仅适用于自建的兼容网关等可按 ID 加载字典解压的接入点. 默认名称不匹配签名前缀, 不参与签名, 可通过 WithDictionaryHeaders 修改
+const (
+	DefaultAcceptDictHeader   = "X-Slsh-Accept-Dict"
+	DefaultCompressDictHeader = "X-Slsh-Compress-Dict"
+)
+
+// zstd 字典格式的魔数, 其后 4 字节为字典 ID, 不以魔数开头的视为原始内容 (raw content) 字典
+const zstdDictMagic = 0xEC30A437
+
+// Dictionary zstd 压缩字典
+type Dictionary struct {
+	ID   uint32
+	Data []byte
+}
+
+// NewDictionary 解析 zstd 字典: zstd --train 生成的字典读取其中的 ID, 原始内容字典 (例如 TrainDictionary 的结果)
+// 没有 ID, 使用内容的 CRC32, 使客户端与服务端对同一份内容得到相同的 ID
+func NewDictionary(data []byte) Dictionary {
+	if len(data) >= 8 && binary.LittleEndian.Uint32(data) == zstdDictMagic {
+		return Dictionary{ID: binary.LittleEndian.Uint32(data[4:]), Data: data}
+	}
+	return Dictionary{ID: crc32.ChecksumIEEE(data), Data: data}
+}
+
+// DictEncoder 使用字典的 zstd 压缩实现, 例如以 github.com/klauspost/compress/zstd 的 WithEncoderDict
+// 创建的 Encoder 调用 EncodeAll, 实现需并发安全
+type DictEncoder interface {
+	EncodeDict(src []byte, dict Dictionary) ([]byte, error)
+}
+
+type DictEncoderFunc func(src []byte, dict Dictionary) ([]byte, error)
+
+func (f DictEncoderFunc) EncodeDict(src []byte, dict Dictionary) ([]byte, error) { return f(src, dict) }
+
+// dictionary 记录字典与接入点是否支持该字典
+type dictionary struct {
+	dict     Dictionary
+	id       string
+	encoder  DictEncoder
+	accepted int32
+}
+
+// WithDictionary 在接入点声明支持该字典后使用字典压缩, 对重复度高的日志可显著减小载荷.
+// 初始按普通压缩发送, 每次响应根据 DefaultAcceptDictHeader 响应头更新是否支持, 因此只有支持字典的接入点会收到字典压缩的请求.
+// 服务端不再支持时 (例如切换到其他节点) 当次请求按普通错误处理, 之后的批次恢复普通压缩. EncodeBatch 不经过协商, 不使用字典
+func WithDictionary(dict Dictionary, encoder DictEncoder) WriterOption {
+	return func(w *writer) {
+		w.dict = &dictionary{dict: dict, id: strconv.FormatUint(uint64(dict.ID), 10), encoder: encoder}
+	}
+}
+
+// WithDictionaryHeaders 修改字典压缩协商使用的响应头与请求头名称, 为空时保持默认值.
+// 名称匹配签名前缀 (参考 WithSignHeaderPrefixes) 时请求头参与签名, 接入点需按相同规则校验
+func WithDictionaryHeaders(accept, compress string) WriterOption {
+	return func(w *writer) {
+		if accept != "" {
+			w.dictAccept = http.CanonicalHeaderKey(accept)
+		}
+		if compress != "" {
+			w.dictHeader = http.CanonicalHeaderKey(compress)
+		}
+	}
+}
+
+// usable 返回接入点是否已声明支持该字典
+func (d *dictionary) usable() bool {
+	return d != nil && atomic.LoadInt32(&d.accepted) == 1
+}
+
+// observe 根据响应头的值 (已加载的字典 ID 列表) 更新接入点是否支持该字典
+func (d *dictionary) observe(accept string) {
+	if d == nil {
+		return
+	}
+	accepted := int32(0)
+	for _, id := range strings.Split(accept, ",") {
+		if strings.TrimSpace(id) == d.id {
+			accepted = 1
+			break
+		}
+	}
+	atomic.StoreInt32(&d.accepted, accepted)
+}
+
+// 字典训练参数: 以 dictKmer 字节的片段统计出现频率, 每次选取 dictSegment 字节的片段加入字典
+const (
+	dictKmer    = 8
+	dictSegment = 64
+)
+
+// TrainDictionary 从样本日志训练不超过 size 字节的原始内容字典, 供 NewDictionary 使用.
+// 样本按发送时的 protobuf 格式编码, 将样本均分为若干段, 每段选取包含最多高频片段 (在多条样本中出现) 的一段内容,
+// 与 zstd 的 COVER 算法思路相同, 结果按得分升序排列, 使最常用的内容距离压缩数据最近.
+// 样本应覆盖常见的字段组合, 一般数百至数千条, 样本过少时字典可能过拟合
+func TrainDictionary(samples []Message, size int) []byte {
+	if size <= 0 || len(samples) == 0 {
+		return nil
+	}
+
+	// 拼接编码后的样本, 跨越样本边界的片段不参与统计
+	var data []byte
+	var ends []int
+	var keys []string
+	for _, sample := range samples {
+		data, keys = appendLog(data, keys, sample)
+		ends = append(ends, len(data))
+	}
+	if len(data) <= size {
+		return data
+	}
+
+	// 片段编号与出现在多少条样本中
+	ids := make([]int32, len(data))
+	index := make(map[string]int32)
+	var freq []int
+	var seen []int
+	start := 0
+	for n, end := range ends {
+		for i := start; i < end; i++ {
+			if i+dictKmer > end {
+				ids[i] = -1
+				continue
+			}
+			id, ok := index[string(data[i:i+dictKmer])]
+			if !ok {
+				id = int32(len(freq))
+				index[string(data[i:i+dictKmer])] = id
+				freq, seen = append(freq, 0), append(seen, -1)
+			}
+			if seen[id] != n {
+				seen[id] = n
+				freq[id]++
+			}
+			ids[i] = id
+		}
+		start = end
+	}
+
+	type segment struct {
+		start, score int
+	}
+	epochs := size / dictSegment
+	if epochs == 0 {
+		epochs = 1
+	}
+	epoch := len(data) / epochs
+	var segments []segment
+	for e := 0; e < epochs; e++ {
+		best := segment{score: -1}
+		from, to := e*epoch, (e+1)*epoch
+		for i := from; i+dictSegment <= to && i+dictSegment <= len(data); i++ {
+			score := 0
+			for j := i; j <= i+dictSegment-dictKmer; j++ {
+				if ids[j] >= 0 {
+					score += freq[ids[j]] - 1
+				}
+			}
+			if score > best.score {
+				best = segment{start: i, score: score}
+			}
+		}
+		if best.score <= 0 {
+			continue
+		}
+		// 已选取的片段不再计分, 避免字典中出现重复内容
+		for j := best.start; j <= best.start+dictSegment-dictKmer; j++ {
+			if ids[j] >= 0 {
+				freq[ids[j]] = 1
+			}
+		}
+		segments = append(segments, best)
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].score < segments[j].score })
+	dict := make([]byte, 0, size)
+	for _, s := range segments {
+		dict = append(dict, data[s.start:s.start+dictSegment]...)
+	}
+	return dict
+}
@@ -0,0 +1,129 @@
+package slsh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDictionary(t *testing.T) {
+	t.Run("id", func(t *testing.T) {
+		trained := make([]byte, 16)
+		binary.LittleEndian.PutUint32(trained, zstdDictMagic)
+		binary.LittleEndian.PutUint32(trained[4:], 42)
+		assert.Equal(t, uint32(42), NewDictionary(trained).ID)
+
+		raw := []byte("level=info message=request handled")
+		assert.Equal(t, NewDictionary(raw).ID, NewDictionary(append([]byte(nil), raw...)).ID)
+		assert.NotEqual(t, NewDictionary(raw).ID, NewDictionary(raw[1:]).ID)
+	})
+
+	t.Run("train", func(t *testing.T) {
+		samples := make([]Message, 500)
+		for i := range samples {
+			samples[i] = Message{Time: time.Unix(1577836800, 0), Contents: map[string]string{
+				"message": "request handled",
+				"path":    "/api/v1/users/" + strconv.Itoa(i%7),
+				"trace":   fmt.Sprintf("%016x", i*7919),
+			}}
+		}
+		dict := TrainDictionary(samples, 1024)
+		assert.True(t, len(dict) > 0 && len(dict) <= 1024, len(dict))
+		assert.True(t, bytes.Contains(dict, []byte("request handled")))
+		assert.True(t, bytes.Contains(dict, []byte("/api/v1/users/")))
+
+		assert.Nil(t, TrainDictionary(nil, 1024))
+		assert.Nil(t, TrainDictionary(samples, 0))
+		raw, _ := appendLog(nil, nil, samples[0])
+		assert.Equal(t, raw, TrainDictionary(samples[:1], 1024))
+	})
+}
+
+func TestWriterDictionary(t *testing.T) {
+	dict := NewDictionary([]byte("request handled"))
+	id := strconv.FormatUint(uint64(dict.ID), 10)
+	encoder := DictEncoderFunc(func(src []byte, d Dictionary) ([]byte, error) {
+		assert.Equal(t, dict, d)
+		return append([]byte("dict:"), src...), nil
+	})
+
+	var mu sync.Mutex
+	var requests []http.Header
+	accept := []string{"1," + id, ""}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		sig, err := signature(Secret("secret"), req)
+		assert.NoError(t, err)
+		assert.Equal(t, "LOG key:"+sig, req.Header.Get("Authorization"))
+		requests = append(requests, req.Header)
+		if len(accept) > 0 {
+			if accept[0] != "" {
+				w.Header().Set(DefaultAcceptDictHeader, accept[0])
+			}
+			accept = accept[1:]
+		}
+	}))
+	defer srv.Close()
+
+	uri, _ := url.Parse(srv.URL + "/logstores/store/shards/lb")
+	w := NewWriter(uri, "", "", "key", Secret("secret"), http.DefaultClient, WithDictionary(dict, encoder))
+	message := Message{Time: time.Now(), Contents: map[string]string{"message": "request handled"}}
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, w.WriteMessage(message))
+	}
+
+	if assert.Len(t, requests, 3) {
+		assert.Equal(t, CompressTypeLZ4, requests[0].Get("X-Log-Compresstype"))
+		assert.Empty(t, requests[0].Get(DefaultCompressDictHeader))
+		assert.Equal(t, CompressTypeZstd, requests[1].Get("X-Log-Compresstype"))
+		assert.Equal(t, id, requests[1].Get(DefaultCompressDictHeader))
+		assert.Equal(t, CompressTypeLZ4, requests[2].Get("X-Log-Compresstype"))
+		assert.Empty(t, requests[2].Get(DefaultCompressDictHeader))
+	}
+
+	t.Run("unsigned", func(t *testing.T) {
+		// 默认请求头不匹配签名前缀, 不参与签名
+		var signStr string
+		w := NewWriter(uri, "", "", "key", Secret("secret"), http.DefaultClient, WithDictionary(dict, encoder),
+			WithSignatureDebug(func(s string) { signStr = s }))
+		w.dict.observe(id)
+		req, err := w.buildRequest(newPayload([]byte("data"), 4, CompressTypeZstd, id), RequestOptions{}, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, id, req.Header.Get(DefaultCompressDictHeader))
+			assert.NotContains(t, strings.ToLower(signStr), "dict")
+		}
+	})
+
+	t.Run("custom headers", func(t *testing.T) {
+		w := NewWriter(uri, "", "", "key", Secret("secret"), http.DefaultClient, WithDictionary(dict, encoder),
+			WithDictionaryHeaders("X-Gateway-Dicts", "x-gateway-dict"))
+		w.dict.observe(id)
+		assert.True(t, w.dict.usable())
+		req, err := w.buildRequest(newPayload([]byte("data"), 4, CompressTypeZstd, id), RequestOptions{}, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, id, req.Header.Get("X-Gateway-Dict"))
+			assert.Empty(t, req.Header.Get(DefaultCompressDictHeader))
+		}
+	})
+
+	t.Run("payload", func(t *testing.T) {
+		// EncodeBatch 不经过协商, 不使用字典
+		p, err := EncodeBatch("", "", []Message{message}, WithDictionary(dict, encoder))
+		assert.NoError(t, err)
+		assert.Empty(t, p.Header().Get(DefaultCompressDictHeader))
+
+		p = Payload{Data: []byte("data"), RawSize: 4, CompressType: CompressTypeZstd, DictID: id}
+		assert.Equal(t, id, p.Header().Get(DefaultCompressDictHeader))
+	})
+}
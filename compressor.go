@@ -0,0 +1,41 @@
+package slsh
+
+import "github.com/kyochou/go-logrus-aliyun-log-hook/internal/lz4block"
+
+// lz4 压缩级别预设, 大于 0 时使用 HC 模式并作为搜索深度
+const (
+	CompressionFastest  = 0
+	CompressionBalanced = 16
+	CompressionBest     = 1 << 16
+)
+
+// 压缩类型, 对应请求头 x-log-compresstype
+const (
+	CompressTypeLZ4     = "lz4"
+	CompressTypeDeflate = "deflate"
+	CompressTypeZstd    = "zstd"
+)
+
+// 日志组压缩实现, 可替换为自定义编码 (例如硬件加速的 zstd), 实现需并发安全.
+// 返回压缩后的内容, 压缩前的长度与压缩类型 (CompressTypeXXX), Writer 据此设置请求头
+type Compressor interface {
+	Compress(src []byte) (dst []byte, rawSize int, contentType string, err error)
+}
+
+// LZ4Compressor 返回默认的 lz4 压缩实现, level 参考 CompressionXXX 预设
+func LZ4Compressor(level int) Compressor {
+	return lz4Compressor{level: level}
+}
+
+type lz4Compressor struct {
+	level int
+}
+
+func (c lz4Compressor) Compress(src []byte) ([]byte, int, string, error) {
+	dst, err := compressLZ4(src, c.level)
+	return dst, len(src), CompressTypeLZ4, err
+}
+
+func compressLZ4(data []byte, level int) ([]byte, error) {
+	return lz4block.Compress(data, level, !underMemoryPressure())
+}
@@ -0,0 +1,42 @@
+//go:build go1.18
+// +build go1.18
+
+package slsh
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/pierrec/lz4"
+)
+
+func FuzzCompressLZ4(f *testing.F) {
+	for _, raw := range compressSamples(rand.New(rand.NewSource(1))) {
+		f.Add(raw, false)
+		f.Add(raw, true)
+	}
+
+	f.Fuzz(func(t *testing.T, raw []byte, hc bool) {
+		if len(raw) == 0 {
+			return
+		}
+		level := CompressionFastest
+		if hc {
+			level = CompressionBalanced
+		}
+
+		data, err := compressLZ4(raw, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]byte, len(raw))
+		n, err := lz4.UncompressBlock(data, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out[:n], raw) {
+			t.Fatalf("round trip mismatch: got %d bytes, want %d", n, len(raw))
+		}
+	})
+}
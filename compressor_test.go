@@ -0,0 +1,78 @@
+package slsh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/lz4"
+	"github.com/stretchr/testify/assert"
+)
+
+// compressSamples 生成可压缩 (重复文本) 与不可压缩 (随机字节) 的测试数据
+func compressSamples(r *rand.Rand) [][]byte {
+	samples := [][]byte{[]byte("a"), []byte(strings.Repeat("request handled ", 1000))}
+	for _, size := range []int{14, 15, 270, 4096, 100 << 10} {
+		random := make([]byte, size)
+		r.Read(random)
+		samples = append(samples, random)
+
+		text := make([]byte, 0, size)
+		for len(text) < size {
+			text = append(text, fmt.Sprintf("path=/api/v1/users/%d status=200 ", r.Intn(50))...)
+		}
+		samples = append(samples, text[:size])
+	}
+	return samples
+}
+
+// lz4Frame 将单个压缩块封装为 lz4 frame (块独立, 最大 4MB, 无校验和)
+func lz4Frame(block []byte) []byte {
+	frame := []byte{0x04, 0x22, 0x4D, 0x18, 0x60, 0x70, 0x73}
+	frame = append(frame, make([]byte, 4)...)
+	binary.LittleEndian.PutUint32(frame[len(frame)-4:], uint32(len(block)))
+	frame = append(frame, block...)
+	return append(frame, 0, 0, 0, 0)
+}
+
+func TestCompressLZ4(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	samples := compressSamples(r)
+
+	for _, level := range []int{CompressionFastest, CompressionBalanced} {
+		c := LZ4Compressor(level)
+
+		t.Run(fmt.Sprintf("round trip %d", level), func(t *testing.T) {
+			for _, raw := range samples {
+				data, rawSize, compressType, err := c.Compress(raw)
+				if !assert.NoError(t, err) {
+					continue
+				}
+				assert.Equal(t, len(raw), rawSize)
+				assert.Equal(t, CompressTypeLZ4, compressType)
+				out := make([]byte, len(raw))
+				n, err := lz4.UncompressBlock(data, out)
+				if assert.NoError(t, err) {
+					assert.Equal(t, raw, out[:n])
+				}
+			}
+		})
+
+		t.Run(fmt.Sprintf("frame decoder %d", level), func(t *testing.T) {
+			for _, raw := range samples {
+				data, _, _, err := c.Compress(raw)
+				if !assert.NoError(t, err) {
+					continue
+				}
+				out, err := ioutil.ReadAll(lz4.NewReader(bytes.NewReader(lz4Frame(data))))
+				if assert.NoError(t, err) {
+					assert.Equal(t, raw, out)
+				}
+			}
+		})
+	}
+}
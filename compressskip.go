@@ -0,0 +1,86 @@
+package slsh
+
+import (
+	"unicode/utf8"
+)
+
+// 判断字段值是否已压缩的最小长度, 较短的值即使是 base64 也不影响整体压缩率
+const minOpaqueLen = 64
+
+// WithCompressionSkip 在字段值中疑似已压缩内容 (base64 编码, 二进制数据) 的字节占比达到 ratio 时,
+// 该批次不压缩直接发送, 节省无效压缩的 CPU, 可通过 MetricCompressionRatio 观察效果, 为 0 时总是压缩
+func WithCompressionSkip(ratio float64) WriterOption {
+	return func(w *writer) { w.skip = ratio }
+}
+
+// compressBatch 按 WithCompressionSkip 判断是否压缩 raw, 接入点支持时使用 WithDictionary 的字典,
+// 并上报压缩率指标, 返回的 dictID 为空表示未使用字典. raw 在返回后放回缓冲池, 返回的 data 不引用 raw
+func (w *writer) compressBatch(raw []byte, messages []Message) (data []byte, rawSize int, compressType, dictID string, err error) {
+	if w.skip > 0 && opaqueShare(messages) >= w.skip {
+		w.metrics.Count(MetricCompressionSkip, 1)
+		w.metrics.Observe(MetricCompressionRatio, 1)
+		// raw 来自缓冲池, 重试期间仍需使用请求内容, 不压缩时复制一份
+		return append([]byte(nil), raw...), len(raw), "", "", nil
+	}
+
+	if w.dict.usable() {
+		data, err = w.dict.encoder.EncodeDict(raw, w.dict.dict)
+		rawSize, compressType, dictID = len(raw), CompressTypeZstd, w.dict.id
+	} else {
+		data, rawSize, compressType, err = w.compressor.Compress(raw)
+	}
+	if err == nil && len(data) > 0 {
+		w.metrics.Observe(MetricCompressionRatio, float64(rawSize)/float64(len(data)))
+	}
+	return data, rawSize, compressType, dictID, err
+}
+
+// opaqueShare 返回疑似已压缩的字段值占全部字段值的字节比例
+func opaqueShare(messages []Message) float64 {
+	var total, opaqueBytes int
+	for _, message := range messages {
+		for _, value := range message.Contents {
+			total += len(value)
+			if opaque(value) {
+				opaqueBytes += len(value)
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(opaqueBytes) / float64(total)
+}
+
+// opaque 判断字段值是否像已压缩或编码的内容: 含控制字符或非法 UTF-8 的二进制数据,
+// 或同时含大小写字母与数字, 且只由 base64 字符组成的长字符串 (排除十六进制摘要与普通单词)
+func opaque(value string) bool {
+	if len(value) < minOpaqueLen {
+		return false
+	}
+
+	var upper, lower, digit, other, high bool
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= '0' && c <= '9':
+			digit = true
+		case c == '+' || c == '/' || c == '=' || c == '-' || c == '_':
+		case c == '\t' || c == '\n' || c == '\r':
+			other = true
+		case c < 0x20 || c == 0x7F:
+			return true
+		case c >= 0x80:
+			high, other = true, true
+		default:
+			other = true
+		}
+	}
+	if high && !utf8.ValidString(value) {
+		return true
+	}
+	return !other && upper && lower && digit
+}
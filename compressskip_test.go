@@ -0,0 +1,78 @@
+package slsh
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpaque(t *testing.T) {
+	blob := testBlob()
+	assert.True(t, opaque(blob))
+	assert.True(t, opaque(strings.Repeat("\x00\x01", 40)))
+	assert.True(t, opaque(strings.Repeat("\xff\xfe", 40)))
+
+	assert.False(t, opaque(blob[:minOpaqueLen-1]))
+	assert.False(t, opaque(strings.Repeat("0123456789abcdef", 4)))
+	assert.False(t, opaque(strings.Repeat("hello world, ", 8)))
+	assert.False(t, opaque(strings.Repeat("日志内容", 8)))
+}
+
+func TestCompressionSkip(t *testing.T) {
+	blob := testBlob()
+	messages := []Message{{Time: time.Unix(1577836800, 0), Contents: map[string]string{"msg": "upload", "body": blob}}}
+
+	t.Run("skip", func(t *testing.T) {
+		metrics := &MockMetrics{}
+		p, err := EncodeBatch("", "", messages, WithCompressionSkip(0.5), WithMetrics(metrics))
+		if assert.NoError(t, err) {
+			assert.Empty(t, p.CompressType)
+			assert.Equal(t, len(p.Data), p.RawSize)
+			assert.Nil(t, p.Header()["X-Log-Compresstype"])
+		}
+		assert.Equal(t, int64(1), metrics.counter(MetricCompressionSkip))
+		assert.Equal(t, []float64{1}, metrics.observations[MetricCompressionRatio])
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		// 不压缩时返回的内容不能与放回缓冲池的 raw 共享底层数组
+		w := NewWriter(&url.URL{}, "", "", "", nil, nil, WithCompressionSkip(0.5))
+		raw := []byte("raw")
+		data, _, _, _, err := w.compressBatch(raw, messages)
+		if assert.NoError(t, err) {
+			raw[0] = 'x'
+			assert.Equal(t, "raw", string(data))
+		}
+	})
+
+	t.Run("compress", func(t *testing.T) {
+		metrics := &MockMetrics{}
+		plain := []Message{{Time: time.Unix(1577836800, 0), Contents: map[string]string{"msg": strings.Repeat("hello world, ", 8)}}}
+		p, err := EncodeBatch("", "", plain, WithCompressionSkip(0.5), WithMetrics(metrics))
+		if assert.NoError(t, err) {
+			assert.Equal(t, CompressTypeLZ4, p.CompressType)
+		}
+		assert.Zero(t, metrics.counter(MetricCompressionSkip))
+		assert.Len(t, metrics.observations[MetricCompressionRatio], 1)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		p, err := EncodeBatch("", "", messages)
+		if assert.NoError(t, err) {
+			assert.Equal(t, CompressTypeLZ4, p.CompressType)
+		}
+	})
+}
+
+// testBlob 返回 base64 编码的伪随机字节, 模拟已压缩的字段值
+func testBlob() string {
+	data := make([]byte, 120)
+	for i := range data {
+		data[i] = byte(i*i*31 + i*7)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
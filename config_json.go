@@ -0,0 +1,140 @@
+package slsh
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UnknownFieldError 表示严格模式下配置中存在无法识别的键, 通常是拼写错误
+type UnknownFieldError struct {
+	Key        string
+	Suggestion string // 名称最接近的字段, 可能为空
+}
+
+func (e *UnknownFieldError) Error() string {
+	if e.Suggestion != "" {
+		return fmt.Sprintf("invalid config %q is unknown, did you mean %q?", e.Key, e.Suggestion)
+	}
+	return fmt.Sprintf("invalid config %q is unknown", e.Key)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// UnmarshalConfig 将 JSON 格式的配置解码到 c, 未出现的字段保持不变.
+// 键名不区分大小写且忽略 "_" 与 "-", 例如 access_key 对应 AccessKey; time.Duration 字段可使用 "5s" 格式的字符串.
+// strict 为 true 时存在无法识别的键 (含嵌套结构体中的键) 返回 *UnknownFieldError, 避免拼写错误的配置被静默忽略,
+// 直到发送失败时才被发现. YAML 格式的配置可先转换为 JSON
+func UnmarshalConfig(data []byte, c *Config, strict bool) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.PkgPath == "" {
+			fields[normalizeKey(f.Name)] = i
+		}
+	}
+
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		i, ok := fields[normalizeKey(k)]
+		if !ok {
+			if strict {
+				return &UnknownFieldError{Key: k, Suggestion: suggestField(t, k)}
+			}
+			continue
+		}
+		if err := decodeField(v.Field(i), raw[k], strict); err != nil {
+			return fmt.Errorf("invalid config %q: %w", k, err)
+		}
+	}
+	return nil
+}
+
+func decodeField(field reflect.Value, raw json.RawMessage, strict bool) error {
+	if field.Type() == durationType {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(field.Addr().Interface())
+}
+
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+}
+
+// suggestField 返回与 key 编辑距离最小且不超过 key 长度三分之一 (至少为 2) 的字段名
+func suggestField(t reflect.Type, key string) string {
+	key = normalizeKey(key)
+	max := len(key) / 3
+	if max < 2 {
+		max = 2
+	}
+
+	var best string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if d := editDistance(key, normalizeKey(f.Name)); d <= max {
+			best, max = f.Name, d-1
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,70 @@
+package slsh
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalConfig(t *testing.T) {
+	t.Run("decode", func(t *testing.T) {
+		c := Config{Topic: "kept"}
+		err := UnmarshalConfig([]byte(`{
+			"endpoint": "cn-hangzhou.log.aliyuncs.com",
+			"access_key": "key",
+			"AccessSecret": "secret",
+			"buffer-size": 100,
+			"interval": "2s",
+			"timeout": 500000000,
+			"time_clamp": {"Past": 3600000000000},
+			"extra": {"service": "demo"}
+		}`), &c, true)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "cn-hangzhou.log.aliyuncs.com", c.Endpoint)
+			assert.Equal(t, "key", c.AccessKey)
+			assert.Equal(t, "secret", c.AccessSecret)
+			assert.Equal(t, 100, c.BufferSize)
+			assert.Equal(t, 2*time.Second, c.Interval)
+			assert.Equal(t, 500*time.Millisecond, c.Timeout)
+			assert.Equal(t, time.Hour, c.TimeClamp.Past)
+			assert.Equal(t, map[string]string{"service": "demo"}, c.Extra)
+			assert.Equal(t, "kept", c.Topic)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		var c Config
+		err := UnmarshalConfig([]byte(`{"acces_key": "key", "project": "p"}`), &c, true)
+		var uErr *UnknownFieldError
+		if assert.True(t, errors.As(err, &uErr)) {
+			assert.Equal(t, "acces_key", uErr.Key)
+			assert.Equal(t, "AccessKey", uErr.Suggestion)
+			assert.EqualError(t, err, `invalid config "acces_key" is unknown, did you mean "AccessKey"?`)
+		}
+
+		err = UnmarshalConfig([]byte(`{"completely_unrelated": 1}`), &c, true)
+		if assert.True(t, errors.As(err, &uErr)) {
+			assert.Empty(t, uErr.Suggestion)
+		}
+
+		err = UnmarshalConfig([]byte(`{"time_clamp": {"Passt": 1}}`), &c, true)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "time_clamp")
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		var c Config
+		assert.NoError(t, UnmarshalConfig([]byte(`{"acces_key": "key", "time_clamp": {"Passt": 1}, "project": "p"}`), &c, false))
+		assert.Equal(t, "p", c.Project)
+		assert.Empty(t, c.AccessKey)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var c Config
+		assert.Error(t, UnmarshalConfig([]byte(`{"interval": "soon"}`), &c, true))
+		assert.Error(t, UnmarshalConfig([]byte(`{"buffer_size": "many"}`), &c, true))
+		assert.Error(t, UnmarshalConfig([]byte(`[]`), &c, true))
+	})
+}
@@ -0,0 +1,100 @@
+package slsh
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// Connect 失败的阶段
+const (
+	ConnectResolve = "resolve" // 解析接入点域名
+	ConnectProbe   = "probe"   // 发送签名的探测请求
+)
+
+// ConnectError 表示 Connect 在某一阶段失败, Err 为原始错误, 例如凭证错误时为 *SignatureError
+type ConnectError struct {
+	Stage string
+	Err   error
+}
+
+func (e *ConnectError) Error() string { return "slsh: connect (" + e.Stage + "): " + e.Err.Error() }
+func (e *ConnectError) Unwrap() error { return e.Err }
+
+// Connect 主动连接接入点: 解析域名, 发送签名的探测请求验证网络, 凭证与日志库, 探测使用的连接放回连接池供发送复用.
+// NewWriter 不访问网络, 域名在首次发送时才解析, 需要在启动阶段发现配置错误时调用 Connect
+func (w *writer) Connect(ctx context.Context) error {
+	if !w.proxied() {
+		if err := resolve(ctx, w.uri.Hostname()); err != nil {
+			return &ConnectError{Stage: ConnectResolve, Err: err}
+		}
+	}
+	if err := w.Ping(ctx); err != nil {
+		return &ConnectError{Stage: ConnectProbe, Err: err}
+	}
+	return nil
+}
+
+// proxied 判断请求是否经过代理, 经过代理时域名由代理解析, 本地可能无法解析
+func (w *writer) proxied() bool {
+	t, ok := w.client.Transport.(*http.Transport)
+	if w.client.Transport == nil {
+		t, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if !ok {
+		// 无法判断的自定义 Transport 同样跳过解析
+		return true
+	}
+	if t.Proxy == nil {
+		return false
+	}
+	u, err := t.Proxy(&http.Request{URL: w.uri})
+	return err != nil || u != nil
+}
+
+func resolve(ctx context.Context, host string) error {
+	if host == "" || net.ParseIP(host) != nil {
+		return nil
+	}
+	_, err := net.DefaultResolver.LookupHost(ctx, host)
+	return err
+}
+
+// Connect 连接第一个分片检查网络与凭证, 其余分片只预先建立连接
+func (s *shardedWriter) Connect(ctx context.Context) error {
+	if c, ok := s.writers[0].(Connector); ok {
+		if err := c.Connect(ctx); err != nil {
+			return err
+		}
+	}
+	for _, w := range s.writers[1:] {
+		if w, ok := w.(Warmer); ok {
+			if err := w.WarmUp(ctx); err != nil {
+				return &ConnectError{Stage: ConnectProbe, Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+// Connect 主动连接接入点并验证凭证与日志库, 参考 NewConnected
+func (h *Hook) Connect(ctx context.Context) error {
+	if c, ok := h.writer.(Connector); ok {
+		return c.Connect(ctx)
+	}
+	return nil
+}
+
+// NewConnected 与 New 相同, 但在返回前调用 Connect, 连接失败时关闭 Hook 并返回 *ConnectError.
+// New 不访问网络, 接入点不可用或凭证错误时只在发送时报告; 需要在启动阶段快速失败时使用 NewConnected
+func NewConnected(ctx context.Context, c Config) (*Hook, error) {
+	hook, err := New(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := hook.Connect(ctx); err != nil {
+		_ = hook.Close()
+		return nil, err
+	}
+	return hook, nil
+}
@@ -0,0 +1,69 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterConnect(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		sig, err := signature(DefaultAccessSecret, req)
+		if assert.NoError(t, err) && req.Header.Get("Authorization") != "LOG "+DefaultAccessKey+":"+sig {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"errorCode":"SignatureNotMatch","errorMessage":"signature not match"}`))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL + "/logstores/test-logstore/shards/lb")
+	client := &http.Client{Transport: &http.Transport{}}
+
+	t.Run("lazy", func(t *testing.T) {
+		NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, client)
+		assert.Equal(t, 0, requests)
+	})
+
+	t.Run("connect", func(t *testing.T) {
+		w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, client)
+		assert.NoError(t, w.Connect(context.TODO()))
+		assert.Equal(t, 1, requests)
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, Secret("wrong"), client)
+		err := w.Connect(context.TODO())
+		var cErr *ConnectError
+		var sErr *SignatureError
+		if assert.True(t, errors.As(err, &cErr)) {
+			assert.Equal(t, ConnectProbe, cErr.Stage)
+		}
+		assert.True(t, errors.As(err, &sErr))
+	})
+
+	t.Run("resolve", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		u, _ := url.Parse("http://slsh-connect-test.invalid/logstores/test-logstore/shards/lb")
+		w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, client)
+		var cErr *ConnectError
+		if assert.True(t, errors.As(w.Connect(ctx), &cErr)) {
+			assert.Equal(t, ConnectResolve, cErr.Stage)
+		}
+	})
+
+	t.Run("proxied", func(t *testing.T) {
+		proxy, _ := url.Parse("http://proxy.invalid:3128")
+		w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret,
+			&http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxy)}})
+		assert.True(t, w.proxied())
+		assert.False(t, NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, client).proxied())
+	})
+}
@@ -0,0 +1,290 @@
+package slsh
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/lz4block"
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/validator"
+)
+
+// 起始位置
+const (
+	CursorBegin = "begin"
+	CursorEnd   = "end"
+)
+
+// 消费者配置
+type ConsumerConfig struct {
+	Endpoint     string              // 接入点, 例如: "cn-hangzhou.log.aliyuncs.com"
+	AccessKey    string              // 访问密钥 ID
+	AccessSecret string              // 访问密钥
+	Credentials  CredentialsProvider // 凭证提供者, 可选, 设置后忽略 AccessKey 与 AccessSecret
+	Project      string              // 项目名称
+	Store        string              // 日志库名称
+	HTTPS        bool                // 是否使用 HTTPS
+	HttpClient   *http.Client        // 可选, 默认为 http.DefaultClient
+	From         string              // 起始位置: CursorBegin, CursorEnd 或 Unix 时间戳 (秒), 默认为 CursorEnd
+	Count        int                 // 每次拉取的日志组数上限, 取值 [1, 1000], 默认为 100
+}
+
+func (c *ConsumerConfig) validate() error {
+	if c.Credentials == nil {
+		if err := validator.All(
+			validator.Required("AccessKey", c.AccessKey),
+			validator.Required("AccessSecret", c.AccessSecret),
+		); err != nil {
+			return err
+		}
+		c.Credentials = StaticCredentials(c.AccessKey, Secret(c.AccessSecret))
+	}
+
+	if err := validator.All(
+		validator.Required("Endpoint", c.Endpoint),
+		validator.Required("Project", c.Project),
+		validator.Required("Store", c.Store),
+	); err != nil {
+		return err
+	}
+
+	c.From = validator.CoalesceStr(c.From, CursorEnd)
+	if c.From != CursorBegin && c.From != CursorEnd {
+		if _, err := strconv.ParseInt(c.From, 10, 64); err != nil {
+			return validator.IllegalArgument("From", "must be begin, end or a unix timestamp")
+		}
+	}
+
+	c.Count = validator.CoalesceInt(c.Count, 100)
+	if c.Count < 1 || c.Count > 1000 {
+		return validator.IllegalArgument("Count", "must be in [1, 1000]")
+	}
+
+	if c.HttpClient == nil {
+		c.HttpClient = http.DefaultClient
+	}
+	return nil
+}
+
+// Consumer 通过 PullLogs 接口逐个分片读取日志库, 用于调试或集成测试中 tail 日志,
+// 游标仅保存在内存中, 多实例分担分片与检查点参考 Consumer.Group, 不处理分片分裂合并, 生产环境的消费请使用官方 SDK
+type Consumer struct {
+	writer *writer
+	from   string
+	count  int
+
+	mu      sync.Mutex
+	cursors map[int]string
+}
+
+// NewConsumer 创建消费者, 不发送请求, 首次拉取某个分片时按 From 获取游标
+func NewConsumer(c ConsumerConfig) (*Consumer, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	scheme := "http"
+	if c.HTTPS {
+		scheme = "https"
+	}
+	uri, err := url.Parse(fmt.Sprintf("%s://%s.%s/logstores/%s", scheme, c.Project, c.Endpoint, c.Store))
+	if err != nil {
+		return nil, validator.IllegalArgument("Endpoint", err.Error())
+	}
+
+	return &Consumer{
+		writer:  NewWriter(uri, "", "", "", nil, c.HttpClient, WithCredentialsProvider(c.Credentials)),
+		from:    c.From,
+		count:   c.Count,
+		cursors: make(map[int]string),
+	}, nil
+}
+
+// Shards 返回日志库的分片 ID, 包含分裂后只读的分片
+func (c *Consumer) Shards(ctx context.Context) ([]int, error) {
+	var shards []struct {
+		ShardID int `json:"shardID"`
+	}
+	if err := c.getJSON(ctx, "/shards", nil, &shards); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(shards))
+	for _, shard := range shards {
+		ids = append(ids, shard.ShardID)
+	}
+	return ids, nil
+}
+
+// Cursor 返回分片当前的游标, 尚未拉取过时为空
+func (c *Consumer) Cursor(shard int) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cursors[shard]
+}
+
+// SetCursor 设置分片的游标, 可用于从之前记录的位置继续读取
+func (c *Consumer) SetCursor(shard int, cursor string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cursors[shard] = cursor
+}
+
+// Pull 从分片当前游标处拉取一批日志并推进游标, 没有新日志时返回空
+func (c *Consumer) Pull(ctx context.Context, shard int) ([]Message, error) {
+	cursor := c.Cursor(shard)
+	if cursor == "" {
+		var body struct {
+			Cursor string `json:"cursor"`
+		}
+		query := url.Values{"type": {"cursor"}, "from": {c.from}}
+		if err := c.getJSON(ctx, shardPath(shard), query, &body); err != nil {
+			return nil, err
+		}
+		cursor = body.Cursor
+	}
+
+	query := url.Values{"type": {"logs"}, "cursor": {cursor}, "count": {strconv.Itoa(c.count)}}
+	resp, err := c.writer.get(ctx, shardPath(shard), query, http.Header{
+		"Accept":          {"application/x-protobuf"},
+		"Accept-Encoding": {CompressTypeLZ4},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body, c.writer.maxBody)
+	if err := c.writer.validateResponse(resp); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Header.Get("X-Log-Compresstype") == CompressTypeLZ4 {
+		if data, err = uncompressLZ4(data, resp.Header.Get("X-Log-Bodyrawsize")); err != nil {
+			return nil, err
+		}
+	}
+	messages, err := decodeGroupList(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if next := resp.Header.Get("X-Log-Cursor"); next != "" {
+		c.SetCursor(shard, next)
+	} else {
+		c.SetCursor(shard, cursor)
+	}
+	return messages, nil
+}
+
+// Tail 持续拉取所有分片, 将日志依次交给 fn 处理, 所有分片均无新日志时等待 interval,
+// 直至 ctx 结束或 fn 返回错误
+func (c *Consumer) Tail(ctx context.Context, interval time.Duration, fn func(shard int, message Message) error) error {
+	shards, err := c.Shards(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		idle := true
+		for _, shard := range shards {
+			messages, err := c.Pull(ctx, shard)
+			if err != nil {
+				return err
+			}
+			for _, message := range messages {
+				if err := fn(shard, message); err != nil {
+					return err
+				}
+			}
+			idle = idle && len(messages) == 0
+		}
+		if !idle {
+			continue
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Consumer) getJSON(ctx context.Context, sub string, query url.Values, out interface{}) error {
+	resp, err := c.writer.get(ctx, sub, query, nil)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp.Body, c.writer.maxBody)
+	if err := c.writer.validateResponse(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, c.writer.maxBody)).Decode(out)
+}
+
+func shardPath(shard int) string { return "/shards/" + strconv.Itoa(shard) }
+
+func uncompressLZ4(data []byte, rawSize string) ([]byte, error) {
+	n, err := strconv.Atoi(rawSize)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid x-log-bodyrawsize: %q", rawSize)
+	}
+	return lz4block.Uncompress(data, n)
+}
+
+var errMalformedGroupList = errors.New("malformed LogGroupList")
+
+// decodeGroupList 解析 LogGroupList (字段 1 为重复的 LogGroup), 将每条日志转换为 Message,
+// 主题与来源保存在 TopicKey 与 SourceKey 字段
+func decodeGroupList(data []byte) ([]Message, error) {
+	var messages []Message
+	for len(data) > 0 {
+		// LogGroupList.LogGroups 与 LogGroup.Logs 同为字段 1 (bytes), tag 相同
+		if data[0] != tagGroupLogs {
+			return nil, errMalformedGroupList
+		}
+		size, n := binary.Uvarint(data[1:])
+		if n <= 0 || size > uint64(len(data)-1-n) {
+			return nil, errMalformedGroupList
+		}
+		data = data[1+n:]
+
+		group := &api.LogGroup{}
+		if err := proto.Unmarshal(data[:size], group); err != nil {
+			return nil, err
+		}
+		data = data[size:]
+
+		for _, log := range group.Logs {
+			contents := make(map[string]string, len(log.Contents)+2)
+			for _, content := range log.Contents {
+				contents[content.GetKey()] = content.GetValue()
+			}
+			if group.Topic != nil {
+				contents[TopicKey] = group.GetTopic()
+			}
+			if group.Source != nil {
+				contents[SourceKey] = group.GetSource()
+			}
+			messages = append(messages, Message{Time: time.Unix(int64(log.GetTime()), 0), Contents: contents})
+		}
+	}
+	return messages, nil
+}
@@ -0,0 +1,132 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+)
+
+func TestConsumer(t *testing.T) {
+	topic, source := "topic", "source"
+	group := &api.LogGroup{
+		Topic:  &topic,
+		Source: &source,
+		Logs: []*api.Log{{
+			Time:     proto.Uint32(1577836800),
+			Contents: []*api.Log_Content{{Key: proto.String("key"), Value: proto.String("value")}},
+		}},
+	}
+	data, err := proto.Marshal(group)
+	if !assert.NoError(t, err) {
+		return
+	}
+	list := append(appendVarint([]byte{tagGroupLogs}, uint64(len(data))), data...)
+
+	var queries []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "project.example.com", req.Host)
+		assert.Equal(t, http.MethodGet, req.Method)
+		assert.Contains(t, req.Header.Get("Authorization"), "LOG key:")
+		queries = append(queries, req.URL.Query())
+
+		switch req.URL.Query().Get("type") {
+		case "":
+			assert.Equal(t, "/logstores/store/shards", req.URL.Path)
+			_, _ = w.Write([]byte(`[{"shardID":0,"status":"readwrite"},{"shardID":1,"status":"readonly"}]`))
+		case "cursor":
+			_, _ = w.Write([]byte(`{"cursor":"c0"}`))
+		case "logs":
+			if req.URL.Path != "/logstores/store/shards/0" || req.URL.Query().Get("cursor") != "c0" {
+				w.Header().Set("X-Log-Cursor", req.URL.Query().Get("cursor"))
+				return
+			}
+			body, err := compressLZ4(list, CompressionFastest)
+			assert.NoError(t, err)
+			w.Header().Set("X-Log-Cursor", "c1")
+			w.Header().Set("X-Log-Compresstype", CompressTypeLZ4)
+			w.Header().Set("X-Log-Bodyrawsize", strconv.Itoa(len(list)))
+			_, _ = w.Write(body)
+		}
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	c, err := NewConsumer(ConsumerConfig{
+		Endpoint:     "example.com",
+		AccessKey:    "key",
+		AccessSecret: "secret",
+		Project:      "project",
+		Store:        "store",
+		From:         CursorBegin,
+		HttpClient: &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+			return http.DefaultTransport.RoundTrip(req)
+		})},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("pull", func(t *testing.T) {
+		messages, err := c.Pull(context.TODO(), 0)
+		if assert.NoError(t, err) && assert.Len(t, messages, 1) {
+			assert.Equal(t, int64(1577836800), messages[0].Time.Unix())
+			assert.Equal(t, map[string]string{"key": "value", TopicKey: topic, SourceKey: source}, messages[0].Contents)
+		}
+		assert.Equal(t, "c1", c.Cursor(0))
+		assert.Equal(t, CursorBegin, queries[0].Get("from"))
+		assert.Equal(t, "100", queries[1].Get("count"))
+
+		messages, err = c.Pull(context.TODO(), 0)
+		assert.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("tail", func(t *testing.T) {
+		c.SetCursor(0, "c0")
+		ctx, cancel := context.WithCancel(context.TODO())
+		var shards []int
+		err := c.Tail(ctx, time.Millisecond, func(shard int, message Message) error {
+			shards = append(shards, shard)
+			cancel()
+			return nil
+		})
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.Equal(t, []int{0}, shards)
+	})
+
+	t.Run("config", func(t *testing.T) {
+		_, err := NewConsumer(ConsumerConfig{Endpoint: "example.com", AccessKey: "key", AccessSecret: "secret"})
+		assert.Error(t, err)
+
+		config := ConsumerConfig{Endpoint: "e", AccessKey: "k", AccessSecret: "s", Project: "p", Store: "s", From: "yesterday"}
+		_, err = NewConsumer(config)
+		assert.Error(t, err)
+
+		config.From, config.Count = "1577836800", 1001
+		_, err = NewConsumer(config)
+		assert.Error(t, err)
+	})
+}
+
+func TestDecodeGroupList(t *testing.T) {
+	_, err := decodeGroupList([]byte{0x12, 0x00})
+	assert.Equal(t, errMalformedGroupList, err)
+
+	_, err = decodeGroupList([]byte{tagGroupLogs, 0x05, 0x00})
+	assert.Equal(t, errMalformedGroupList, err)
+
+	messages, err := decodeGroupList(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, messages)
+}
@@ -0,0 +1,211 @@
+package slsh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/validator"
+)
+
+const (
+	DefaultGroupTimeout = 60 * time.Second // 消费者心跳超时
+	errGroupExist       = "ConsumerGroupAlreadyExist"
+)
+
+// 消费组配置
+type ConsumerGroupConfig struct {
+	Name     string        // 消费组名称
+	Consumer string        // 当前实例在消费组中的名称, 可选, 默认为 "<hostname>-<pid>", 各实例需不同
+	Timeout  time.Duration // 心跳超时, 超时未发送心跳的实例持有的分片会分配给其他实例, 可选, 默认为 60s, 精确到秒
+	InOrder  bool          // 分片分裂后是否先消费完旧分片再消费新分片, 仅在创建消费组时生效
+}
+
+// ConsumerGroup 服务端消费组: 多个实例以不同的名称加入同一消费组, 服务端根据心跳分配分片并保存各分片的检查点,
+// 使多实例的 tail 或调试工具可以分担分片并在重启后继续读取. 复用 Consumer 的签名与拉取, 不依赖官方消费库,
+// 不处理分片分裂合并的消费顺序以外的细节
+type ConsumerGroup struct {
+	consumer *Consumer
+	name     string
+	member   string
+	timeout  time.Duration
+	inOrder  bool
+}
+
+// Group 返回加入消费组 c.Name 的消费组客户端, 不发送请求, 使用前需确保消费组已存在, 参考 ConsumerGroup.Create
+func (c *Consumer) Group(g ConsumerGroupConfig) (*ConsumerGroup, error) {
+	if err := validator.Required("Name", g.Name); err != nil {
+		return nil, err
+	}
+	if g.Consumer == "" {
+		host, _ := os.Hostname()
+		g.Consumer = host + "-" + strconv.Itoa(os.Getpid())
+	}
+	g.Timeout = validator.CoalesceDur(g.Timeout, DefaultGroupTimeout)
+	if g.Timeout < time.Second {
+		return nil, validator.IllegalArgument("Timeout", "must be at least 1s")
+	}
+	return &ConsumerGroup{consumer: c, name: g.Name, member: g.Consumer, timeout: g.Timeout, inOrder: g.InOrder}, nil
+}
+
+// Create 创建消费组, 已存在时返回 nil
+func (g *ConsumerGroup) Create(ctx context.Context) error {
+	body := map[string]interface{}{
+		"consumerGroup": g.name,
+		"timeout":       int(g.timeout / time.Second),
+		"order":         g.inOrder,
+	}
+	err := g.post(ctx, "/consumergroups", nil, body, nil)
+	var aErr *AliyunError
+	if errors.As(err, &aErr) && aErr.Code == errGroupExist {
+		return nil
+	}
+	return err
+}
+
+// Heartbeat 报告当前实例正在消费的分片, 返回服务端分配给当前实例的分片. 需在 Timeout 内持续调用,
+// 不在返回结果中的分片应停止消费, 返回结果中新增的分片从检查点继续读取
+func (g *ConsumerGroup) Heartbeat(ctx context.Context, held []int) ([]int, error) {
+	if held == nil {
+		held = []int{}
+	}
+	var assigned []int
+	query := url.Values{"type": {"heartbeat"}, "consumer": {g.member}}
+	if err := g.post(ctx, g.path(), query, held, &assigned); err != nil {
+		return nil, err
+	}
+	return assigned, nil
+}
+
+// Commit 保存分片的检查点, cursor 为下次读取的起始游标 (即 Consumer.Cursor 在处理完一批日志后的值).
+// 分片已分配给其他实例时服务端拒绝保存, 避免失去分片的实例覆盖新实例的进度
+func (g *ConsumerGroup) Commit(ctx context.Context, shard int, cursor string) error {
+	query := url.Values{"type": {"checkpoint"}, "consumer": {g.member}, "forceSuccess": {"false"}}
+	body := map[string]interface{}{"shard": shard, "checkpoint": cursor}
+	return g.post(ctx, g.path(), query, body, nil)
+}
+
+// Checkpoints 返回各分片已保存的检查点, 未保存过检查点的分片不包含在内
+func (g *ConsumerGroup) Checkpoints(ctx context.Context) (map[int]string, error) {
+	var checkpoints []struct {
+		Shard      int    `json:"shard"`
+		Checkpoint string `json:"checkpoint"`
+	}
+	if err := g.consumer.getJSON(ctx, g.path(), nil, &checkpoints); err != nil {
+		return nil, err
+	}
+
+	m := make(map[int]string, len(checkpoints))
+	for _, c := range checkpoints {
+		if c.Checkpoint != "" {
+			m[c.Shard] = c.Checkpoint
+		}
+	}
+	return m, nil
+}
+
+// Tail 以消费组成员的身份持续消费: 每 Timeout/3 发送一次心跳, 拉取分配给当前实例的分片,
+// 新分配的分片从检查点继续读取 (没有检查点时按 ConsumerConfig.From), 每批日志由 fn 处理完成后保存检查点,
+// 所有分片均无新日志时等待 interval, 直至 ctx 结束或 fn 返回错误
+func (g *ConsumerGroup) Tail(ctx context.Context, interval time.Duration, fn func(shard int, message Message) error) error {
+	var held []int
+	var beat time.Time
+	for {
+		if time.Since(beat) >= g.timeout/3 {
+			assigned, err := g.Heartbeat(ctx, held)
+			if err != nil {
+				return err
+			}
+			beat = time.Now()
+			if added := newShards(held, assigned); len(added) > 0 {
+				if err := g.restore(ctx, added); err != nil {
+					return err
+				}
+			}
+			held = assigned
+		}
+
+		idle := true
+		for _, shard := range held {
+			messages, err := g.consumer.Pull(ctx, shard)
+			if err != nil {
+				return err
+			}
+			for _, message := range messages {
+				if err := fn(shard, message); err != nil {
+					return err
+				}
+			}
+			if len(messages) > 0 {
+				idle = false
+				if err := g.Commit(ctx, shard, g.consumer.Cursor(shard)); err != nil {
+					return err
+				}
+			}
+		}
+		if !idle {
+			continue
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// restore 将新分配分片的游标设为检查点, 没有检查点的分片清空游标, 在下次拉取时按 From 获取
+func (g *ConsumerGroup) restore(ctx context.Context, shards []int) error {
+	checkpoints, err := g.Checkpoints(ctx)
+	if err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		g.consumer.SetCursor(shard, checkpoints[shard])
+	}
+	return nil
+}
+
+func newShards(held, assigned []int) []int {
+	seen := make(map[int]bool, len(held))
+	for _, shard := range held {
+		seen[shard] = true
+	}
+	var added []int
+	for _, shard := range assigned {
+		if !seen[shard] {
+			added = append(added, shard)
+		}
+	}
+	return added
+}
+
+func (g *ConsumerGroup) path() string { return "/consumergroups/" + url.PathEscape(g.name) }
+
+func (g *ConsumerGroup) post(ctx context.Context, sub string, query url.Values, body, out interface{}) error {
+	w := g.consumer.writer
+	resp, err := w.postJSON(ctx, sub, query, body)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp.Body, w.maxBody)
+	if err := w.validateResponse(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, w.maxBody)).Decode(out); err != nil {
+		return fmt.Errorf("decode %s response: %w", query.Get("type"), err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package slsh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+)
+
+func TestConsumerGroup(t *testing.T) {
+	group := &api.LogGroup{Logs: []*api.Log{{
+		Time:     proto.Uint32(1577836800),
+		Contents: []*api.Log_Content{{Key: proto.String("key"), Value: proto.String("value")}},
+	}}}
+	data, err := proto.Marshal(group)
+	if !assert.NoError(t, err) {
+		return
+	}
+	list := append(appendVarint([]byte{tagGroupLogs}, uint64(len(data))), data...)
+
+	var mu sync.Mutex
+	var created int
+	var heartbeats [][]int
+	var commits []map[string]interface{}
+	var onCommit func()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		sig, err := signature(Secret("secret"), req)
+		assert.NoError(t, err)
+		assert.Equal(t, "LOG key:"+sig, req.Header.Get("Authorization"))
+		body, _ := ioutil.ReadAll(req.Body)
+
+		query := req.URL.Query()
+		switch {
+		case req.Method == http.MethodPost && req.URL.Path == "/logstores/store/consumergroups":
+			var b map[string]interface{}
+			assert.NoError(t, json.Unmarshal(body, &b))
+			assert.Equal(t, map[string]interface{}{"consumerGroup": "g", "timeout": float64(30), "order": false}, b)
+			if created++; created > 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"errorCode":"ConsumerGroupAlreadyExist","errorMessage":"exists"}`))
+			}
+		case req.URL.Path != "/logstores/store/consumergroups/g":
+			assert.Equal(t, "/logstores/store/shards/0", req.URL.Path)
+			if query.Get("cursor") != "c1" {
+				w.Header().Set("X-Log-Cursor", query.Get("cursor"))
+				return
+			}
+			w.Header().Set("X-Log-Cursor", "c2")
+			w.Header().Set("X-Log-Bodyrawsize", strconv.Itoa(len(list)))
+			_, _ = w.Write(list)
+		case req.Method == http.MethodGet:
+			_, _ = w.Write([]byte(`[{"shard":0,"checkpoint":"c1","consumer":"m1"},{"shard":1,"checkpoint":""}]`))
+		case query.Get("type") == "heartbeat":
+			assert.Equal(t, "m1", query.Get("consumer"))
+			var held []int
+			assert.NoError(t, json.Unmarshal(body, &held))
+			heartbeats = append(heartbeats, held)
+			_, _ = w.Write([]byte(`[0]`))
+		case query.Get("type") == "checkpoint":
+			assert.Equal(t, "false", query.Get("forceSuccess"))
+			var b map[string]interface{}
+			assert.NoError(t, json.Unmarshal(body, &b))
+			commits = append(commits, b)
+			if onCommit != nil {
+				onCommit()
+			}
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL)
+		}
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	c, err := NewConsumer(ConsumerConfig{
+		Endpoint:     "example.com",
+		AccessKey:    "key",
+		AccessSecret: "secret",
+		Project:      "project",
+		Store:        "store",
+		HttpClient: &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+			return http.DefaultTransport.RoundTrip(req)
+		})},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	g, err := c.Group(ConsumerGroupConfig{Name: "g", Consumer: "m1", Timeout: 30 * time.Second})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("create", func(t *testing.T) {
+		assert.NoError(t, g.Create(context.TODO()))
+		assert.NoError(t, g.Create(context.TODO()))
+	})
+
+	t.Run("checkpoints", func(t *testing.T) {
+		checkpoints, err := g.Checkpoints(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, map[int]string{0: "c1"}, checkpoints)
+	})
+
+	t.Run("tail", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		onCommit = cancel
+
+		var pulled []Message
+		err := g.Tail(ctx, time.Millisecond, func(shard int, message Message) error {
+			assert.Equal(t, 0, shard)
+			pulled = append(pulled, message)
+			return nil
+		})
+		assert.True(t, errors.Is(err, context.Canceled))
+		if assert.Len(t, pulled, 1) {
+			assert.Equal(t, "value", pulled[0].Contents["key"])
+		}
+		assert.Equal(t, [][]int{{}}, heartbeats)
+		assert.Equal(t, []map[string]interface{}{{"shard": float64(0), "checkpoint": "c2"}}, commits)
+		assert.Equal(t, "c2", c.Cursor(0))
+	})
+
+	t.Run("config", func(t *testing.T) {
+		_, err := c.Group(ConsumerGroupConfig{})
+		assert.Error(t, err)
+		_, err = c.Group(ConsumerGroupConfig{Name: "g", Timeout: time.Millisecond})
+		assert.Error(t, err)
+
+		g, err := c.Group(ConsumerGroupConfig{Name: "g"})
+		if assert.NoError(t, err) {
+			assert.NotEmpty(t, g.member)
+			assert.Equal(t, DefaultGroupTimeout, g.timeout)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package slsh
 import (
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -15,12 +16,44 @@ type ContentModifierFunc func(map[string]string)
 
 func (f ContentModifierFunc) Modify(contents map[string]string) { f(contents) }
 
+// 按顺序依次执行的 ContentModifier 组合
+type ContentModifiers []ContentModifier
+
+func (ms ContentModifiers) Modify(contents map[string]string) {
+	for _, m := range ms {
+		m.Modify(contents)
+	}
+}
+
+// 日志级别名称, 与阿里云日志控制台按级别筛选的约定一致
+var severities = [...]string{"FATAL", "FATAL", "ERROR", "WARN", "INFO", "DEBUG", "TRACE"}
+
+// Severity 返回日志级别名称, Panic 与 Fatal 均为 "FATAL"
+func Severity(level logrus.Level) string {
+	if int(level) < len(severities) {
+		return severities[level]
+	}
+	return "UNKNOWN"
+}
+
+// converter 构造后只读, 可被多个 goroutine 并发调用 Message.
+// Extra 在构造时复制, 之后修改传入的 map 不影响已创建的 converter
 type converter struct {
 	MessageKey   string
 	LevelKey     string
 	LevelMapping LevelMapping
 	Extra        map[string]string
+	LevelExtra   map[logrus.Level]map[string]string
 	Modifier     ContentModifier
+	Interner     *Interner     // 字段值驻留缓存, 可选
+	Types        *TypeRegistry // 字段类型记录, 可选
+	SeverityKey  string        // 级别名称字段, 可选, 为空时不输出
+	TimeLayout   string        // time.Time 字段值的格式, 可选, 默认为 time.RFC3339Nano
+	LogIDKey     string        // 日志 ID 字段, 可选, 为空时不输出, 日志中已有该字段时不覆盖
+	IDs          IDGenerator   // 日志 ID 生成器, 可选, 默认为 RandomID
+
+	// 设置后 time.Time 字段额外输出以该后缀命名的 Unix 毫秒时间戳字段, 可选
+	TimeMillisSuffix string
 }
 
 func NewConverter(messageKey, levelKey string,
@@ -32,40 +65,147 @@ func NewConverter(messageKey, levelKey string,
 		MessageKey:   messageKey,
 		LevelKey:     levelKey,
 		LevelMapping: levelMapping,
-		Extra:        extra,
+		Extra:        copyExtra(extra),
 		Modifier:     modifier,
 	}
 }
 
+func copyExtra(extra map[string]string) map[string]string {
+	if extra == nil {
+		return nil
+	}
+	m := make(map[string]string, len(extra))
+	for k, v := range extra {
+		m[k] = v
+	}
+	return m
+}
+
+func copyLevelExtra(levelExtra map[logrus.Level]map[string]string) map[logrus.Level]map[string]string {
+	if levelExtra == nil {
+		return nil
+	}
+	m := make(map[logrus.Level]map[string]string, len(levelExtra))
+	for level, extra := range levelExtra {
+		m[level] = copyExtra(extra)
+	}
+	return m
+}
+
 func (c converter) Message(entry *logrus.Entry) Message {
 	contents := make(map[string]string)
 	for k, v := range c.Extra {
 		contents[k] = v
 	}
+	for k, v := range c.LevelExtra[entry.Level] {
+		contents[k] = v
+	}
 	contents[c.MessageKey] = entry.Message
 	contents[c.LevelKey] = strconv.Itoa(c.LevelMapping(entry.Level))
+	if c.SeverityKey != "" {
+		contents[c.SeverityKey] = Severity(entry.Level)
+	}
 	for k, v := range entry.Data {
-		switch v := v.(type) {
-		case string:
-			contents[k] = v
-		case int, int8, int16, int32, int64,
-			uint, uint8, uint16, uint32, uint64:
-			contents[k] = fmt.Sprintf("%d", v)
-		case float32, float64:
-			contents[k] = fmt.Sprintf("%f", v)
-		case bool:
-			contents[k] = strconv.FormatBool(v)
-		default:
-			contents[k] = fmt.Sprintf("%v", v)
+		c.field(contents, k, v)
+	}
+	if _, ok := contents[c.LogIDKey]; c.LogIDKey != "" && !ok {
+		ids := c.IDs
+		if ids == nil {
+			ids = RandomID
 		}
+		contents[c.LogIDKey] = ids.NewID()
 	}
 
 	if c.Modifier != nil {
 		c.Modifier.Modify(contents)
 	}
 
-	return Message{
+	message := Message{
 		Time:     entry.Time,
 		Contents: contents,
 	}
+	reserved(entry, &message)
+	return message
+}
+
+// field 将字段值转换为字符串, 转换中的 panic (例如 String 方法或自定义类型的 nil 接收者) 被恢复,
+// 字段值替换为与 fmt 一致的占位内容 "%!v(PANIC=...)", 日志其余内容照常发送
+func (c converter) field(contents map[string]string, k string, v interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			contents[k] = "%!v(PANIC=" + panicValue(r) + ")"
+		}
+	}()
+
+	if c.Types != nil {
+		c.Types.observe(k, v)
+	}
+	switch v := v.(type) {
+	case string:
+		contents[k] = v
+	case int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64:
+		contents[k] = fmt.Sprintf("%d", v)
+	case float32, float64:
+		contents[k] = fmt.Sprintf("%f", v)
+	case bool:
+		contents[k] = strconv.FormatBool(v)
+	case time.Time:
+		c.formatTime(contents, k, v)
+	case *time.Time:
+		if v == nil {
+			contents[k] = "<nil>"
+			break
+		}
+		c.formatTime(contents, k, *v)
+	default:
+		contents[k] = fmt.Sprintf("%v", v)
+	}
+	if c.Interner != nil {
+		contents[k] = c.Interner.Intern(contents[k])
+	}
+}
+
+// formatTime 按 TimeLayout 格式化时间字段 (不含单调时钟读数), 并按需输出毫秒时间戳字段
+func (c converter) formatTime(contents map[string]string, k string, t time.Time) {
+	layout := c.TimeLayout
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	contents[k] = t.Format(layout)
+	if c.TimeMillisSuffix != "" && k != TimeKey {
+		contents[k+c.TimeMillisSuffix] = strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+}
+
+// reserved 将保留字段移到 Message 的 Topic, Source 与 Time, 无法解析的 __time__ 保留为普通字段
+func reserved(entry *logrus.Entry, m *Message) {
+	if v, ok := m.Contents[TopicKey]; ok {
+		m.Topic = v
+		delete(m.Contents, TopicKey)
+	}
+	if v, ok := m.Contents[SourceKey]; ok {
+		m.Source = v
+		delete(m.Contents, SourceKey)
+	}
+	if v, ok := m.Contents[TimeKey]; ok {
+		if t, ok := reservedTime(entry.Data[TimeKey], v); ok {
+			m.Time = t
+			delete(m.Contents, TimeKey)
+		}
+	}
+}
+
+// reservedTime 解析 __time__ 字段, 支持 time.Time, Unix 秒与 RFC3339 格式
+func reservedTime(raw interface{}, value string) (time.Time, bool) {
+	if t, ok := raw.(time.Time); ok {
+		return t, true
+	}
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0), true
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
 }
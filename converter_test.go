@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -38,6 +39,63 @@ func TestConverter(t *testing.T) {
 		assert.Equal(t, strconv.Itoa(int(entry.Level)), msg.Contents[c.LevelKey])
 	})
 
+	t.Run("reserved", func(t *testing.T) {
+		c := NewConverter("m", "l", SyslogLevelMapping, map[string]string{SourceKey: "10.0.0.1"}, nil)
+		at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, v := range []interface{}{at, at.Unix(), at.Format(time.RFC3339)} {
+			msg := c.Message(&logrus.Entry{Data: logrus.Fields{TopicKey: "audit", TimeKey: v}, Time: time.Now()})
+			assert.True(t, at.Equal(msg.Time), v)
+			assert.Equal(t, "audit", msg.Topic)
+			assert.Equal(t, "10.0.0.1", msg.Source)
+			for _, k := range []string{TopicKey, SourceKey, TimeKey} {
+				assert.NotContains(t, msg.Contents, k)
+			}
+		}
+
+		// 无法解析的时间保留为普通字段
+		entry := &logrus.Entry{Data: logrus.Fields{TimeKey: "yesterday"}, Time: time.Now()}
+		msg := c.Message(entry)
+		assert.Equal(t, entry.Time, msg.Time)
+		assert.Equal(t, "yesterday", msg.Contents[TimeKey])
+	})
+
+	t.Run("time", func(t *testing.T) {
+		at := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.FixedZone("CST", 8*3600))
+		monotonic := time.Now()
+		entry := &logrus.Entry{Data: logrus.Fields{"at": at, "ptr": &at, "nil": (*time.Time)(nil), "now": monotonic}}
+
+		c := NewConverter("m", "l", SyslogLevelMapping, nil, nil)
+		msg := c.Message(entry)
+		assert.Equal(t, "2020-01-02T03:04:05.006+08:00", msg.Contents["at"])
+		assert.Equal(t, msg.Contents["at"], msg.Contents["ptr"])
+		assert.Equal(t, "<nil>", msg.Contents["nil"])
+		assert.NotContains(t, msg.Contents["now"], "m=")
+		assert.NotContains(t, msg.Contents, "at_ms")
+
+		c.TimeLayout = time.RFC1123
+		c.TimeMillisSuffix = "_ms"
+		msg = c.Message(entry)
+		assert.Equal(t, at.Format(time.RFC1123), msg.Contents["at"])
+		assert.Equal(t, "1577905445006", msg.Contents["at_ms"])
+		assert.Equal(t, "1577905445006", msg.Contents["ptr_ms"])
+		assert.NotContains(t, msg.Contents, "nil_ms")
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		c := NewConverter("m", "l", SyslogLevelMapping, nil, nil)
+		var nilMap map[string]int
+		entry := &logrus.Entry{
+			Data:    logrus.Fields{"boom": panicStringer{}, "nil": nilMap, "ok": "v"},
+			Message: "content",
+		}
+		var msg Message
+		assert.NotPanics(t, func() { msg = c.Message(entry) })
+		assert.Contains(t, msg.Contents["boom"], "PANIC=")
+		assert.Equal(t, "map[]", msg.Contents["nil"])
+		assert.Equal(t, "v", msg.Contents["ok"])
+		assert.Equal(t, "content", msg.Contents["m"])
+	})
+
 	t.Run("modifier", func(t *testing.T) {
 		const levelKey = "level"
 
@@ -60,4 +118,55 @@ func TestConverter(t *testing.T) {
 		msg := c.Message(entry)
 		assert.Equal(t, "INFO", msg.Contents[levelKey])
 	})
+	t.Run("level extra", func(t *testing.T) {
+		c := NewConverter("message", "level", SyslogLevelMapping, map[string]string{"alert": "false"}, nil)
+		c.LevelExtra = map[logrus.Level]map[string]string{
+			logrus.ErrorLevel: {"alert": "true"},
+		}
+
+		msg := c.Message(&logrus.Entry{Level: logrus.ErrorLevel, Data: logrus.Fields{}})
+		assert.Equal(t, "true", msg.Contents["alert"])
+
+		msg = c.Message(&logrus.Entry{Level: logrus.InfoLevel, Data: logrus.Fields{}})
+		assert.Equal(t, "false", msg.Contents["alert"])
+	})
+	t.Run("severity", func(t *testing.T) {
+		c := NewConverter("message", "level", SyslogLevelMapping, nil, nil)
+		c.SeverityKey = DefaultSeverityKey
+
+		msg := c.Message(&logrus.Entry{Level: logrus.WarnLevel, Data: logrus.Fields{}})
+		assert.Equal(t, "WARN", msg.Contents[DefaultSeverityKey])
+		assert.Equal(t, "4", msg.Contents["level"])
+
+		assert.Equal(t, "FATAL", Severity(logrus.PanicLevel))
+		assert.Equal(t, "TRACE", Severity(logrus.TraceLevel))
+		assert.Equal(t, "UNKNOWN", Severity(logrus.TraceLevel+1))
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		extra := map[string]string{"env": "prod"}
+		c := NewConverter("message", "level", SyslogLevelMapping, extra, nil)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					msg := c.Message(&logrus.Entry{Level: logrus.InfoLevel, Data: logrus.Fields{"i": j}})
+					assert.Equal(t, "prod", msg.Contents["env"])
+				}
+			}()
+		}
+		// 构造后修改原 map 不影响 converter, go test -race 下不应报告数据竞争
+		for j := 0; j < 1000; j++ {
+			extra["env"] = strconv.Itoa(j)
+		}
+		wg.Wait()
+	})
 }
+
+// panicStringer 的 String 方法以自身为值 panic, fmt 格式化 panic 值时再次 panic, 无法由 fmt 自行恢复
+type panicStringer struct{}
+
+func (panicStringer) String() string { panic(panicStringer{}) }
@@ -0,0 +1,74 @@
+package slsh
+
+import (
+	"sync"
+	"time"
+)
+
+// 访问凭证
+type Credentials struct {
+	AccessKey     string
+	AccessSecret  Secret
+	SecurityToken string // STS 临时凭证 token, 可选
+}
+
+// 凭证提供者, 每次发送请求前调用, 实现方需自行缓存
+type CredentialsProvider interface {
+	Credentials() (Credentials, error)
+}
+
+// 支持主动刷新的凭证提供者, Hook.Reset 时调用
+type Refresher interface {
+	Refresh() error
+}
+
+type staticCredentials Credentials
+
+func (s staticCredentials) Credentials() (Credentials, error) { return Credentials(s), nil }
+
+// StaticCredentials 返回固定密钥对的凭证提供者
+func StaticCredentials(accessKey string, accessSecret Secret) CredentialsProvider {
+	return staticCredentials{AccessKey: accessKey, AccessSecret: accessSecret}
+}
+
+// 提前刷新时间, 避免临时凭证在请求途中过期
+const credentialsRefreshAhead = 5 * time.Minute
+
+// refreshingCredentials 缓存凭证并在过期前重新获取, 获取失败时在有效期内继续使用旧凭证
+type refreshingCredentials struct {
+	mu      sync.Mutex
+	fetch   func() (Credentials, time.Time, error)
+	creds   Credentials
+	expires time.Time
+}
+
+func (r *refreshingCredentials) Credentials() (Credentials, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Now().Add(credentialsRefreshAhead).Before(r.expires) {
+		return r.creds, nil
+	}
+	if err := r.refreshLocked(); err != nil {
+		if time.Now().Before(r.expires) {
+			return r.creds, nil
+		}
+		return Credentials{}, err
+	}
+	return r.creds, nil
+}
+
+func (r *refreshingCredentials) Refresh() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.refreshLocked()
+}
+
+func (r *refreshingCredentials) refreshLocked() error {
+	creds, expires, err := r.fetch()
+	if err != nil {
+		return err
+	}
+	r.creds, r.expires = creds, expires
+	return nil
+}
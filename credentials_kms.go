@@ -0,0 +1,72 @@
+package slsh
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/validator"
+)
+
+const DefaultKMSRefreshInterval = time.Hour
+
+// KMS 凭证配置
+type KMSConfig struct {
+	Endpoint        string              // KMS 接入地址, 例如: "kms-vpc.cn-hangzhou.aliyuncs.com"
+	SecretName      string              // 凭据名称
+	AccessKey       string              // 凭据内容为纯文本 secret 时对应的 AccessKey, RAM 凭据可不填
+	Credentials     CredentialsProvider // 访问 KMS 使用的凭证, 例如 RRSA 或 AssumeRole 凭证
+	RefreshInterval time.Duration       // 重新读取凭据的间隔, 可选, 默认为 1h, 须大于 5m, 配合凭据轮转使用
+	HttpClient      *http.Client        // HTTP 客户端, 可选, 默认为 DefaultClient
+	Timeout         time.Duration       // 单次读取凭据的超时, 可选, 默认为 DefaultRPCTimeout
+}
+
+// NewKMSCredentials 返回从 KMS 凭据管理服务读取 AccessSecret 的凭证提供者, 配置文件中无需保存明文密钥.
+// 凭据内容可以是纯文本 secret, 也可以是 RAM 凭据格式的 JSON: {"AccessKeyId": "...", "AccessKeySecret": "..."}
+func NewKMSCredentials(c KMSConfig) (CredentialsProvider, error) {
+	if err := validator.All(
+		validator.Required("Endpoint", c.Endpoint),
+		validator.Required("SecretName", c.SecretName),
+	); err != nil {
+		return nil, err
+	}
+	if c.Credentials == nil {
+		return nil, validator.IllegalArgument("Credentials", "is required")
+	}
+	c.RefreshInterval = validator.CoalesceDur(c.RefreshInterval, DefaultKMSRefreshInterval)
+	// 凭证在到期前 credentialsRefreshAhead 即会刷新, 间隔过短时每次请求都会读取 KMS
+	if c.RefreshInterval <= credentialsRefreshAhead {
+		return nil, validator.IllegalArgument("RefreshInterval", "must be greater than 5m")
+	}
+
+	rpc := &rpcClient{endpoint: c.Endpoint, version: "2016-01-20", client: c.HttpClient, timeout: c.Timeout}
+	provider := &refreshingCredentials{fetch: func() (Credentials, time.Time, error) {
+		creds, err := c.Credentials.Credentials()
+		if err != nil {
+			return Credentials{}, time.Time{}, err
+		}
+
+		var resp struct {
+			SecretData string
+		}
+		if err := rpc.call(context.Background(), "GetSecretValue", map[string]string{"SecretName": c.SecretName}, &creds, &resp); err != nil {
+			return Credentials{}, time.Time{}, err
+		}
+
+		result := Credentials{AccessKey: c.AccessKey, AccessSecret: Secret(resp.SecretData)}
+		var ram struct {
+			AccessKeyId     string
+			AccessKeySecret string
+		}
+		if json.Unmarshal([]byte(resp.SecretData), &ram) == nil && ram.AccessKeySecret != "" {
+			result = Credentials{AccessKey: ram.AccessKeyId, AccessSecret: Secret(ram.AccessKeySecret)}
+		}
+		return result, time.Now().Add(c.RefreshInterval), nil
+	}}
+
+	if err := provider.Refresh(); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
@@ -0,0 +1,155 @@
+package slsh
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/validator"
+)
+
+const (
+	DefaultSTSEndpoint     = "sts.aliyuncs.com"
+	DefaultSTSDuration     = time.Hour
+	DefaultRoleSessionName = "slsh"
+)
+
+// STS 接口返回的临时凭证
+type stsResponse struct {
+	Credentials struct {
+		AccessKeyId     string
+		AccessKeySecret string
+		SecurityToken   string
+		Expiration      string
+	}
+}
+
+func (r stsResponse) credentials() (Credentials, time.Time, error) {
+	expires, err := time.Parse(time.RFC3339, r.Credentials.Expiration)
+	if err != nil {
+		return Credentials{}, time.Time{}, err
+	}
+	return Credentials{
+		AccessKey:     r.Credentials.AccessKeyId,
+		AccessSecret:  Secret(r.Credentials.AccessKeySecret),
+		SecurityToken: r.Credentials.SecurityToken,
+	}, expires, nil
+}
+
+// RRSA (RAM Roles for Service Accounts) 凭证配置, 未填写的字段从 ACK 注入的环境变量读取
+type RRSAConfig struct {
+	RoleArn         string        // 可选, 默认读取 ALIBABA_CLOUD_ROLE_ARN
+	OIDCProviderArn string        // 可选, 默认读取 ALIBABA_CLOUD_OIDC_PROVIDER_ARN
+	OIDCTokenFile   string        // 可选, 默认读取 ALIBABA_CLOUD_OIDC_TOKEN_FILE
+	RoleSessionName string        // 可选, 默认为 "slsh"
+	Duration        time.Duration // 临时凭证有效期, 可选, 默认为 1h
+	Endpoint        string        // STS 接入地址, 可选, 默认为 "sts.aliyuncs.com"
+	HttpClient      *http.Client  // HTTP 客户端, 可选, 默认为 DefaultClient
+	Timeout         time.Duration // 单次换取凭证的超时, 可选, 默认为 DefaultRPCTimeout
+}
+
+// NewRRSACredentials 返回基于 ACK RRSA 的凭证提供者: 读取挂载的 OIDC token,
+// 调用 STS AssumeRoleWithOIDC 换取临时凭证并在过期前自动刷新, 无需在部署中保存任何密钥
+func NewRRSACredentials(c RRSAConfig) (CredentialsProvider, error) {
+	c.RoleArn = validator.CoalesceStr(c.RoleArn, os.Getenv("ALIBABA_CLOUD_ROLE_ARN"))
+	c.OIDCProviderArn = validator.CoalesceStr(c.OIDCProviderArn, os.Getenv("ALIBABA_CLOUD_OIDC_PROVIDER_ARN"))
+	c.OIDCTokenFile = validator.CoalesceStr(c.OIDCTokenFile, os.Getenv("ALIBABA_CLOUD_OIDC_TOKEN_FILE"))
+	if err := validator.All(
+		validator.Required("RoleArn", c.RoleArn),
+		validator.Required("OIDCProviderArn", c.OIDCProviderArn),
+		validator.Required("OIDCTokenFile", c.OIDCTokenFile),
+	); err != nil {
+		return nil, err
+	}
+	c.RoleSessionName = validator.CoalesceStr(c.RoleSessionName, DefaultRoleSessionName)
+	c.Duration = validator.CoalesceDur(c.Duration, DefaultSTSDuration)
+	c.Endpoint = validator.CoalesceStr(c.Endpoint, DefaultSTSEndpoint)
+
+	rpc := &rpcClient{endpoint: c.Endpoint, version: "2015-04-01", client: c.HttpClient, timeout: c.Timeout}
+	provider := &refreshingCredentials{fetch: func() (Credentials, time.Time, error) {
+		// token 由 kubelet 定期轮换, 每次刷新时重新读取
+		token, err := ioutil.ReadFile(c.OIDCTokenFile)
+		if err != nil {
+			return Credentials{}, time.Time{}, err
+		}
+
+		var resp stsResponse
+		if err := rpc.call(context.Background(), "AssumeRoleWithOIDC", map[string]string{
+			"RoleArn":         c.RoleArn,
+			"OIDCProviderArn": c.OIDCProviderArn,
+			"OIDCToken":       strings.TrimSpace(string(token)),
+			"RoleSessionName": c.RoleSessionName,
+			"DurationSeconds": strconv.Itoa(int(c.Duration / time.Second)),
+		}, nil, &resp); err != nil {
+			return Credentials{}, time.Time{}, err
+		}
+		return resp.credentials()
+	}}
+
+	if err := provider.Refresh(); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// AssumeRole 凭证配置
+type AssumeRoleConfig struct {
+	RoleArn         string              // 目标角色, 例如: "acs:ram::<account-b>:role/log-writer"
+	RoleSessionName string              // 可选, 默认为 "slsh"
+	ExternalId      string              // 角色信任策略要求的外部 ID, 可选
+	Policy          string              // 进一步限制权限的策略, 可选
+	Duration        time.Duration       // 临时凭证有效期, 可选, 默认为 1h
+	Credentials     CredentialsProvider // 扮演角色使用的凭证
+	Endpoint        string              // STS 接入地址, 可选, 默认为 "sts.aliyuncs.com"
+	HttpClient      *http.Client        // HTTP 客户端, 可选, 默认为 DefaultClient
+	Timeout         time.Duration       // 单次扮演角色的超时, 可选, 默认为 DefaultRPCTimeout
+}
+
+// NewAssumeRoleCredentials 返回通过 STS AssumeRole 获取临时凭证的提供者, 并在过期前自动刷新,
+// 适用于将账号 A 中应用的日志写入账号 B 的集中日志项目
+func NewAssumeRoleCredentials(c AssumeRoleConfig) (CredentialsProvider, error) {
+	if err := validator.Required("RoleArn", c.RoleArn); err != nil {
+		return nil, err
+	}
+	if c.Credentials == nil {
+		return nil, validator.IllegalArgument("Credentials", "is required")
+	}
+	c.RoleSessionName = validator.CoalesceStr(c.RoleSessionName, DefaultRoleSessionName)
+	c.Duration = validator.CoalesceDur(c.Duration, DefaultSTSDuration)
+	c.Endpoint = validator.CoalesceStr(c.Endpoint, DefaultSTSEndpoint)
+
+	rpc := &rpcClient{endpoint: c.Endpoint, version: "2015-04-01", client: c.HttpClient, timeout: c.Timeout}
+	provider := &refreshingCredentials{fetch: func() (Credentials, time.Time, error) {
+		creds, err := c.Credentials.Credentials()
+		if err != nil {
+			return Credentials{}, time.Time{}, err
+		}
+
+		params := map[string]string{
+			"RoleArn":         c.RoleArn,
+			"RoleSessionName": c.RoleSessionName,
+			"DurationSeconds": strconv.Itoa(int(c.Duration / time.Second)),
+		}
+		if c.ExternalId != "" {
+			params["ExternalId"] = c.ExternalId
+		}
+		if c.Policy != "" {
+			params["Policy"] = c.Policy
+		}
+
+		var resp stsResponse
+		if err := rpc.call(context.Background(), "AssumeRole", params, &creds, &resp); err != nil {
+			return Credentials{}, time.Time{}, err
+		}
+		return resp.credentials()
+	}}
+
+	if err := provider.Refresh(); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
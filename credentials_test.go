@@ -0,0 +1,253 @@
+package slsh
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRPCSignature(t *testing.T) {
+	// 示例来自阿里云 RPC 签名文档
+	query := url.Values{
+		"AccessKeyId":      {"testid"},
+		"Action":           {"DescribeRegions"},
+		"Format":           {"XML"},
+		"SignatureMethod":  {"HMAC-SHA1"},
+		"SignatureNonce":   {"3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf"},
+		"SignatureVersion": {"1.0"},
+		"Timestamp":        {"2016-02-23T12:46:24Z"},
+		"Version":          {"2014-05-26"},
+	}
+
+	sig, err := rpcSignature(Secret("testsecret"), http.MethodGet, query)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "OLeaidS1JvxuMvnyHOwuJ+uX5qY=", sig)
+	}
+}
+
+func TestRefreshingCredentials(t *testing.T) {
+	calls := 0
+	var fail error
+	expires := time.Now().Add(time.Hour)
+	r := &refreshingCredentials{fetch: func() (Credentials, time.Time, error) {
+		calls++
+		return Credentials{AccessKey: "key"}, expires, fail
+	}}
+
+	creds, err := r.Credentials()
+	assert.NoError(t, err)
+	assert.Equal(t, "key", creds.AccessKey)
+
+	_, _ = r.Credentials()
+	assert.Equal(t, 1, calls)
+
+	// 临近过期且刷新失败时, 在有效期内继续使用旧凭证
+	r.expires = time.Now().Add(time.Minute)
+	fail = errors.New("unavailable")
+	creds, err = r.Credentials()
+	assert.NoError(t, err)
+	assert.Equal(t, "key", creds.AccessKey)
+
+	r.expires = time.Now().Add(-time.Minute)
+	_, err = r.Credentials()
+	assert.Error(t, err)
+}
+
+func TestKMSCredentials(t *testing.T) {
+	newClient := func(t *testing.T, secretData string) (*http.Client, func()) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			q := req.URL.Query()
+			assert.Equal(t, "GetSecretValue", q.Get("Action"))
+			assert.Equal(t, "slsh-secret", q.Get("SecretName"))
+			assert.Equal(t, "bootstrap", q.Get("AccessKeyId"))
+			assert.NotEmpty(t, q.Get("Signature"))
+			_, _ = w.Write([]byte(`{"SecretData":` + secretData + `}`))
+		}))
+		target, _ := url.Parse(srv.URL)
+		client := &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+			return http.DefaultTransport.RoundTrip(req)
+		})}
+		return client, srv.Close
+	}
+
+	t.Run("plain secret", func(t *testing.T) {
+		client, done := newClient(t, `"plain"`)
+		defer done()
+
+		provider, err := NewKMSCredentials(KMSConfig{
+			Endpoint:    "kms.cn-hangzhou.aliyuncs.com",
+			SecretName:  "slsh-secret",
+			AccessKey:   "key",
+			Credentials: StaticCredentials("bootstrap", Secret("bootstrap")),
+			HttpClient:  client,
+		})
+		if assert.NoError(t, err) {
+			creds, err := provider.Credentials()
+			assert.NoError(t, err)
+			assert.Equal(t, Credentials{AccessKey: "key", AccessSecret: Secret("plain")}, creds)
+		}
+	})
+
+	t.Run("ram credentials", func(t *testing.T) {
+		client, done := newClient(t, `"{\"AccessKeyId\":\"rotated\",\"AccessKeySecret\":\"s3cr3t\"}"`)
+		defer done()
+
+		provider, err := NewKMSCredentials(KMSConfig{
+			Endpoint:    "kms.cn-hangzhou.aliyuncs.com",
+			SecretName:  "slsh-secret",
+			Credentials: StaticCredentials("bootstrap", Secret("bootstrap")),
+			HttpClient:  client,
+		})
+		if assert.NoError(t, err) {
+			creds, err := provider.Credentials()
+			assert.NoError(t, err)
+			assert.Equal(t, Credentials{AccessKey: "rotated", AccessSecret: Secret("s3cr3t")}, creds)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			select {
+			case <-release:
+			case <-req.Context().Done():
+			}
+		}))
+		defer srv.Close()
+		defer close(release)
+		target, _ := url.Parse(srv.URL)
+		client := &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+			return http.DefaultTransport.RoundTrip(req)
+		})}
+
+		start := time.Now()
+		_, err := NewKMSCredentials(KMSConfig{
+			Endpoint:    "kms.cn-hangzhou.aliyuncs.com",
+			SecretName:  "slsh-secret",
+			Credentials: StaticCredentials("bootstrap", Secret("bootstrap")),
+			HttpClient:  client,
+			Timeout:     50 * time.Millisecond,
+		})
+		assert.Error(t, err)
+		assert.True(t, time.Since(start) < time.Second, time.Since(start))
+	})
+
+	t.Run("required", func(t *testing.T) {
+		_, err := NewKMSCredentials(KMSConfig{Endpoint: "kms.cn-hangzhou.aliyuncs.com", SecretName: "any"})
+		assert.Error(t, err)
+	})
+
+	t.Run("refresh interval", func(t *testing.T) {
+		_, err := NewKMSCredentials(KMSConfig{
+			Endpoint:        "kms.cn-hangzhou.aliyuncs.com",
+			SecretName:      "any",
+			Credentials:     StaticCredentials("bootstrap", Secret("bootstrap")),
+			RefreshInterval: credentialsRefreshAhead,
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestRRSACredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rrsa")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	tokenFile := filepath.Join(dir, "token")
+	if !assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("oidc-token\n"), 0600)) {
+		return
+	}
+
+	expiration := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		assert.Equal(t, "AssumeRoleWithOIDC", q.Get("Action"))
+		assert.Equal(t, "acs:ram::1:role/slsh", q.Get("RoleArn"))
+		assert.Equal(t, "acs:ram::1:oidc-provider/ack", q.Get("OIDCProviderArn"))
+		assert.Equal(t, "oidc-token", q.Get("OIDCToken"))
+		assert.Equal(t, "3600", q.Get("DurationSeconds"))
+		assert.Empty(t, q.Get("Signature"))
+		_, _ = w.Write([]byte(`{"Credentials":{"AccessKeyId":"STS.id","AccessKeySecret":"secret",` +
+			`"SecurityToken":"token","Expiration":"` + expiration + `"}}`))
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	client := &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+
+	provider, err := NewRRSACredentials(RRSAConfig{
+		RoleArn:         "acs:ram::1:role/slsh",
+		OIDCProviderArn: "acs:ram::1:oidc-provider/ack",
+		OIDCTokenFile:   tokenFile,
+		HttpClient:      client,
+	})
+	if assert.NoError(t, err) {
+		creds, err := provider.Credentials()
+		assert.NoError(t, err)
+		assert.Equal(t, Credentials{AccessKey: "STS.id", AccessSecret: Secret("secret"), SecurityToken: "token"}, creds)
+	}
+
+	_, err = NewRRSACredentials(RRSAConfig{OIDCTokenFile: tokenFile})
+	assert.Error(t, err)
+}
+
+func TestAssumeRoleCredentials(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		assert.Equal(t, "AssumeRole", q.Get("Action"))
+		assert.Equal(t, "acs:ram::2:role/log-writer", q.Get("RoleArn"))
+		assert.Equal(t, "ext", q.Get("ExternalId"))
+		assert.Equal(t, "app", q.Get("AccessKeyId"))
+		assert.NotEmpty(t, q.Get("Signature"))
+		if q.Get("RoleSessionName") == "denied" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"Code":"NoPermission","Message":"denied","RequestId":"1"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"Credentials":{"AccessKeyId":"STS.id","AccessKeySecret":"secret",` +
+			`"SecurityToken":"token","Expiration":"` + expiration + `"}}`))
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	client := &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+
+	c := AssumeRoleConfig{
+		RoleArn:     "acs:ram::2:role/log-writer",
+		ExternalId:  "ext",
+		Credentials: StaticCredentials("app", Secret("app")),
+		HttpClient:  client,
+	}
+	provider, err := NewAssumeRoleCredentials(c)
+	if assert.NoError(t, err) {
+		creds, err := provider.Credentials()
+		assert.NoError(t, err)
+		assert.Equal(t, "token", creds.SecurityToken)
+	}
+
+	c.RoleSessionName = "denied"
+	_, err = NewAssumeRoleCredentials(c)
+	var aErr *AliyunError
+	if assert.True(t, errors.As(err, &aErr)) {
+		assert.Equal(t, "NoPermission", aErr.Code)
+	}
+}
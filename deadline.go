@@ -0,0 +1,27 @@
+package slsh
+
+import (
+	"context"
+	"time"
+)
+
+// nearDeadline 判断 ctx 是否已结束, 或剩余时间不足 margin
+func nearDeadline(ctx context.Context, margin time.Duration) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && time.Until(deadline) < margin
+}
+
+// entryPush 在设置 DeadlineMargin 且 entry 带有 Context 时, 使写缓存的等待不超过 entry 的截止时间,
+// 临近截止时改为非阻塞写入, 避免日志调用拖长请求耗时. Service 不支持非阻塞写入时 (例如审计模式) 仅限制等待时间
+func (h *Hook) entryPush(parent context.Context, push func(context.Context, Message) error) (context.Context, func(context.Context, Message) error) {
+	if h.margin <= 0 || parent == nil {
+		return context.Background(), push
+	}
+	if tp, ok := h.service.(TryPusher); ok && nearDeadline(parent, h.margin) {
+		push = func(_ context.Context, m Message) error { return tp.TryPush(m) }
+	}
+	return parent, push
+}
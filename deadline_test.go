@@ -0,0 +1,61 @@
+package slsh
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNearDeadline(t *testing.T) {
+	assert.False(t, nearDeadline(context.Background(), time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	assert.False(t, nearDeadline(ctx, time.Second))
+	assert.True(t, nearDeadline(ctx, 2*time.Hour))
+	cancel()
+	assert.True(t, nearDeadline(ctx, 0))
+}
+
+func TestHookDeadlineMargin(t *testing.T) {
+	chStarted, chGate := make(chan struct{}), make(chan struct{})
+	flushed := 0
+	service := NewService(1, time.Hour, func(messages ...Message) error {
+		if flushed == 0 {
+			close(chStarted)
+			<-chGate
+		}
+		flushed += len(messages)
+		return nil
+	})
+	service.MinGap = 0
+	converter := NewConverter(DefaultMessageKey, DefaultLevelKey, SyslogLevelMapping, nil, nil)
+	hook := NewCustom(time.Hour, DefaultVisibleLevels, converter, &MockWriter{}, service)
+	hook.margin = 50 * time.Millisecond
+	var drops []string
+	hook.lifecycle.OnDrop = func(reason string, count int) { drops = append(drops, reason) }
+
+	entry := &logrus.Entry{Level: logrus.InfoLevel, Data: logrus.Fields{}}
+	assert.NoError(t, hook.Fire(entry))
+	<-chStarted
+	assert.NoError(t, hook.Fire(entry))
+
+	t.Run("near", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, ErrQueueFull, hook.Fire(entry.WithContext(ctx)))
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, hook.Fire(entry.WithContext(ctx)))
+	})
+
+	assert.Equal(t, []string{DropQueueFull, DropTimeout}, drops)
+	close(chGate)
+	assert.NoError(t, hook.Close())
+	assert.Equal(t, 2, flushed)
+}
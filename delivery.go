@@ -0,0 +1,63 @@
+package slsh
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+)
+
+// 传输错误类型
+type DeliveryKind string
+
+const (
+	DeliveryDNS               DeliveryKind = "dns"                // 域名解析失败
+	DeliveryTLS               DeliveryKind = "tls"                // 证书校验或 TLS 握手失败, 通常需要修改配置, 不重试
+	DeliveryTimeout           DeliveryKind = "timeout"            // 连接或响应超时
+	DeliveryConnectionRefused DeliveryKind = "connection_refused" // 连接被拒绝
+	DeliveryNetwork           DeliveryKind = "network"            // 其他网络错误, 例如连接被重置
+)
+
+// DeliveryError 包装未收到响应的传输层错误, 可通过 errors.As 获取并按 Kind 区分处理
+type DeliveryError struct {
+	Kind DeliveryKind
+	Err  error
+}
+
+func (e *DeliveryError) Error() string {
+	return "delivery failed (" + string(e.Kind) + "): " + e.Err.Error()
+}
+
+func (e *DeliveryError) Unwrap() error { return e.Err }
+
+// Retryable 返回重试能否解决该错误, TLS 错误重试无效
+func (e *DeliveryError) Retryable() bool { return e.Kind != DeliveryTLS }
+
+// classifyDelivery 判断 http.Client 返回的错误类型
+func classifyDelivery(err error) DeliveryKind {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return DeliveryDNS
+	}
+
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		recordHeader     tls.RecordHeaderError
+	)
+	if errors.As(err, &unknownAuthority) || errors.As(err, &hostname) ||
+		errors.As(err, &invalid) || errors.As(err, &recordHeader) {
+		return DeliveryTLS
+	}
+
+	var nErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &nErr) && nErr.Timeout()) {
+		return DeliveryTimeout
+	}
+	if connectionRefused(err) {
+		return DeliveryConnectionRefused
+	}
+	return DeliveryNetwork
+}
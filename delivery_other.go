@@ -0,0 +1,7 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package slsh
+
+// 其他平台没有 ECONNREFUSED, 连接被拒绝归为普通网络错误
+func connectionRefused(err error) bool { return false }
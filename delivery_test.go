@@ -0,0 +1,71 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryError(t *testing.T) {
+	send := func(t *testing.T, rawurl string, client *http.Client) *DeliveryError {
+		u, _ := url.Parse(rawurl)
+		metrics := &MockMetrics{}
+		w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, client,
+			WithMetrics(metrics))
+
+		var dErr *DeliveryError
+		if !assert.True(t, errors.As(w.WriteMessage(ShortMessage), &dErr)) {
+			t.FailNow()
+		}
+		assert.Equal(t, int64(1), metrics.counters[MetricDeliveryErrorPrefix+string(dErr.Kind)])
+		return dErr
+	}
+
+	t.Run("tls", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+		defer srv.Close()
+
+		dErr := send(t, srv.URL, &http.Client{})
+		assert.Equal(t, DeliveryTLS, dErr.Kind)
+		assert.False(t, retryable(dErr))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		chDone := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { <-chDone }))
+		defer srv.Close()
+		defer close(chDone)
+
+		dErr := send(t, srv.URL, &http.Client{Timeout: 10 * time.Millisecond})
+		assert.Equal(t, DeliveryTimeout, dErr.Kind)
+		assert.True(t, retryable(dErr))
+	})
+
+	t.Run("connection refused", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if !assert.NoError(t, err) {
+			return
+		}
+		addr := l.Addr().String()
+		_ = l.Close()
+
+		dErr := send(t, "http://"+addr, &http.Client{})
+		assert.Equal(t, DeliveryConnectionRefused, dErr.Kind)
+		assert.True(t, retryable(dErr))
+	})
+
+	t.Run("classify", func(t *testing.T) {
+		assert.Equal(t, DeliveryDNS, classifyDelivery(&url.Error{Op: "Post", Err: &net.OpError{
+			Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "example.invalid"}}}))
+		assert.Equal(t, DeliveryTimeout, classifyDelivery(fmt.Errorf("send: %w", context.DeadlineExceeded)))
+		assert.Equal(t, DeliveryNetwork, classifyDelivery(errors.New("connection reset")))
+	})
+}
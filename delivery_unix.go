@@ -0,0 +1,11 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows
+// +build darwin dragonfly freebsd linux netbsd openbsd windows
+
+package slsh
+
+import (
+	"errors"
+	"syscall"
+)
+
+func connectionRefused(err error) bool { return errors.Is(err, syscall.ECONNREFUSED) }
@@ -0,0 +1,116 @@
+package slsh
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+)
+
+// DryRunTransport 返回试运行的 http.RoundTripper: 请求照常编码, 压缩与签名, 但不发送,
+// 而是将每个请求的摘要写入 out 并返回成功, verbose 为 true 时同时输出解码后的每条日志.
+// 用于在预发环境安全地核对配置与日志内容
+func DryRunTransport(out io.Writer, verbose bool) http.RoundTripper {
+	return &dryRun{out: out, verbose: verbose}
+}
+
+// WithDryRun 使用 DryRunTransport 替换 HTTP 客户端, 不向日志服务发送任何请求
+func WithDryRun(out io.Writer, verbose bool) WriterOption {
+	return func(w *writer) { w.client = &http.Client{Transport: DryRunTransport(out, verbose)} }
+}
+
+type dryRun struct {
+	mu      sync.Mutex
+	out     io.Writer
+	verbose bool
+}
+
+func (d *dryRun) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "slsh dry-run: %s %s signed=%t", req.Method, req.URL, strings.HasPrefix(req.Header.Get("Authorization"), "LOG "))
+	if req.Method == http.MethodPost {
+		d.describe(&b, req.Header, body)
+	}
+	b.WriteByte('\n')
+
+	d.mu.Lock()
+	_, err := d.out.Write(b.Bytes())
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"X-Log-Requestid": {"dry-run"}},
+		Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		Request:    req,
+	}, nil
+}
+
+// describe 解码 PutLogs 请求体并写入摘要, 无法解码 (例如自定义压缩类型) 时只输出长度
+func (d *dryRun) describe(b *bytes.Buffer, header http.Header, body []byte) {
+	compressType := header.Get("X-Log-Compresstype")
+	fmt.Fprintf(b, " body=%dB raw=%sB compress=%q", len(body), header.Get("X-Log-Bodyrawsize"), compressType)
+
+	raw := body
+	switch compressType {
+	case "":
+	case CompressTypeLZ4:
+		var err error
+		if raw, err = uncompressLZ4(body, header.Get("X-Log-Bodyrawsize")); err != nil {
+			fmt.Fprintf(b, " decode_error=%q", err.Error())
+			return
+		}
+	default:
+		return
+	}
+
+	group := &api.LogGroup{}
+	if err := proto.Unmarshal(raw, group); err != nil {
+		fmt.Fprintf(b, " decode_error=%q", err.Error())
+		return
+	}
+	fmt.Fprintf(b, " topic=%q source=%q logs=%d", group.GetTopic(), group.GetSource(), len(group.Logs))
+	for _, tag := range group.LogTags {
+		fmt.Fprintf(b, " tag:%s=%q", tag.GetKey(), tag.GetValue())
+	}
+	if !d.verbose {
+		return
+	}
+
+	for _, log := range group.Logs {
+		contents := make(map[string]string, len(log.Contents))
+		for _, content := range log.Contents {
+			contents[content.GetKey()] = content.GetValue()
+		}
+		data, _ := json.Marshal(contents)
+		b.WriteString("\n  ")
+		b.WriteString(time.Unix(int64(log.GetTime()), 0).UTC().Format(time.RFC3339))
+		b.WriteByte(' ')
+		b.Write(data)
+	}
+}
@@ -0,0 +1,43 @@
+package slsh
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun(t *testing.T) {
+	u, _ := url.Parse("http://project.example.com/logstores/store/shards/lb")
+
+	t.Run("verbose", func(t *testing.T) {
+		var out bytes.Buffer
+		w := NewWriter(u, "demo", "host", DefaultAccessKey, DefaultAccessSecret, nil, WithDryRun(&out, true), WithVersionTag())
+		assert.NoError(t, w.WriteMessage(ShortMessage))
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if assert.Len(t, lines, 2) {
+			assert.Contains(t, lines[0], "slsh dry-run: POST http://project.example.com/logstores/store/shards/lb signed=true")
+			assert.Contains(t, lines[0], `compress="lz4"`)
+			assert.Contains(t, lines[0], `topic="demo" source="host" logs=1`)
+			assert.Contains(t, lines[0], "tag:"+VersionTagKey+"=")
+			assert.Equal(t, `  2020-01-01T00:00:00Z {"key":"value"}`, lines[1])
+		}
+
+		out.Reset()
+		assert.NoError(t, w.Ping(context.TODO()))
+		assert.True(t, strings.HasPrefix(out.String(), "slsh dry-run: GET "))
+	})
+
+	t.Run("custom compressor", func(t *testing.T) {
+		var out bytes.Buffer
+		w := NewWriter(u, "demo", "host", DefaultAccessKey, DefaultAccessSecret, nil,
+			WithDryRun(&out, true), WithCompressor(identityCompressor{}))
+		assert.NoError(t, w.WriteMessage(ShortMessage))
+		assert.Contains(t, out.String(), `compress="identity"`)
+		assert.NotContains(t, out.String(), "logs=")
+	})
+}
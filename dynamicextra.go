@@ -0,0 +1,40 @@
+package slsh
+
+import "expvar"
+
+// DynamicExtra 是按发送批次计算的附加字段, 每次发送前各函数调用一次, 结果附加到批次内的每条日志,
+// 不覆盖日志中已有的同名字段. 用于记录发送时的主机状态 (队列长度, 请求速率等), 便于事后关联分析,
+// 函数需并发安全且快速返回
+type DynamicExtra map[string]func() string
+
+// ExpvarExtra 返回读取 expvar 变量的函数, 变量不存在时返回空字符串
+func ExpvarExtra(name string) func() string {
+	return func() string {
+		if v := expvar.Get(name); v != nil {
+			return v.String()
+		}
+		return ""
+	}
+}
+
+func (d DynamicExtra) wrap(flush func(...Message) error) func(...Message) error {
+	return func(messages ...Message) error {
+		values := make(map[string]string, len(d))
+		for k, f := range d {
+			values[k] = f()
+		}
+
+		out := make([]Message, len(messages))
+		for i, m := range messages {
+			// 不覆盖日志中已有的同名字段
+			extra := make(map[string]string, len(values))
+			for k, v := range values {
+				if _, ok := m.Contents[k]; !ok {
+					extra[k] = v
+				}
+			}
+			out[i] = withContents(m, extra)
+		}
+		return flush(out...)
+	}
+}
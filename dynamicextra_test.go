@@ -0,0 +1,37 @@
+package slsh
+
+import (
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// expvar 变量不能重复注册, 在包级别创建以支持 -count
+var testQueueDepth = expvar.NewInt("slsh_test_queue_depth")
+
+func TestDynamicExtra(t *testing.T) {
+	calls := 0
+	testQueueDepth.Set(42)
+
+	extra := DynamicExtra{
+		"batch":   func() string { calls++; return "b1" },
+		"depth":   ExpvarExtra("slsh_test_queue_depth"),
+		"missing": ExpvarExtra("slsh_test_missing"),
+	}
+
+	var flushed []Message
+	flush := extra.wrap(func(messages ...Message) error { flushed = messages; return nil })
+
+	original := Message{Time: time.Now(), Contents: map[string]string{"batch": "own"}}
+	assert.NoError(t, flush(original, Message{Contents: map[string]string{}}))
+
+	assert.Equal(t, 1, calls)
+	if assert.Len(t, flushed, 2) {
+		assert.Equal(t, map[string]string{"batch": "own", "depth": "42", "missing": ""}, flushed[0].Contents)
+		assert.Equal(t, original.Time, flushed[0].Time)
+		assert.Equal(t, "b1", flushed[1].Contents["batch"])
+	}
+	assert.Equal(t, map[string]string{"batch": "own"}, original.Contents)
+}
@@ -0,0 +1,166 @@
+package slsh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 告警日志的字段
+const (
+	AlertKey         = "slsh_alert"   // 告警类型
+	AlertErrorBudget = "error_budget" // 发送成功率低于 ErrorBudget.Threshold
+)
+
+const (
+	DefaultBudgetWindow   = 5 * time.Minute
+	DefaultBudgetMinCount = 100
+	budgetBuckets         = 10
+)
+
+// ErrorBudget 统计滑动窗口内日志的发送成功率 (发送成功 / (发送成功 + 丢弃)), 低于阈值时发出 Error 级别的告警日志,
+// 告警同时写入 SLS (优先发送, 远端不可用时可能失败) 与 Fallback, 避免日志在无人察觉时持续丢失.
+// 同一窗口内最多告警一次, 审计模式下不生效
+type ErrorBudget struct {
+	Window    time.Duration // 统计窗口, 可选, 默认为 5 分钟
+	Threshold float64       // 最低成功率, 例如 0.99
+	MinCount  int           // 窗口内至少处理的日志条数, 不足时不判断, 可选, 默认为 100
+	Fallback  io.Writer     // 告警日志同时以 JSON 写入的位置, 可选, 默认为标准错误
+}
+
+type budgetBucket struct {
+	slot            int64
+	delivered, lost int
+}
+
+type errorBudget struct {
+	ErrorBudget
+	mu         sync.Mutex
+	clock      Clock
+	buckets    [budgetBuckets]budgetBucket
+	alerted    time.Time
+	messageKey string
+	levelKey   string
+	level      string
+	push       func(Message) // 将告警写入 SLS, 在发送协程启动后设置, 需持有锁访问
+}
+
+func newErrorBudget(b *ErrorBudget, messageKey, levelKey string, mapping LevelMapping, clock Clock) *errorBudget {
+	e := &errorBudget{
+		ErrorBudget: *b,
+		clock:       clock,
+		messageKey:  messageKey,
+		levelKey:    levelKey,
+		level:       strconv.Itoa(mapping(logrus.ErrorLevel)),
+	}
+	if e.Window < budgetBuckets {
+		e.Window = DefaultBudgetWindow
+	}
+	if e.MinCount <= 0 {
+		e.MinCount = DefaultBudgetMinCount
+	}
+	if e.Fallback == nil {
+		e.Fallback = os.Stderr
+	}
+	return e
+}
+
+// wrap 在 OnFlush 与 OnDrop 中统计发送结果, 保留原有回调
+func (e *errorBudget) wrap(l Lifecycle) Lifecycle {
+	onFlush, onDrop := l.OnFlush, l.OnDrop
+	l.OnFlush = func(count int, elapsed time.Duration, err error) {
+		if err == nil {
+			e.record(count, 0)
+		}
+		if onFlush != nil {
+			onFlush(count, elapsed, err)
+		}
+	}
+	l.OnDrop = func(reason string, count int) {
+		e.record(0, count)
+		if onDrop != nil {
+			onDrop(reason, count)
+		}
+	}
+	return l
+}
+
+func (e *errorBudget) record(delivered, lost int) {
+	now := e.clock.Now()
+	slot := now.UnixNano() / int64(e.Window/budgetBuckets)
+
+	e.mu.Lock()
+	b := &e.buckets[slot%budgetBuckets]
+	if b.slot != slot {
+		*b = budgetBucket{slot: slot}
+	}
+	b.delivered += delivered
+	b.lost += lost
+
+	var total, ok int
+	for _, b := range e.buckets {
+		if slot-b.slot < budgetBuckets {
+			total += b.delivered + b.lost
+			ok += b.delivered
+		}
+	}
+	rate := float64(ok) / float64(total)
+	alert := total >= e.MinCount && rate < e.Threshold && now.Sub(e.alerted) >= e.Window
+	if alert {
+		e.alerted = now
+	}
+	push := e.push
+	e.mu.Unlock()
+
+	if alert {
+		e.alert(e.message(now, rate, ok, total-ok), push)
+	}
+}
+
+func (e *errorBudget) setPush(push func(Message)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.push = push
+}
+
+func (e *errorBudget) message(now time.Time, rate float64, delivered, lost int) Message {
+	return Message{Time: now, Contents: map[string]string{
+		e.messageKey: fmt.Sprintf("slsh: delivery success rate %.2f%% below %.2f%% in the last %s, %d of %d logs lost",
+			rate*100, e.Threshold*100, e.Window, lost, delivered+lost),
+		e.levelKey:          e.level,
+		AlertKey:            AlertErrorBudget,
+		"slsh_success_rate": strconv.FormatFloat(rate, 'f', 4, 64),
+		"slsh_delivered":    strconv.Itoa(delivered),
+		"slsh_lost":         strconv.Itoa(lost),
+	}}
+}
+
+// alert 将告警写入 Fallback, 并在后台写入 SLS, 在发送协程中调用时不能阻塞
+func (e *errorBudget) alert(message Message, push func(Message)) {
+	if b, err := json.Marshal(message.Contents); err == nil {
+		_, _ = e.Fallback.Write(append(b, '\n'))
+	}
+	if push != nil {
+		go push(message)
+	}
+}
+
+// pushAlert 以优先通道写入告警日志, 不经过采样与处理链
+func (h *Hook) pushAlert(message Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	push := h.service.Push
+	if p, ok := h.service.(PriorityPusher); ok {
+		push = p.PushPriority
+	}
+	if err := push(ctx, message); err != nil {
+		h.errors.record(err, 1)
+	}
+}
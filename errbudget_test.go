@@ -0,0 +1,75 @@
+package slsh
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorBudget(t *testing.T) {
+	newBudget := func(clock *fixedClock) (*errorBudget, *bytes.Buffer, chan Message) {
+		var fallback bytes.Buffer
+		alerts := make(chan Message, 10)
+		b := newErrorBudget(&ErrorBudget{Window: time.Minute, Threshold: 0.9, MinCount: 10, Fallback: &fallback},
+			DefaultMessageKey, DefaultLevelKey, SyslogLevelMapping, clock)
+		b.setPush(func(m Message) { alerts <- m })
+		return b, &fallback, alerts
+	}
+
+	t.Run("alert", func(t *testing.T) {
+		clock := &fixedClock{now: time.Unix(1577836800, 0)}
+		b, fallback, alerts := newBudget(clock)
+		var flushed, dropped int
+		l := b.wrap(Lifecycle{
+			OnFlush: func(count int, _ time.Duration, _ error) { flushed += count },
+			OnDrop:  func(_ string, count int) { dropped += count },
+		})
+
+		l.flush(8, 0, nil)
+		l.flush(5, 0, errors.New("spilled"))
+		l.drop(DropFailed, 1)
+		assert.Zero(t, fallback.Len(), "9 of 9 is below MinCount, 8 of 9 above threshold")
+
+		l.drop(DropFailed, 1)
+		assert.Equal(t, 13, flushed)
+		assert.Equal(t, 2, dropped)
+
+		var contents map[string]string
+		if assert.NoError(t, json.Unmarshal(fallback.Bytes(), &contents)) {
+			assert.Equal(t, AlertErrorBudget, contents[AlertKey])
+			assert.Equal(t, "3", contents[DefaultLevelKey])
+			assert.Equal(t, "0.8000", contents["slsh_success_rate"])
+			assert.Equal(t, "2", contents["slsh_lost"])
+			assert.Contains(t, contents[DefaultMessageKey], "2 of 10 logs lost")
+		}
+		select {
+		case m := <-alerts:
+			assert.Equal(t, AlertErrorBudget, m.Contents[AlertKey])
+			assert.Equal(t, clock.now, m.Time)
+		case <-time.After(time.Second):
+			t.Fatal("alert not pushed")
+		}
+
+		// 同一窗口内不重复告警
+		l.drop(DropFailed, 10)
+		assert.Equal(t, 1, bytes.Count(fallback.Bytes(), []byte("\n")))
+	})
+
+	t.Run("window", func(t *testing.T) {
+		clock := &fixedClock{now: time.Unix(1577836800, 0)}
+		b, fallback, _ := newBudget(clock)
+
+		b.record(0, 5)
+		clock.now = clock.now.Add(2 * time.Minute)
+		b.record(20, 1)
+		assert.Zero(t, fallback.Len(), "earlier losses slid out of the window")
+
+		clock.now = clock.now.Add(30 * time.Second)
+		b.record(0, 4)
+		assert.Equal(t, 1, bytes.Count(fallback.Bytes(), []byte("\n")))
+	})
+}
@@ -0,0 +1,166 @@
+package slsh
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/validator"
+)
+
+// 对象存储上传接口, 返回对象的访问地址
+type Uploader interface {
+	Upload(key string, data []byte) (url string, err error)
+}
+
+// Externalizer 将超长字段值上传至对象存储, 原字段替换为访问地址, 并附加 "<key>_sha1" 摘要字段.
+// 通过 Config.Externalize 启用, 上传在发送协程中按批次执行, 不阻塞 Fire, 审计模式下不生效
+type Externalizer struct {
+	Uploader  Uploader
+	Threshold int      // 字段值超过该字节数时上传, 为 0 时不上传
+	Keys      []string // 需要检查的字段, 为空时检查所有字段
+}
+
+func (e *Externalizer) validate() error {
+	if e.Uploader == nil {
+		return validator.IllegalArgument("Externalize", "Uploader is required")
+	}
+	if e.Threshold < 0 {
+		return validator.IllegalArgument("Externalize", "Threshold must not be negative")
+	}
+	return nil
+}
+
+func (e *Externalizer) wrap(flush func(...Message) error) func(...Message) error {
+	if e.Threshold <= 0 {
+		return flush
+	}
+	return func(messages ...Message) error {
+		out := make([]Message, len(messages))
+		for i, m := range messages {
+			out[i] = m
+			var extra map[string]string
+			for _, k := range e.keys(m.Contents) {
+				v, ok := m.Contents[k]
+				if !ok || len(v) <= e.Threshold {
+					continue
+				}
+				if extra == nil {
+					extra = make(map[string]string, 2)
+				}
+				e.externalize(extra, k, v)
+			}
+			if extra != nil {
+				out[i] = withContents(m, extra)
+			}
+		}
+		return flush(out...)
+	}
+}
+
+// keys 返回需要检查的字段
+func (e *Externalizer) keys(contents map[string]string) []string {
+	if len(e.Keys) > 0 {
+		return e.Keys
+	}
+	keys := make([]string, 0, len(contents))
+	for k := range contents {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (e *Externalizer) externalize(extra map[string]string, key, value string) {
+	sum := sha1.Sum([]byte(value))
+	digest := hex.EncodeToString(sum[:])
+
+	uri, err := e.Uploader.Upload(digest, []byte(value))
+	if err != nil {
+		extra[key+"_upload_error"] = err.Error()
+		return
+	}
+	extra[key] = uri
+	extra[key+"_sha1"] = digest
+}
+
+// 阿里云对象存储上传实现, 使用 PutObject 接口
+type OSSUploader struct {
+	Endpoint    string // 接入地址, 例如: "oss-cn-hangzhou-internal.aliyuncs.com"
+	Bucket      string
+	Prefix      string // 对象名前缀, 可选
+	Credentials CredentialsProvider
+	HttpClient  *http.Client // HTTP 客户端, 可选, 默认为 DefaultClient
+}
+
+func (u *OSSUploader) Upload(key string, data []byte) (string, error) {
+	object := u.Prefix + key
+	uri := fmt.Sprintf("https://%s.%s/%s", u.Bucket, u.Endpoint, object)
+
+	req, err := http.NewRequest(http.MethodPut, uri, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	creds, err := u.Credentials.Credentials()
+	if err != nil {
+		return "", err
+	}
+
+	sum := md5.Sum(data)
+	req.Header.Set("Content-Md5", base64.StdEncoding.EncodeToString(sum[:]))
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("Date", gmtNow())
+	if creds.SecurityToken != "" {
+		req.Header.Set("X-Oss-Security-Token", creds.SecurityToken)
+	}
+
+	sign, err := ossSignature(creds.AccessSecret, req, "/"+u.Bucket+"/"+object)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("OSS %s:%s", creds.AccessKey, sign))
+
+	client := u.HttpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("oss: put object %q: %s: %s", object, resp.Status, body)
+	}
+	return uri, nil
+}
+
+func ossSignature(secret Secret, req *http.Request, resource string) (string, error) {
+	arr := []string{
+		req.Method,
+		req.Header.Get("Content-Md5"),
+		req.Header.Get("Content-Type"),
+		req.Header.Get("Date"),
+	}
+	if token := req.Header.Get("X-Oss-Security-Token"); token != "" {
+		arr = append(arr, "x-oss-security-token:"+token)
+	}
+	arr = append(arr, resource)
+
+	mac := hmac.New(sha1.New, secret)
+	if _, err := mac.Write([]byte(strings.Join(arr, "\n"))); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
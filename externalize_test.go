@@ -0,0 +1,114 @@
+package slsh
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExternalizer(t *testing.T) {
+	uploaded := map[string]string{}
+	uploader := UploaderFunc(func(key string, data []byte) (string, error) {
+		if string(data) == "fail" {
+			return "", errors.New("boom")
+		}
+		uploaded[key] = string(data)
+		return "https://bucket.example.com/" + key, nil
+	})
+
+	t.Run("upload", func(t *testing.T) {
+		e := &Externalizer{Uploader: uploader, Threshold: 3}
+		var flushed []Message
+		contents := map[string]string{"short": "abc", "body": "0123456789", "other": "fail"}
+		err := e.wrap(func(messages ...Message) error {
+			flushed = messages
+			return nil
+		})(Message{Contents: contents, Topic: "topic"})
+		assert.NoError(t, err)
+
+		// 原日志不被修改
+		assert.Equal(t, "0123456789", contents["body"])
+		if assert.Len(t, flushed, 1) {
+			m := flushed[0]
+			assert.Equal(t, "topic", m.Topic)
+			assert.Equal(t, "abc", m.Contents["short"])
+			assert.Len(t, m.Contents["body_sha1"], 40)
+			assert.Equal(t, "https://bucket.example.com/"+m.Contents["body_sha1"], m.Contents["body"])
+			assert.Equal(t, "0123456789", uploaded[m.Contents["body_sha1"]])
+			assert.Equal(t, "fail", m.Contents["other"])
+			assert.Equal(t, "boom", m.Contents["other_upload_error"])
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		e := &Externalizer{Uploader: UploaderFunc(func(key string, data []byte) (string, error) {
+			t.Error("unexpected upload")
+			return "", nil
+		})}
+		err := e.wrap(func(messages ...Message) error {
+			assert.Equal(t, "0123456789", messages[0].Contents["body"])
+			return nil
+		})(Message{Contents: map[string]string{"body": "0123456789"}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("config", func(t *testing.T) {
+		c := Config{Endpoint: "example.com", AccessKey: "key", AccessSecret: "secret", Project: "project",
+			Store: "store", Topic: "topic", Externalize: &Externalizer{Threshold: 1024}}
+		if err := c.validate(); assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "Uploader")
+		}
+
+		c.Externalize = &Externalizer{Uploader: uploader, Threshold: -1}
+		if err := c.validate(); assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "Threshold")
+		}
+	})
+}
+
+func TestOSSUploader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.MethodPut, req.Method)
+		assert.Equal(t, "/logs/abc", req.URL.Path)
+		assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"), "OSS key:"))
+		assert.NotEmpty(t, req.Header.Get("Content-Md5"))
+
+		data, err := ioutil.ReadAll(req.Body)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "payload", string(data))
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	u := &OSSUploader{
+		Endpoint:    "oss-cn-hangzhou.aliyuncs.com",
+		Bucket:      "bucket",
+		Prefix:      "logs/",
+		Credentials: StaticCredentials("key", Secret("secret")),
+		HttpClient: &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+			return http.DefaultTransport.RoundTrip(req)
+		})},
+	}
+
+	uri, err := u.Upload("abc", []byte("payload"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://bucket.oss-cn-hangzhou.aliyuncs.com/logs/abc", uri)
+	}
+}
+
+type UploaderFunc func(key string, data []byte) (string, error)
+
+func (f UploaderFunc) Upload(key string, data []byte) (string, error) { return f(key, data) }
+
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -0,0 +1,136 @@
+package slsh
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+)
+
+// parseTopics 解析主题列表, 支持逗号或空白分隔, 以及 []string 字段转换后的 "[a b]" 格式
+func parseTopics(value string) []string {
+	return strings.FieldsFunc(strings.Trim(value, "[]"), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// fanOut 按 TopicsKey 字段将日志复制到其中的各个主题, 副本共享同一 Contents, 发送时只编码一次.
+// 字段不存在或为空时保持原主题, 字段本身不发送
+func fanOut(topicsKey string, message Message) []Message {
+	value, ok := message.Contents[topicsKey]
+	if !ok {
+		return []Message{message}
+	}
+	delete(message.Contents, topicsKey)
+
+	topics := parseTopics(value)
+	if len(topics) == 0 {
+		return []Message{message}
+	}
+	out := make([]Message, len(topics))
+	for i, topic := range topics {
+		out[i] = message
+		out[i].Topic = topic
+	}
+	return out
+}
+
+// hasGroups 判断是否有日志指定了与 Writer 不同的主题或来源
+func (w *writer) hasGroups(messages []Message) bool {
+	for _, m := range messages {
+		if m.Topic != "" && m.Topic != w.topic || m.Source != "" && m.Source != w.source {
+			return true
+		}
+	}
+	return false
+}
+
+// writeTopics 按主题与来源分组发送, 每组一个日志组 (PutLogs 每次只接受一个日志组), 返回第一个错误.
+// Contents 与时间相同的日志 (扇出的副本) 只编码一次, 各日志组复制编码结果
+func (w *writer) writeTopics(ctx context.Context, opts RequestOptions, messages []Message) error {
+	type ref struct {
+		contents uintptr
+		time     int64
+	}
+	type extent struct{ start, end int }
+
+	now := time.Now()
+	var arena []byte
+	var keys []string
+	encoded := make(map[ref]extent, len(messages))
+	extents := make([]extent, len(messages))
+	type groupKey struct{ topic, source string }
+	var order []groupKey
+	groups := make(map[groupKey][]int)
+	for i, m := range messages {
+		r := ref{contents: reflect.ValueOf(m.Contents).Pointer(), time: m.Time.UnixNano()}
+		e, ok := encoded[r]
+		if !ok {
+			if w.fields.enabled() {
+				m = w.fields.limit(m)
+			}
+			if w.clamp.enabled() {
+				m = w.clamp.clamp(now, m)
+			}
+			e.start = len(arena)
+			arena, keys = appendLog(arena, keys, m)
+			e.end = len(arena)
+			encoded[r] = e
+		}
+		extents[i] = e
+
+		k := groupKey{topic: m.Topic, source: m.Source}
+		if k.topic == "" {
+			k.topic = w.topic
+		}
+		if k.source == "" {
+			k.source = w.source
+		}
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], i)
+	}
+
+	var pending []Message
+	var first error
+	for _, k := range order {
+		indexes := groups[k]
+		group := make([]Message, len(indexes))
+		for j, i := range indexes {
+			group[j] = messages[i]
+		}
+		static := w.groupStatic(k.topic, k.source)
+		err := w.writeGroup(ctx, opts, group, func(b []byte) []byte {
+			for _, i := range indexes {
+				b = append(b, arena[extents[i].start:extents[i].end]...)
+			}
+			return append(b, static...)
+		})
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			pending = append(pending, group...)
+		}
+	}
+	// 部分分组已发送时只返回未发送的日志, 避免落盘或二分时重复发送
+	if first != nil && len(pending) < len(messages) {
+		return &partialError{pending: pending, err: first}
+	}
+	return first
+}
+
+// groupStatic 返回指定主题与来源的日志组固定字段编码
+func (w *writer) groupStatic(topic, source string) []byte {
+	if topic == w.topic && source == w.source {
+		return w.static
+	}
+	static, _ := proto.Marshal(&api.LogGroup{Topic: &topic, Source: &source, LogTags: w.tags})
+	return static
+}
@@ -0,0 +1,163 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+)
+
+func TestParseTopics(t *testing.T) {
+	assert.Equal(t, []string{"audit", "app"}, parseTopics("audit,app"))
+	assert.Equal(t, []string{"audit", "app"}, parseTopics(" audit, app "))
+	assert.Equal(t, []string{"audit", "app"}, parseTopics("[audit app]"))
+	assert.Empty(t, parseTopics(" , "))
+}
+
+func TestFanOut(t *testing.T) {
+	message := Message{Time: time.Now(), Contents: map[string]string{"msg": "hi", "topics": "audit,app"}}
+	out := fanOut("topics", message)
+	if assert.Len(t, out, 2) {
+		assert.Equal(t, "audit", out[0].Topic)
+		assert.Equal(t, "app", out[1].Topic)
+		assert.Equal(t, map[string]string{"msg": "hi"}, out[0].Contents)
+		out[0].Contents["shared"] = "yes"
+		assert.Equal(t, "yes", out[1].Contents["shared"])
+	}
+
+	out = fanOut("topics", Message{Contents: map[string]string{"msg": "hi"}})
+	if assert.Len(t, out, 1) {
+		assert.Empty(t, out[0].Topic)
+	}
+}
+
+func TestWriterTopics(t *testing.T) {
+	var mu sync.Mutex
+	groups := make(map[string]*api.LogGroup)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, _ := ioutil.ReadAll(req.Body)
+		raw, err := uncompressLZ4(data, req.Header.Get("X-Log-Bodyrawsize"))
+		assert.NoError(t, err)
+		group := &api.LogGroup{}
+		assert.NoError(t, proto.Unmarshal(raw, group))
+		mu.Lock()
+		groups[group.GetTopic()] = group
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	writer := NewWriter(u, "app", DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient)
+	shared := Message{Time: time.Unix(1577836800, 0), Contents: map[string]string{"msg": "login", "user": "alice"}}
+	audit := shared
+	audit.Topic = "audit"
+	other := Message{Time: time.Unix(1577836801, 0), Contents: map[string]string{"msg": "hello"}}
+	assert.NoError(t, writer.WriteMessage(shared, audit, other))
+
+	var topics []string
+	for topic := range groups {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	assert.Equal(t, []string{"app", "audit"}, topics)
+	if assert.Len(t, groups["app"].Logs, 2) && assert.Len(t, groups["audit"].Logs, 1) {
+		assert.True(t, proto.Equal(groups["app"].Logs[0], groups["audit"].Logs[0]))
+		assert.Equal(t, DefaultSource, groups["audit"].GetSource())
+	}
+
+	t.Run("hook", func(t *testing.T) {
+		var pushed []Message
+		service := MockService{
+			onPush: func(_ context.Context, m Message) error {
+				pushed = append(pushed, m)
+				return nil
+			},
+			onStart: func() {},
+		}
+		converter := NewConverter(DefaultMessageKey, DefaultLevelKey, SyslogLevelMapping, nil, nil)
+		hook := NewCustom(time.Second, DefaultVisibleLevels, converter, &MockWriter{}, service)
+		hook.topicsKey = "topics"
+
+		entry := &logrus.Entry{Level: logrus.InfoLevel, Message: "login", Data: logrus.Fields{"topics": []string{"audit", "app"}}}
+		assert.NoError(t, hook.Fire(entry))
+		if assert.Len(t, pushed, 2) {
+			assert.Equal(t, "audit", pushed[0].Topic)
+			assert.Equal(t, "app", pushed[1].Topic)
+			assert.NotContains(t, pushed[0].Contents, "topics")
+		}
+	})
+}
+
+func TestWriterTopicsPartial(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, _ := ioutil.ReadAll(req.Body)
+		raw, err := uncompressLZ4(data, req.Header.Get("X-Log-Bodyrawsize"))
+		assert.NoError(t, err)
+		group := &api.LogGroup{}
+		assert.NoError(t, proto.Unmarshal(raw, group))
+		if group.GetTopic() == "audit" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	// 只有失败的分组作为未发送日志返回, 已发送的分组不会被落盘或二分重发
+	writer := NewWriter(u, "app", DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient)
+	app := Message{Time: time.Unix(1577836800, 0), Contents: map[string]string{"msg": "hello"}}
+	audit := Message{Time: time.Unix(1577836800, 0), Contents: map[string]string{"msg": "login"}, Topic: "audit"}
+	err := writer.WriteMessage(app, audit, app)
+	var pErr *partialError
+	if assert.True(t, errors.As(err, &pErr), err) {
+		assert.Equal(t, []Message{audit}, pErr.pending)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode(err))
+
+	t.Run("all failed", func(t *testing.T) {
+		err := writer.WriteMessage(audit)
+		assert.False(t, errors.As(err, &pErr))
+		assert.Error(t, err)
+	})
+}
+
+func TestWriterSources(t *testing.T) {
+	var mu sync.Mutex
+	var groups []*api.LogGroup
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, _ := ioutil.ReadAll(req.Body)
+		raw, err := uncompressLZ4(data, req.Header.Get("X-Log-Bodyrawsize"))
+		assert.NoError(t, err)
+		group := &api.LogGroup{}
+		assert.NoError(t, proto.Unmarshal(raw, group))
+		mu.Lock()
+		groups = append(groups, group)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	writer := NewWriter(u, "app", DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient)
+	local := Message{Time: time.Unix(1577836800, 0), Contents: map[string]string{"msg": "hello"}}
+	remote := Message{Time: time.Unix(1577836800, 0), Contents: map[string]string{"msg": "relayed"}, Source: "10.0.0.2"}
+	assert.NoError(t, writer.WriteMessage(local, remote, local))
+
+	if assert.Len(t, groups, 2) {
+		assert.Equal(t, DefaultSource, groups[0].GetSource())
+		assert.Len(t, groups[0].Logs, 2)
+		assert.Equal(t, "10.0.0.2", groups[1].GetSource())
+		assert.Equal(t, "app", groups[1].GetTopic())
+		assert.Len(t, groups[1].Logs, 1)
+	}
+}
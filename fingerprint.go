@@ -0,0 +1,48 @@
+package slsh
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/validator"
+)
+
+const FingerprintPrefix = "hmac:"
+
+// Fingerprinter 将高基数或敏感字段的值替换为 HMAC-SHA256 摘要 "hmac:<hex>", 既保留关联能力又避免泄露原值.
+// 未设置密钥时无法防止对低熵值 (手机号, 邮箱等) 的枚举还原, 此时字段值直接替换为 "REDACTED"
+type Fingerprinter struct {
+	Keys   []string // 需要替换的字段
+	Key    Secret   // HMAC 密钥, 必填, 应妥善保管并与日志分开存放
+	Length int      // 摘要保留的十六进制位数, 可选, 默认保留全部 64 位
+}
+
+// NewFingerprinter 返回使用 key 计算摘要的 Fingerprinter, key 不能为空
+func NewFingerprinter(key Secret, keys ...string) (*Fingerprinter, error) {
+	if err := validator.Required("Key", string(key)); err != nil {
+		return nil, err
+	}
+	return &Fingerprinter{Keys: keys, Key: key}, nil
+}
+
+func (f *Fingerprinter) Modify(contents map[string]string) {
+	for _, k := range f.Keys {
+		if v, ok := contents[k]; ok && v != "" {
+			contents[k] = f.Fingerprint(v)
+		}
+	}
+}
+
+func (f *Fingerprinter) Fingerprint(value string) string {
+	if len(f.Key) == 0 {
+		return "REDACTED"
+	}
+	mac := hmac.New(sha256.New, f.Key)
+	_, _ = mac.Write([]byte(value))
+	digest := hex.EncodeToString(mac.Sum(nil))
+	if f.Length > 0 && f.Length < len(digest) {
+		digest = digest[:f.Length]
+	}
+	return FingerprintPrefix + digest
+}
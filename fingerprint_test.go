@@ -0,0 +1,38 @@
+package slsh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprinter(t *testing.T) {
+	f, err := NewFingerprinter(Secret("key"), "email", "empty")
+	if !assert.NoError(t, err) {
+		return
+	}
+	f.Length = 8
+
+	contents := map[string]string{"email": "user@example.com", "empty": "", "path": "/users"}
+	ContentModifiers{f}.Modify(contents)
+
+	assert.True(t, strings.HasPrefix(contents["email"], FingerprintPrefix))
+	assert.Len(t, contents["email"], len(FingerprintPrefix)+8)
+	assert.Equal(t, f.Fingerprint("user@example.com"), contents["email"])
+	assert.Equal(t, "", contents["empty"])
+	assert.Equal(t, "/users", contents["path"])
+
+	other := &Fingerprinter{Key: Secret("other")}
+	assert.NotEqual(t, other.Fingerprint("user@example.com"), (&Fingerprinter{Key: Secret("key")}).Fingerprint("user@example.com"))
+	assert.Len(t, other.Fingerprint("user@example.com"), len(FingerprintPrefix)+64)
+
+	t.Run("no key", func(t *testing.T) {
+		_, err := NewFingerprinter(nil, "email")
+		assert.Error(t, err)
+
+		contents := map[string]string{"email": "user@example.com"}
+		(&Fingerprinter{Keys: []string{"email"}}).Modify(contents)
+		assert.Equal(t, "REDACTED", contents["email"])
+	})
+}
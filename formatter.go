@@ -0,0 +1,53 @@
+package slsh
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 日志服务保留字段, 以 logrus 字段设置时由 Converter 写入 Message 的 Time, Topic 与 Source, 不作为普通字段发送.
+// Consumer 拉取到的日志同样以 TopicKey 与 SourceKey 保存主题与来源, 与控制台查询结果一致
+const (
+	TimeKey   = "__time__"
+	TopicKey  = "__topic__"
+	SourceKey = "__source__"
+)
+
+// 将 Converter 的输出渲染为单行 JSON, 本地输出与阿里云日志接收到的内容保持一致
+type Formatter struct {
+	converter Converter
+}
+
+func NewFormatter(converter Converter) *Formatter {
+	return &Formatter{converter: converter}
+}
+
+func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	message := f.converter.Message(entry)
+
+	contents := make(map[string]string, len(message.Contents)+1)
+	for k, v := range message.Contents {
+		contents[k] = v
+	}
+	contents[TimeKey] = strconv.FormatInt(message.Time.Unix(), 10)
+	if message.Topic != "" {
+		contents[TopicKey] = message.Topic
+	}
+	if message.Source != "" {
+		contents[SourceKey] = message.Source
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(contents); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Formatter 返回与当前 Hook 共用 Converter 的 Formatter
+func (h *Hook) Formatter() *Formatter { return NewFormatter(h.converter) }
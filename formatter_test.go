@@ -0,0 +1,39 @@
+package slsh
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatter(t *testing.T) {
+	converter := NewConverter("message", "level", SyslogLevelMapping, map[string]string{"service": "demo"}, nil)
+
+	buf := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(buf)
+	logger.SetFormatter(NewFormatter(converter))
+
+	now := time.Now()
+	logger.WithTime(now).WithField("n", 1).Warn("<Hi>")
+
+	line := buf.String()
+	assert.Contains(t, line, "<Hi>")
+	assert.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("\n")))
+
+	var contents map[string]string
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &contents)) {
+		assert.Equal(t, map[string]string{
+			"message": "<Hi>",
+			"level":   strconv.Itoa(SyslogLevelMapping(logrus.WarnLevel)),
+			"service": "demo",
+			"n":       "1",
+			TimeKey:   strconv.FormatInt(now.Unix(), 10),
+		}, contents)
+	}
+}
@@ -3,11 +3,10 @@ module github.com/kyochou/go-logrus-aliyun-log-hook
 go 1.13
 
 require (
-	github.com/aliyun/aliyun-log-go-sdk v0.1.5
-	github.com/frankban/quicktest v1.7.2 // indirect
+	github.com/frankban/quicktest v1.14.6 // indirect
 	github.com/golang/protobuf v1.3.2
 	github.com/pierrec/lz4 v2.4.0+incompatible
-	github.com/sirupsen/logrus v1.4.2
-	github.com/stretchr/testify v1.3.0
-	golang.org/x/net v0.0.0-20190827160401-ba9fcec4b297 // indirect
+	github.com/sirupsen/logrus v1.9.3
+	github.com/stretchr/testify v1.8.4
+	golang.org/x/sys v0.28.0 // indirect
 )
@@ -0,0 +1,88 @@
+package slsh
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"runtime/pprof"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 后台协程的 pprof 标签, CPU profile 中可按 component=slsh 区分日志发送 (含编码, 压缩) 的开销
+const (
+	LabelComponent = "component"
+	LabelRole      = "role"
+)
+
+func labels(role string) pprof.LabelSet {
+	return pprof.Labels(LabelComponent, "slsh", LabelRole, role)
+}
+
+// 以固定的函数名运行后台协程, 使其在 goroutine dump 中可识别, 并附加 pprof 标签
+
+func runSender(f func()) {
+	pprof.Do(context.Background(), labels("sender"), func(context.Context) { f() })
+}
+
+func runWarmUp(f func()) {
+	pprof.Do(context.Background(), labels("warmup"), func(context.Context) { f() })
+}
+
+func runMemoryWatch(f func()) {
+	pprof.Do(context.Background(), labels("memwatch"), func(context.Context) { f() })
+}
+
+func runRuntimeMetrics(f func()) {
+	pprof.Do(context.Background(), labels("runtime-metrics"), func(context.Context) { f() })
+}
+
+// 后台协程因 panic 退出后重启前的等待时间, 连续 panic 时加倍, 最长为 MaxRestartBackoff,
+// 协程稳定运行超过 MaxRestartBackoff 后恢复为 DefaultRestartBackoff
+const (
+	DefaultRestartBackoff = 100 * time.Millisecond
+	MaxRestartBackoff     = 30 * time.Second
+)
+
+// supervise 运行后台协程的主循环 f, f 因 panic 退出时恢复, 通过 onPanic 上报 (可为空) 并累加 MetricWorkerRestarts,
+// 退避后重新运行 f, 避免发送协程意外退出后日志静默停止. f 正常返回时结束
+func supervise(role string, metrics Metrics, onPanic func(*PanicError), f func()) {
+	if metrics == nil {
+		metrics = nopMetrics{}
+	}
+	backoff := DefaultRestartBackoff
+	for {
+		start := time.Now()
+		err := protect(f)
+		if err == nil {
+			return
+		}
+		if time.Since(start) > MaxRestartBackoff {
+			backoff = DefaultRestartBackoff
+		}
+
+		if logrus.IsLevelEnabled(logrus.DebugLevel) {
+			log.Printf("slsh: %s worker panic, restarting in %v: %s\n%s", role, backoff, err.Value, err.Stack)
+		}
+		metrics.Count(MetricWorkerRestarts, 1)
+		if onPanic != nil {
+			onPanic(err)
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > MaxRestartBackoff {
+			backoff = MaxRestartBackoff
+		}
+	}
+}
+
+// protect 运行 f 并恢复其中的 panic
+func protect(f func()) (err *PanicError) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: panicValue(r), Stack: debug.Stack()}
+		}
+	}()
+	f()
+	return nil
+}
@@ -0,0 +1,92 @@
+package slsh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime/pprof"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSenderLabels(t *testing.T) {
+	var buf bytes.Buffer
+	chDone := make(chan struct{})
+	s := NewService(1, time.Hour, func(messages ...Message) error {
+		defer close(chDone)
+		return pprof.Lookup("goroutine").WriteTo(&buf, 1)
+	})
+	hook := NewCustom(DefaultTimeout, DefaultVisibleLevels, &MockConverter{}, &MockWriter{}, s)
+	defer func() { _ = hook.Close() }()
+
+	assert.NoError(t, hook.Push(context.TODO(), Message{}))
+	<-chDone
+
+	assert.Contains(t, buf.String(), `"role":"sender"`)
+	assert.Contains(t, buf.String(), ".runSender")
+}
+
+func TestSupervise(t *testing.T) {
+	t.Run("restart sender", func(t *testing.T) {
+		metrics := &MockMetrics{}
+		flushed := make(chan Message, 1)
+		s := NewService(1, time.Hour, func(messages ...Message) error {
+			if messages[0].Contents["kill"] != "" {
+				panic("worker killed")
+			}
+			flushed <- messages[0]
+			return nil
+		})
+		s.Metrics = metrics
+		var mu sync.Mutex
+		var errs []error
+		var lost int
+		s.OnError = func(err error, count int) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+			lost += count
+		}
+		drops := 0
+		s.Lifecycle.OnDrop = func(reason string, count int) {
+			assert.Equal(t, DropPanic, reason)
+			drops += count
+		}
+		go s.Start()
+
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, s.Push(context.TODO(), Message{Contents: map[string]string{"kill": "1"}}))
+		}
+		assert.NoError(t, s.Push(context.TODO(), Message{Contents: map[string]string{"ok": "1"}}))
+		select {
+		case m := <-flushed:
+			assert.Equal(t, "1", m.Contents["ok"])
+		case <-time.After(5 * time.Second):
+			t.Fatal("sender was not restarted")
+		}
+		assert.NoError(t, s.Stop(context.TODO()))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if assert.Len(t, errs, 2) {
+			assert.Equal(t, "slsh: recovered from panic: worker killed", errs[0].Error())
+			assert.Contains(t, string(errs[0].(*PanicError).Stack), "TestSupervise")
+		}
+		assert.Equal(t, 2, lost)
+		assert.Equal(t, 2, drops)
+		assert.Equal(t, int64(2), metrics.counter(MetricWorkerRestarts))
+	})
+
+	t.Run("return", func(t *testing.T) {
+		runs := 0
+		supervise("test", nil, nil, func() {
+			if runs++; runs == 1 {
+				panic(errors.New("first run"))
+			}
+		})
+		assert.Equal(t, 2, runs)
+	})
+}
@@ -3,9 +3,13 @@ package slsh
 import (
 	"context"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -14,11 +18,14 @@ import (
 )
 
 const (
-	DefaultBufferSize = 100
-	DefaultMessageKey = "message"
-	DefaultLevelKey   = "level"
-	DefaultTimeout    = 500 * time.Millisecond
-	DefaultInterval   = 3 * time.Second
+	DefaultBufferSize    = 100
+	DefaultMessageKey    = "message"
+	DefaultLevelKey      = "level"
+	DefaultTimeout       = 500 * time.Millisecond
+	DefaultInterval      = 3 * time.Second
+	DefaultMinFlushGap   = 100 * time.Millisecond
+	DefaultWarmUpTimeout = 5 * time.Second
+	DefaultSeverityKey   = "__level__" // 建议的日志级别名称字段, 需通过 SeverityKey 显式开启
 )
 
 var (
@@ -45,31 +52,112 @@ type Config struct {
 	// 阿里云日志接入地址, 格式: "<region>.log.aliyuncs.com",
 	// 例如: "cn-hangzhou-intranet.log.aliyuncs.com",
 	// 更多接入点参考: https://help.aliyun.com/document_detail/29008.html?spm=a2c4g.11174283.6.1118.292a1caaVMpfPu
-	Endpoint        string
-	AccessKey       string            // 密钥对: key
-	AccessSecret    string            // 密钥对: secret
-	Project         string            // 日志项目名称
-	Store           string            // 日志库名称
-	Topic           string            // 日志 __topic__ 字段
-	Source          string            // 日志 __source__ 字段, 可选, 默认为 hostname
-	Extra           map[string]string // 日志附加字段, 可选
-	BufferSize      int               // 本地缓存日志条数, 可选, 默认为 100
-	Timeout         time.Duration     // 写缓存最大等待时间, 可选, 默认为 500ms
-	Interval        time.Duration     // 缓存刷新间隔, 可选, 默认为 3s
-	MessageKey      string            // 日志 Message 字段映射, 可选, 默认为 "message"
-	LevelKey        string            // 日志 Level 字段映射, 可选, 默认为 "level"
-	LevelMapping    LevelMapping      // 日志 Level 内容映射, 可选, 默认按照 syslog 规则映射
-	VisibleLevels   []logrus.Level    // 日志推送 Level, 可选, 默认推送 level >= info 的日志
-	HttpClient      *http.Client      // HTTP 客户端, 可选, 默认为 DefaultClient
-	ContentModifier ContentModifier   // 在发送前编辑日志内容, 可选, 默认为空
-	uri             *url.URL
+	Endpoint         string
+	AccessKey        string                             // 密钥对: key
+	AccessSecret     string                             // 密钥对: secret
+	Project          string                             // 日志项目名称
+	Store            string                             // 日志库名称
+	Topic            string                             // 日志 __topic__ 字段
+	TopicsKey        string                             // 主题列表字段, 可选, 日志带有该字段 (逗号分隔或 []string) 时复制发送到其中每个主题, 字段本身不发送, 例如 "topics"
+	Source           string                             // 日志 __source__ 字段, 可选, 默认为 hostname
+	Preset           Preset                             // 预设配置, 可选, 参考 PresetXXX, 仅填充未设置的字段
+	Extra            map[string]string                  // 日志附加字段, 可选
+	LevelExtra       map[logrus.Level]map[string]string // 按日志级别附加的字段, 可选, 例如 Error 级别附加 alert=true
+	DynamicExtra     DynamicExtra                       // 每个发送批次计算一次的附加字段, 可选, 审计模式下不生效
+	Types            *TypeRegistry                      // 记录字段值类型, 可选, 用于生成匹配的索引配置, 参考 TypeRegistry.IndexKeys
+	InternSize       int                                // 字段值驻留缓存容量, 可选, 默认不启用, 适合大量重复字段值的高频日志
+	BufferSize       int                                // 本地缓存日志条数, 可选, 默认为 100
+	Timeout          time.Duration                      // 写缓存最大等待时间, 可选, 默认为 500ms, 审计模式下为含重试的写入超时, 默认为 10s
+	Interval         time.Duration                      // 缓存刷新间隔, 可选, 默认为 3s
+	MinFlushGap      time.Duration                      // 两次发送请求的最小间隔, 可选, 默认为 100ms, 防止配置不当耗尽写入配额
+	IdleFlush        bool                               // 流量稀疏时立即发送新日志, 可选, 负载升高后恢复按间隔批量发送
+	AdaptiveThrottle bool                               // 收到写入配额限流错误时自动降低发送频率, 可选, 限流解除后逐步恢复
+	Quota            *Quota                             // 按字段值划分的缓存配额, 可选, 审计模式下不生效
+	Aggregate        *Aggregator                        // 在每个发送批次内合并重复的错误日志, 可选, 审计模式下不生效
+	PriorityLane     bool                               // 优先通道, 可选, 开启后 Error 及以上级别的日志在积压时优先发送
+	DeadlineMargin   time.Duration                      // entry 的 Context (logrus.WithContext) 已结束或剩余时间不足该值时只尝试写入缓存而不等待, 可选, 为 0 时忽略 entry 的 Context, 审计模式下仅以其截止时间限制同步写入
+	MessageKey       string                             // 日志 Message 字段映射, 可选, 默认为 "message"
+	LevelKey         string                             // 日志 Level 字段映射, 可选, 默认为 "level"
+	SeverityKey      string                             // 日志级别名称字段 (INFO, ERROR 等), 可选, 为空时不输出, 可设为 DefaultSeverityKey
+	TimeLayout       string                             // time.Time 字段值的格式, 可选, 默认为 time.RFC3339Nano
+	TimeMillisSuffix string                             // 设置后 time.Time 字段额外输出以该后缀命名的 Unix 毫秒时间戳字段, 便于数值范围查询, 例如 "_ms", 可选
+	LevelMapping     LevelMapping                       // 日志 Level 内容映射, 可选, 默认按照 syslog 规则映射
+	VisibleLevels    []logrus.Level                     // 日志推送 Level, 可选, 默认推送 level >= info 的日志
+	SampleRates      map[logrus.Level]float64           // 按级别采样比例, 可选, 默认全量推送
+	HttpClient       *http.Client                       // HTTP 客户端, 可选, 默认为 DefaultClient
+	DryRun           io.Writer                          // 试运行, 可选, 设置后照常编码, 压缩与签名但不发送请求, 将每个批次的摘要写入其中 (例如 os.Stdout), 忽略 HttpClient
+	DryRunVerbose    bool                               // 试运行时同时输出解码后的每条日志
+	HTTPS            bool                               // 使用 https 接入, 可选, 配合支持 HTTP/2 的客户端 (例如 DefaultClient) 时自动启用 HTTP/2
+	ExtraHeaders     map[string]string                  // 附加到每个请求的请求头, 可选, 匹配 SignPrefixes 的请求头参与签名
+	SignPrefixes     []string                           // 参与签名的请求头前缀, 可选, 默认为 X-Log-, X-Acs-, 用于 SLS 兼容网关
+	WarmUp           bool                               // 创建时在后台预先建立连接 (含 TLS 握手), 可选, 避免首次发送承担握手延迟
+	Credentials      CredentialsProvider                // 凭证提供者, 可选, 设置后忽略 AccessKey 与 AccessSecret
+	MaxInFlight      int                                // 最大并发请求数, 可选, 默认不限制
+	MaxAttempts      int                                // 单批次最大发送次数, 可选, 默认为 1 (不重试), 审计模式下与 AuditAttempts 叠加
+	RetryBackoff     time.Duration                      // 首次重试前的等待时间, 之后每次加倍, 可选, 默认为 100ms
+	MaxRetryElapsed  time.Duration                      // 单批次从首次发送起的最长重试时长, 超出后该批次落盘 (设置 SpillDir 时) 或丢弃, 可选, MaxAttempts 为 0 时不限次数只按时长停止重试
+	MaxResponseBody  int64                              // 响应内容最多读取的字节数, 可选, 默认为 64KB
+	TimeClamp        TimeClamp                          // 日志时间窗口, 可选, 超出窗口的时间戳被调整为边界值, 默认不调整
+	VersionTag       bool                               // 在日志组中附加 __client_version__ 标签, 可选
+	AttemptTags      bool                               // 重试成功的日志组附加 __attempt__ 与 __retry_delay_ms__ 标签, 可选, 参考 WithAttemptTags
+	Tags             map[string]string                  // 日志组标签, 可选, 每个请求只发送一次, 在 SLS 中以 __tag__:<key> 查询
+	ExtraAsTags      bool                               // 将 Extra 作为日志组标签发送而非附加到每条日志, 可选, 可显著减小载荷, 但查询时字段名变为 __tag__:<key>
+	Banner           bool                               // 创建后推送一条汇总生效配置的启动日志, 可选, 密钥已脱敏
+	MemoryLimitRatio float64                            // 进程内存用量达到 GOMEMLIMIT 的该比例时提前发送并释放缓冲区, 可选, 例如 0.9, 需 Go 1.19+
+	RuntimeMetrics   time.Duration                      // 定期采集运行时指标 (GC 停顿, 堆内存, 协程数) 并以日志发送的间隔, 可选, 为 0 时不采集
+	RuntimeTopic     string                             // 运行时指标日志的主题, 可选, 默认为 "runtime_metrics"
+	CompressionLevel int                                // lz4 压缩级别, 可选, 默认为 CompressionFastest
+	Compressor       Compressor                         // 自定义压缩实现, 可选, 设置后忽略 CompressionLevel
+	CompressionSkip  float64                            // 字段值中疑似已压缩内容 (base64, 二进制) 的字节占比达到该值时该批次不压缩, 可选, 例如 0.5, 为 0 时总是压缩
+	Dictionary       []byte                             // zstd 压缩字典, 可选, 可由 TrainDictionary 训练, 仅在接入点声明支持时使用, 参考 WithDictionary
+	DictEncoder      DictEncoder                        // 使用字典的 zstd 压缩实现, 设置 Dictionary 时必填
+	DictAcceptHeader string                             // 接入点声明支持的字典 ID 的响应头, 可选, 默认为 X-Slsh-Accept-Dict
+	DictHeader       string                             // 标注所用字典 ID 的请求头, 可选, 默认为 X-Slsh-Compress-Dict, 匹配 SignPrefixes 时参与签名
+	Metrics          Metrics                            // 指标上报, 可选, 默认为空
+	Tracer           Tracer                             // 发送链路追踪, 可选, 默认为空
+	SlowWrite        time.Duration                      // 单次写入 (含重试) 超过该耗时时记录慢日志, 包含编码, 压缩, 签名与网络各阶段耗时, 可选, 为 0 时不记录
+	OnSlowWrite      func(SlowWrite)                    // 慢日志回调, 可选, 默认输出到标准错误
+	Audit            bool                               // 审计模式, 可选, 开启后同步写入且不采样, Fire 在确认接收后才返回
+	AuditAttempts    int                                // 审计模式最大尝试次数, 可选, 默认为 3
+	SpillDir         string                             // 发送失败时的落盘目录, 可选, 默认不落盘
+	SpillKey         KeyFunc                            // 落盘加密密钥, 可选, 设置后使用 AES-GCM 加密落盘记录
+	SnapshotOnClose  bool                               // 关闭时若远端不可用, 将缓存中未发送的日志保存到落盘目录, 下次启动时恢复, 需设置 SpillDir
+	MaxAge           *MaxAge                            // 发送 (含落盘重放) 时丢弃过旧的日志, 可按级别设置, 可选, 审计模式下不生效
+	Shards           int                                // 并行写入实例数, 可选, 默认为 1, 各实例使用独立的连接池, 并发限制与重试, 按 ShardKey 拆分批次并行发送, 审计模式下不生效
+	ShardKey         string                             // 分片依据的字段, 可选, 与主题, 来源一起计算哈希, 值相同的日志由同一实例发送, 为空时按批次内顺序轮流分配
+	ErrorBudget      *ErrorBudget                       // 发送成功率低于阈值时发出告警日志, 可选, 审计模式下不生效
+	Bisect           bool                               // 批次因个别日志被拒绝 (HTTP 400, 错误码为 PostBodyInvalid 等) 时二分拆分重发, 隔离并丢弃有问题的日志 (以 RejectedError 回调 ErrorHandler), 其余照常发送
+	RecentErrors     int                                // 保留最近发送失败记录的条数, 可选, 默认为 10, 通过 Hook.RecentErrors 获取
+	ErrorHandler     func(ErrorRecord)                  // 发送失败回调, 可选, 在发送协程或 Fire 中同步调用, 不应阻塞
+	ContentModifier  ContentModifier                    // 在发送前编辑日志内容, 可选, 默认为空
+	Externalize      *Externalizer                      // 将超长字段值上传至对象存储, 可选, 在发送协程中执行, 审计模式下不生效
+	Transformers     []Transformer                      // 发送前按顺序执行的处理阶段, 可选, 在 ContentModifier 之后执行
+	SplitFields      int                                // 单条日志最多字段数, 可选, 超出时拆分为多条共享 split_id 的日志, 默认不拆分
+	IDGenerator      IDGenerator                        // split_id, __pack_id__ 前缀, 批次 ID 与日志 ID 的生成器, 可选, 默认为 RandomID, 参考 ULID, UUIDv7, Snowflake
+	PackID           bool                               // 为每个日志组附加 __pack_id__ 标签, 用于在控制台浏览上下文, 可选
+	BatchIDKey       string                             // 批次 ID 字段, 可选, 设置后同一发送批次的日志共享一个 ID, 审计模式下不生效
+	LogIDKey         string                             // 日志 ID 字段, 可选, 设置后每条 logrus 日志附加唯一 ID, 日志中已有该字段时不覆盖
+	MaxFields        int                                // 发送时单条日志最多保留的字段数, 可选, 超出的字段被丢弃并在 omitted_fields 中记录数量, 默认不限制
+	FieldPriority    []string                           // MaxFields 生效时在 MessageKey, LevelKey 之后优先保留的字段, 可选, 其余按字典序保留
+	Clock            Clock                              // 批量发送使用的时钟, 可选, 默认为 SystemClock, 测试中可使用 slshooktest.FakeClock
+	Lifecycle        Lifecycle                          // 生命周期回调 (启动, 发送, 丢弃, 关闭), 可选
+	SignatureDebug   func(signString string)            // 签名调试回调, 可选, 接收不含密钥与 STS token 的待签名字符串
+	uri              *url.URL
 }
 
 func (c *Config) validate() (err error) {
+	if c.Credentials == nil {
+		if err := validator.All(
+			validator.Required("AccessKey", c.AccessKey),
+			validator.Required("AccessSecret", c.AccessSecret),
+		); err != nil {
+			return err
+		}
+		c.Credentials = StaticCredentials(c.AccessKey, Secret(c.AccessSecret))
+	}
+
 	if err := validator.All(
 		validator.Required("Endpoint", c.Endpoint),
-		validator.Required("AccessKey", c.AccessKey),
-		validator.Required("AccessSecret", c.AccessSecret),
 		validator.Required("Project", c.Project),
 		validator.Required("Store", c.Store),
 		validator.Required("Topic", c.Topic),
@@ -77,13 +165,22 @@ func (c *Config) validate() (err error) {
 		return err
 	}
 
+	if err := c.Preset.apply(c); err != nil {
+		return err
+	}
+
 	source, _ := os.Hostname()
 	c.Source = validator.CoalesceStr(c.Source, source)
 	c.BufferSize = validator.CoalesceInt(c.BufferSize, DefaultBufferSize)
 	c.MessageKey = validator.CoalesceStr(c.MessageKey, DefaultMessageKey)
 	c.LevelKey = validator.CoalesceStr(c.LevelKey, DefaultLevelKey)
+	if c.Audit {
+		c.Timeout = validator.CoalesceDur(c.Timeout, DefaultAuditTimeout)
+		c.AuditAttempts = validator.CoalesceInt(c.AuditAttempts, DefaultAuditAttempts)
+	}
 	c.Timeout = validator.CoalesceDur(c.Timeout, DefaultTimeout)
 	c.Interval = validator.CoalesceDur(c.Interval, DefaultInterval)
+	c.MinFlushGap = validator.CoalesceDur(c.MinFlushGap, DefaultMinFlushGap)
 
 	if c.LevelMapping == nil {
 		c.LevelMapping = SyslogLevelMapping
@@ -93,12 +190,60 @@ func (c *Config) validate() (err error) {
 		c.VisibleLevels = DefaultVisibleLevels
 	}
 
+	if err := (Settings{MinLevel: logrus.TraceLevel, SampleRates: c.SampleRates}).validate(); err != nil {
+		return err
+	}
+
+	if c.Quota != nil {
+		if err := c.Quota.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Externalize != nil {
+		if err := c.Externalize.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.MemoryLimitRatio < 0 || c.MemoryLimitRatio > 1 {
+		return validator.IllegalArgument("MemoryLimitRatio", "must be in [0, 1]")
+	}
+	if c.CompressionSkip < 0 || c.CompressionSkip > 1 {
+		return validator.IllegalArgument("CompressionSkip", "must be in [0, 1]")
+	}
+	if c.MaxRetryElapsed < 0 {
+		return validator.IllegalArgument("MaxRetryElapsed", "must not be negative")
+	}
+	if len(c.Dictionary) > 0 && c.DictEncoder == nil {
+		return validator.IllegalArgument("DictEncoder", "is required when Dictionary is set")
+	}
+
+	if c.Shards < 0 {
+		return validator.IllegalArgument("Shards", "must not be negative")
+	}
+	if c.SnapshotOnClose && c.SpillDir == "" {
+		return validator.IllegalArgument("SnapshotOnClose", "requires SpillDir")
+	}
+
+	if c.RuntimeMetrics < 0 {
+		return validator.IllegalArgument("RuntimeMetrics", "must not be negative")
+	}
+	c.RuntimeTopic = validator.CoalesceStr(c.RuntimeTopic, DefaultRuntimeMetricsTopic)
+
 	if c.HttpClient == nil {
 		c.HttpClient = http.DefaultClient
 	}
 
+	if c.Metrics == nil {
+		c.Metrics = nopMetrics{}
+	}
+
+	scheme := "http"
+	if c.HTTPS {
+		scheme = "https"
+	}
 	c.uri, err = url.Parse(fmt.Sprintf(
-		"http://%s.%s/logstores/%s/shards/lb", c.Project, c.Endpoint, c.Store))
+		"%s://%s.%s/logstores/%s/shards/lb", scheme, c.Project, c.Endpoint, c.Store))
 	if err != nil {
 		return validator.IllegalArgument("Endpoint", err.Error())
 	}
@@ -107,48 +252,441 @@ func (c *Config) validate() (err error) {
 
 type Hook struct {
 	timeout       time.Duration
+	margin        time.Duration // 参考 Config.DeadlineMargin
 	visibleLevels []logrus.Level
 	writer        Writer
 	converter     Converter
 	service       Service
+	settings      atomic.Value
+	transformers  atomic.Value // Transformers
+	mu            sync.Mutex
+	audit         bool
+	priority      bool
+	errors        *errorRing
+	splitter      *Splitter
+	topicsKey     string
+	quota         *quotaLimiter
+	stop          context.CancelFunc // 停止内存检查, 运行时指标采集等后台协程
+	lifecycle     Lifecycle
+	closeOnce     sync.Once
 }
 
+// New 创建 Hook, 不访问网络, 接入点在首次发送时才解析, 需要在创建时验证连接与凭证时使用 NewConnected
 func New(c Config) (*Hook, error) {
 	if err := c.validate(); err != nil {
 		return nil, err
 	}
 
-	writer := NewWriter(c.uri, c.Topic, c.Source, c.AccessKey, Secret(c.AccessSecret), c.HttpClient)
-	service := NewService(c.BufferSize, c.Interval, writer.WriteMessage)
-	converter := NewConverter(c.MessageKey, c.LevelKey, c.LevelMapping, c.Extra, c.ContentModifier)
-	hook := NewCustom(c.Timeout, c.VisibleLevels, converter, writer, service)
+	maxAttempts := c.MaxAttempts
+	if maxAttempts == 0 && c.MaxRetryElapsed > 0 {
+		maxAttempts = math.MaxInt32
+	}
+	opts := []WriterOption{
+		WithCredentialsProvider(c.Credentials),
+		WithMaxInFlight(c.MaxInFlight),
+		WithRetry(maxAttempts, c.RetryBackoff),
+		WithMaxRetryElapsed(c.MaxRetryElapsed),
+		WithMaxResponseBody(c.MaxResponseBody),
+		WithTimeClamp(c.TimeClamp),
+		WithCompressionLevel(c.CompressionLevel),
+		WithMetrics(c.Metrics),
+		WithTracer(c.Tracer),
+		WithSignatureDebug(c.SignatureDebug),
+	}
+	if c.VersionTag {
+		opts = append(opts, WithVersionTag())
+	}
+	if c.AttemptTags {
+		opts = append(opts, WithAttemptTags())
+	}
+	if c.PackID {
+		opts = append(opts, WithPackID(c.IDGenerator))
+	}
+	if len(c.Tags) > 0 {
+		opts = append(opts, WithLogTags(c.Tags))
+	}
+	extra := c.Extra
+	if c.ExtraAsTags {
+		opts = append(opts, WithLogTags(c.Extra))
+		extra = nil
+	}
+	if c.Compressor != nil {
+		opts = append(opts, WithCompressor(c.Compressor))
+	}
+	if c.CompressionSkip > 0 {
+		opts = append(opts, WithCompressionSkip(c.CompressionSkip))
+	}
+	if len(c.Dictionary) > 0 {
+		opts = append(opts, WithDictionary(NewDictionary(c.Dictionary), c.DictEncoder),
+			WithDictionaryHeaders(c.DictAcceptHeader, c.DictHeader))
+	}
+	if c.DryRun != nil {
+		opts = append(opts, WithDryRun(c.DryRun, c.DryRunVerbose))
+	}
+	if c.SlowWrite > 0 {
+		opts = append(opts, WithSlowWrite(c.SlowWrite, c.OnSlowWrite))
+	}
+	if len(c.SignPrefixes) > 0 {
+		opts = append(opts, WithSignHeaderPrefixes(c.SignPrefixes...))
+	}
+	if len(c.ExtraHeaders) > 0 {
+		opts = append(opts, WithExtraHeaders(c.ExtraHeaders))
+	}
+	if c.MaxFields > 0 {
+		opts = append(opts, WithMaxFields(c.MaxFields, append([]string{c.MessageKey, c.LevelKey}, c.FieldPriority...)...))
+	}
+	writer := NewWriter(c.uri, c.Topic, c.Source, c.AccessKey, Secret(c.AccessSecret), c.HttpClient, opts...)
+	var hookWriter Writer = writer
+	if c.Shards > 1 && !c.Audit {
+		hookWriter = newShardedWriter(c.Shards, c.ShardKey, c.HttpClient, c.Metrics,
+			func(client *http.Client, metrics Metrics) Writer {
+				opts := append(opts[:len(opts):len(opts)], WithMetrics(metrics))
+				return NewWriter(c.uri, c.Topic, c.Source, c.AccessKey, Secret(c.AccessSecret), client, opts...)
+			})
+	}
+	converter := NewConverter(c.MessageKey, c.LevelKey, c.LevelMapping, extra, c.ContentModifier)
+	converter.LevelExtra = copyLevelExtra(c.LevelExtra)
+	converter.Types = c.Types
+	converter.TimeLayout = c.TimeLayout
+	converter.TimeMillisSuffix = c.TimeMillisSuffix
+	converter.LogIDKey = c.LogIDKey
+	converter.IDs = c.IDGenerator
+	converter.SeverityKey = c.SeverityKey
+	if c.InternSize > 0 {
+		converter.Interner = NewInterner(c.InternSize, c.Metrics)
+	}
+
+	if c.WarmUp {
+		go runWarmUp(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), DefaultWarmUpTimeout)
+			defer cancel()
+			_ = hookWriter.(Warmer).WarmUp(ctx)
+		})
+	}
+
+	var hook *Hook
+	errs := newErrorRing(c.RecentErrors, c.ErrorHandler)
+	var budget *errorBudget
+	if c.ErrorBudget != nil && !c.Audit {
+		clock := c.Clock
+		if clock == nil {
+			clock = SystemClock
+		}
+		budget = newErrorBudget(c.ErrorBudget, c.MessageKey, c.LevelKey, c.LevelMapping, clock)
+		c.Lifecycle = budget.wrap(c.Lifecycle)
+	}
+	if c.Audit {
+		service := syncService{writer: NewAuditWriter(writer, c.AuditAttempts, DefaultAuditBackoff)}
+		hook = NewCustom(c.Timeout, c.VisibleLevels, converter, writer, service)
+		hook.audit = true
+		c.Lifecycle.start()
+	} else {
+		flush := hookWriter.WriteMessage
+		if c.Bisect {
+			flush = (&bisector{report: errs.record, lifecycle: c.Lifecycle, metrics: c.Metrics}).wrap(flush)
+		}
+		if c.MaxAge != nil {
+			clock := c.Clock
+			if clock == nil {
+				clock = SystemClock
+			}
+			flush = newAgeLimiter(c.MaxAge, c.LevelKey, c.LevelMapping, clock, c.Lifecycle, c.Metrics).wrap(flush)
+		}
+		var spill *spill
+		if c.SpillDir != "" {
+			var err error
+			if spill, err = newSpill(c.SpillDir, c.SpillKey, c.Metrics); err != nil {
+				return nil, err
+			}
+			flush = spill.wrap(flush)
+		}
+		dynamic := c.DynamicExtra
+		if c.BatchIDKey != "" {
+			dynamic = make(DynamicExtra, len(c.DynamicExtra)+1)
+			for k, f := range c.DynamicExtra {
+				dynamic[k] = f
+			}
+			ids := c.IDGenerator
+			if ids == nil {
+				ids = RandomID
+			}
+			dynamic[c.BatchIDKey] = ids.NewID
+		}
+		if len(dynamic) > 0 {
+			flush = dynamic.wrap(flush)
+		}
+		if c.Externalize != nil {
+			flush = c.Externalize.wrap(flush)
+		}
+		if c.Aggregate != nil {
+			flush = newAggregator(c.Aggregate, c.MessageKey, c.LevelKey, c.LevelMapping).wrap(flush)
+		}
+		var quota *quotaLimiter
+		if c.Quota != nil {
+			quota = newQuotaLimiter(c.Quota, c.BufferSize)
+			flush = quota.wrap(flush)
+		}
+
+		service := NewService(c.BufferSize, c.Interval, flush)
+		service.MinGap = c.MinFlushGap
+		service.IdleFlush = c.IdleFlush
+		service.Adaptive = c.AdaptiveThrottle
+		service.OnError = errs.record
+		service.Lifecycle = c.Lifecycle
+		service.Metrics = c.Metrics
+		if c.SnapshotOnClose {
+			restored, err := spill.restore()
+			if err != nil {
+				return nil, err
+			}
+			service.Restore = restored
+			service.Snapshot = spill.snapshot
+		}
+		if c.Clock != nil {
+			service.Clock = c.Clock
+		}
+		hook = NewCustom(c.Timeout, c.VisibleLevels, converter, hookWriter, service)
+		hook.priority = c.PriorityLane
+		hook.quota = quota
+		hook.settings.Store(Settings{
+			MinLevel:    logrus.TraceLevel,
+			SampleRates: c.SampleRates,
+			BufferSize:  c.BufferSize,
+			Interval:    c.Interval,
+		})
+	}
+	hook.errors = errs
+	hook.lifecycle = c.Lifecycle
+	if budget != nil {
+		budget.setPush(hook.pushAlert)
+	}
+	hook.margin = c.DeadlineMargin
+	hook.Use(c.Transformers...)
+	hook.topicsKey = c.TopicsKey
+	if c.SplitFields > 0 {
+		hook.splitter = &Splitter{MaxFields: c.SplitFields, Keep: []string{c.MessageKey, c.LevelKey}, IDs: c.IDGenerator}
+	}
+
+	if c.MemoryLimitRatio > 0 || c.RuntimeMetrics > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		hook.stop = cancel
+		onPanic := func(err *PanicError) { errs.record(err, 0) }
+		if c.MemoryLimitRatio > 0 {
+			go runMemoryWatch(func() {
+				supervise("memwatch", c.Metrics, onPanic, func() { hook.watchMemory(ctx, c.MemoryLimitRatio) })
+			})
+		}
+		if c.RuntimeMetrics > 0 {
+			metricsWriter := NewWriter(c.uri, c.RuntimeTopic, c.Source, c.AccessKey, Secret(c.AccessSecret), c.HttpClient, opts...)
+			go runRuntimeMetrics(func() {
+				supervise("runtime-metrics", c.Metrics, onPanic, func() {
+					hook.collectRuntimeMetrics(ctx, metricsWriter, c.RuntimeMetrics)
+				})
+			})
+		}
+	}
+
+	if c.Banner {
+		ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+		if err := hook.Push(ctx, c.banner()); err != nil {
+			errs.record(err, 1)
+		}
+		cancel()
+	}
 	return hook, nil
 }
 
 func NewCustom(timeout time.Duration, visibleLevels []logrus.Level,
 	converter Converter, writer Writer, service Service) *Hook {
-	go service.Start()
+	go runSender(service.Start)
 
-	return &Hook{
+	hook := &Hook{
 		timeout:       timeout,
 		visibleLevels: visibleLevels,
 		writer:        writer,
 		converter:     converter,
 		service:       service,
+		errors:        newErrorRing(DefaultRecentErrors, nil),
+	}
+	hook.settings.Store(Settings{MinLevel: logrus.TraceLevel})
+	hook.transformers.Store(Transformers(nil))
+	return hook
+}
+
+// Fire 在返回前完成 entry 到 Message 的转换, 不持有 entry 及其字段, 兼容 logrus 对 entry 的复用
+func (h *Hook) Fire(entry *logrus.Entry) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = h.recovered(r)
+		}
+	}()
+
+	messages := h.messages(entry)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	push := h.service.Push
+	if p, ok := h.service.(PriorityPusher); ok && h.priority && entry.Level <= logrus.ErrorLevel {
+		push = p.PushPriority
+	}
+	parent, push := h.entryPush(entry.Context, push)
+	ctx, cancel := context.WithTimeout(parent, h.timeout)
+	defer cancel()
+	for _, m := range messages {
+		if !h.quota.acquire(m) {
+			h.lifecycle.drop(DropQuota, 1)
+			continue
+		}
+		if err := push(ctx, m); err != nil {
+			h.quota.release(m)
+			h.errors.record(err, 1)
+			h.lifecycle.drop(dropReason(err), 1)
+			return err
+		}
 	}
+	return nil
 }
 
-func (h *Hook) Fire(entry *logrus.Entry) error {
+// TryFire 与 Fire 相同, 但缓存已满时不等待, 丢弃日志并返回 false (超出 Quota 配额时同样返回 false), 适合延迟敏感的调用路径在发送管道饱和时跳过详细日志.
+// 不使用优先通道, Service 不支持非阻塞写入时 (例如审计模式) 等同于 Fire
+func (h *Hook) TryFire(entry *logrus.Entry) (ok bool) {
+	tp, supported := h.service.(TryPusher)
+	if !supported {
+		return h.Fire(entry) == nil
+	}
+
 	defer func() {
-		if err := recover(); err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "Hook recover from panic: %v\n", err)
+		if r := recover(); r != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Hook recover from panic: %v\n", h.recovered(r).Value)
+			ok = false
 		}
 	}()
 
-	ctx, _ := context.WithTimeout(context.Background(), h.timeout)
-	return h.service.Push(ctx, h.converter.Message(entry))
+	ok = true
+	for _, m := range h.messages(entry) {
+		if !h.quota.acquire(m) {
+			h.lifecycle.drop(DropQuota, 1)
+			ok = false
+			continue
+		}
+		if err := tp.TryPush(m); err != nil {
+			h.quota.release(m)
+			h.lifecycle.drop(DropQueueFull, 1)
+			return false
+		}
+	}
+	return ok
+}
+
+// messages 按采样, 处理链与字段拆分规则将 entry 转换为待写入的日志, 被过滤时返回空
+func (h *Hook) messages(entry *logrus.Entry) []Message {
+	if !h.audit && !h.Settings().accept(entry.Level) {
+		return nil
+	}
+
+	message, ok := h.transform(h.converter.Message(entry))
+	if !ok {
+		return nil
+	}
+	return h.split(message)
 }
 
-func (h *Hook) Levels() []logrus.Level                 { return h.visibleLevels }
-func (h *Hook) Close() error                           { return h.CloseContext(context.Background()) }
-func (h *Hook) CloseContext(ctx context.Context) error { return h.service.Stop(ctx) }
+// Push 绕过 logrus 直接将日志写入缓存, 适合访问日志等高频场景
+func (h *Hook) Push(ctx context.Context, message Message) error {
+	message, ok := h.transform(message)
+	if !ok {
+		return nil
+	}
+	for _, m := range h.split(message) {
+		if !h.quota.acquire(m) {
+			return ErrQuotaExceeded
+		}
+		if err := h.service.Push(ctx, m); err != nil {
+			h.quota.release(m)
+			return err
+		}
+	}
+	return nil
+}
+
+// split 按 TopicsKey 扇出到各主题, 再按 SplitFields 拆分
+func (h *Hook) split(message Message) []Message {
+	if h.topicsKey != "" {
+		var out []Message
+		for _, m := range fanOut(h.topicsKey, message) {
+			out = append(out, h.splitOne(m)...)
+		}
+		return out
+	}
+	return h.splitOne(message)
+}
+
+func (h *Hook) splitOne(message Message) []Message {
+	if h.splitter == nil {
+		return []Message{message}
+	}
+	return h.splitter.Split(message)
+}
+
+// Use 在处理链末尾追加 Transformer
+func (h *Hook) Use(transformers ...Transformer) {
+	if len(transformers) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	current := h.transformers.Load().(Transformers)
+	h.transformers.Store(append(current[:len(current):len(current)], transformers...))
+}
+
+func (h *Hook) transform(message Message) (Message, bool) {
+	ts := h.transformers.Load().(Transformers)
+	if len(ts) == 0 {
+		return message, true
+	}
+	m := ts.Transform(&message)
+	if m == nil {
+		return Message{}, false
+	}
+	return *m, true
+}
+
+// Reset 关闭空闲连接并重新加载凭证, 适合在凭证轮换或网络变化后由 SIGHUP 触发
+func (h *Hook) Reset() error {
+	if r, ok := h.writer.(Resetter); ok {
+		return r.Reset()
+	}
+	return nil
+}
+
+// WarmUp 预先建立到接入点的连接, 连接失败不影响后续发送
+func (h *Hook) WarmUp(ctx context.Context) error {
+	if w, ok := h.writer.(Warmer); ok {
+		return w.WarmUp(ctx)
+	}
+	return nil
+}
+
+// Ping 发送签名请求检查网络, 凭证与日志库是否可用, 可用于应用的健康检查
+func (h *Hook) Ping(ctx context.Context) error {
+	if p, ok := h.writer.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// Levels 返回 VisibleLevels, logrus 在 AddHook 时缓存该结果, 运行时的最低级别由 Fire 过滤
+func (h *Hook) Levels() []logrus.Level { return h.visibleLevels }
+
+func (h *Hook) Close() error { return h.CloseContext(context.Background()) }
+func (h *Hook) CloseContext(ctx context.Context) error {
+	if h.stop != nil {
+		h.stop()
+	}
+	err := h.service.Stop(ctx)
+	if h.lifecycle.OnClose != nil {
+		h.closeOnce.Do(func() { h.lifecycle.OnClose(err) })
+	}
+	return err
+}
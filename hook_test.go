@@ -2,10 +2,13 @@ package slsh
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -119,6 +122,34 @@ func TestConfig(t *testing.T) {
 			assert.Equal(t, DefaultInterval, c.Interval)
 		}
 
+		c = raw
+		if assert.NoError(t, c.validate()) {
+			assert.Empty(t, c.SeverityKey)
+		}
+
+		c = raw
+		c.MinFlushGap = 0
+		if assert.NoError(t, c.validate()) {
+			assert.Equal(t, DefaultMinFlushGap, c.MinFlushGap)
+		}
+
+		c = raw
+		c.Audit = true
+		c.Timeout = 0
+		if assert.NoError(t, c.validate()) {
+			assert.Equal(t, DefaultAuditTimeout, c.Timeout)
+			assert.Equal(t, DefaultAuditAttempts, c.AuditAttempts)
+		}
+
+		c = raw
+		if assert.NoError(t, c.validate()) {
+			assert.Equal(t, "http", c.uri.Scheme)
+		}
+		c.HTTPS = true
+		if assert.NoError(t, c.validate()) {
+			assert.Equal(t, "https", c.uri.Scheme)
+		}
+
 		c = raw
 		c.MessageKey = ""
 		if assert.NoError(t, c.validate()) {
@@ -221,13 +252,105 @@ func TestHook(t *testing.T) {
 		}
 
 		hook := NewCustom(DefaultTimeout, DefaultVisibleLevels, converter, writer, service)
+		var drops []string
+		hook.lifecycle.OnDrop = func(reason string, count int) { drops = append(drops, reason) }
 		logger := logrus.New()
+		logger.SetOutput(ioutil.Discard)
 		logger.AddHook(hook)
 		logger.Info("Hi")
+
+		var pErr *PanicError
+		assert.True(t, errors.As(hook.Fire(&logrus.Entry{Level: logrus.InfoLevel}), &pErr))
+		assert.False(t, hook.TryFire(&logrus.Entry{Level: logrus.InfoLevel}))
 		err := hook.CloseContext(context.TODO())
 		assert.NoError(t, err)
-		assert.Equal(t, 1, counter)
+		assert.Equal(t, 3, counter)
+		assert.Equal(t, []string{DropPanic, DropPanic, DropPanic}, drops)
+		if records := hook.RecentErrors(); assert.Len(t, records, 3) && assert.True(t, errors.As(records[0].Err, &pErr)) {
+			assert.Equal(t, "no", pErr.Value)
+			assert.NotEmpty(t, pErr.Stack)
+		}
 	})
+
+	t.Run("entry release", func(t *testing.T) {
+		var mu sync.Mutex
+		var flushed []Message
+		service := NewService(100, 10*time.Millisecond, func(messages ...Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			flushed = append(flushed, messages...)
+			return nil
+		})
+		converter := NewConverter(DefaultMessageKey, DefaultLevelKey, SyslogLevelMapping, nil, nil)
+		hook := NewCustom(time.Second, DefaultVisibleLevels, converter, &MockWriter{}, service)
+
+		logger := logrus.New()
+		logger.SetOutput(ioutil.Discard)
+		logger.AddHook(hook)
+
+		// logrus 在日志输出后复用 entry, 且字段值可能在输出后被调用方修改,
+		// Fire 返回前必须完成转换, 不能持有 entry 或字段引用
+		const goroutines, n = 8, 200
+		var wg sync.WaitGroup
+		for g := 0; g < goroutines; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < n; i++ {
+					data := map[string]int{"i": i}
+					logger.WithField("g", g).WithField("data", data).Infof("%d-%d", g, i)
+					data["i"] = -1
+					logger.Infof("%d-%d", g, i)
+				}
+			}(g)
+		}
+		wg.Wait()
+		assert.NoError(t, hook.Close())
+
+		assert.Len(t, flushed, goroutines*n*2)
+		for _, m := range flushed {
+			if g, ok := m.Contents["g"]; ok {
+				var i int
+				_, err := fmt.Sscanf(m.Contents["data"], "map[i:%d]", &i)
+				assert.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("%s-%d", g, i), m.Contents[DefaultMessageKey])
+			}
+		}
+	})
+}
+
+func TestHookTryFire(t *testing.T) {
+	chStarted, chGate := make(chan struct{}), make(chan struct{})
+	flushed := 0
+	service := NewService(1, time.Hour, func(messages ...Message) error {
+		if flushed == 0 {
+			close(chStarted)
+			<-chGate
+		}
+		flushed += len(messages)
+		return nil
+	})
+	service.MinGap = 0
+	converter := NewConverter(DefaultMessageKey, DefaultLevelKey, SyslogLevelMapping, nil, nil)
+	hook := NewCustom(time.Second, DefaultVisibleLevels, converter, &MockWriter{}, service)
+	drops := 0
+	hook.lifecycle.OnDrop = func(reason string, count int) {
+		assert.Equal(t, DropQueueFull, reason)
+		drops += count
+	}
+
+	entry := &logrus.Entry{Level: logrus.InfoLevel, Data: logrus.Fields{}}
+	assert.True(t, hook.TryFire(entry))
+	<-chStarted
+
+	// 发送阻塞期间缓存 (容量 1) 写满后不再等待
+	assert.True(t, hook.TryFire(entry))
+	assert.False(t, hook.TryFire(entry))
+	assert.Equal(t, 1, drops)
+
+	close(chGate)
+	assert.NoError(t, hook.Close())
+	assert.Equal(t, 2, flushed)
 }
 
 type MockService struct {
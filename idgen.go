@@ -0,0 +1,139 @@
+package slsh
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+)
+
+// IDGenerator 生成日志关联字段使用的 ID, 例如拆分日志的 split_id, 日志组的 __pack_id__ 前缀, 批次 ID 与日志 ID,
+// 可替换为与已有追踪系统一致的格式. 需并发安全
+type IDGenerator interface {
+	NewID() string
+}
+
+type IDGeneratorFunc func() string
+
+func (f IDGeneratorFunc) NewID() string { return f() }
+
+// RandomID 生成 16 位十六进制随机 ID, 为默认的 ID 生成器
+var RandomID IDGenerator = IDGeneratorFunc(func() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+})
+
+// ULID 生成 26 位 Crockford Base32 编码的 ULID (48 位毫秒时间戳 + 80 位随机数), 按生成时间排序
+var ULID IDGenerator = IDGeneratorFunc(func() string {
+	var b [16]byte
+	putMillis(b[:6], time.Now())
+	_, _ = rand.Read(b[6:])
+	return encodeCrockford(b)
+})
+
+// UUIDv7 生成 RFC 9562 UUID version 7 (48 位毫秒时间戳 + 随机数), 按生成时间排序
+var UUIDv7 IDGenerator = IDGeneratorFunc(func() string {
+	var b [16]byte
+	putMillis(b[:6], time.Now())
+	_, _ = rand.Read(b[6:])
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+
+	s := hex.EncodeToString(b[:])
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+})
+
+func putMillis(b []byte, t time.Time) {
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(t.UnixNano()/int64(time.Millisecond)))
+	copy(b, ms[2:])
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// encodeCrockford 将 128 位按 5 位一组编码为 26 个字符, 首字符只包含最高 3 位
+func encodeCrockford(b [16]byte) string {
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
+
+// SnowflakeEpoch 为 Snowflake 时间戳的起点
+var SnowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake 返回生成十进制 Snowflake ID 的生成器: 41 位毫秒时间戳 (自 SnowflakeEpoch) + 10 位节点 + 12 位序号,
+// node 取值 [0, 1023], 同一毫秒内序号用尽时等待下一毫秒
+func Snowflake(node int64) IDGenerator {
+	return &snowflake{node: node & 0x3ff}
+}
+
+type snowflake struct {
+	mu   sync.Mutex
+	node int64
+	last int64
+	seq  int64
+}
+
+func (s *snowflake) NewID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Since(SnowflakeEpoch).Milliseconds()
+	if now < s.last {
+		// 时钟回拨时沿用上次的时间戳, 保证 ID 递增
+		now = s.last
+	}
+	if now == s.last {
+		if s.seq = (s.seq + 1) & 0xfff; s.seq == 0 {
+			for now <= s.last {
+				time.Sleep(time.Millisecond / 10)
+				now = time.Since(SnowflakeEpoch).Milliseconds()
+			}
+		}
+	} else {
+		s.seq = 0
+	}
+	s.last = now
+	return strconv.FormatInt(now<<22|s.node<<12|s.seq, 10)
+}
+
+// SLS 用于上下文浏览的日志组标签, 格式为 "<前缀>-<十六进制序号>", 同一前缀下序号连续的日志组被视为同一来源的上下文
+const PackIDTagKey = "__pack_id__"
+
+// packIDs 生成 __pack_id__ 标签, 前缀在创建时生成一次
+type packIDs struct {
+	prefix string
+	seq    uint64
+}
+
+// WithPackID 为每个日志组附加 __pack_id__ 标签, 使 SLS 控制台可以按发送顺序浏览日志上下文,
+// 前缀由 gen 生成 (为空时使用 RandomID), 需在各进程间唯一
+func WithPackID(gen IDGenerator) WriterOption {
+	return func(w *writer) {
+		if gen == nil {
+			gen = RandomID
+		}
+		w.packs = &packIDs{prefix: strings.ToUpper(gen.NewID())}
+	}
+}
+
+// next 返回下一个 __pack_id__ 标签的编码, 可直接追加到已编码的日志组之后
+func (p *packIDs) next() []byte {
+	id := p.prefix + "-" + strings.ToUpper(strconv.FormatUint(atomic.AddUint64(&p.seq, 1)-1, 16))
+	b, _ := proto.Marshal(&api.LogGroup{LogTags: []*api.LogTag{{Key: proto.String(PackIDTagKey), Value: proto.String(id)}}})
+	return b
+}
@@ -0,0 +1,88 @@
+package slsh
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+)
+
+func TestIDGenerator(t *testing.T) {
+	sorted := func(gen IDGenerator) {
+		ids := make([]string, 3)
+		for i := range ids {
+			ids[i] = gen.NewID()
+			time.Sleep(2 * time.Millisecond)
+		}
+		assert.True(t, sort.StringsAreSorted(ids), ids)
+		assert.NotEqual(t, ids[0], ids[1])
+	}
+
+	t.Run("random", func(t *testing.T) {
+		assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{16}$`), RandomID.NewID())
+		assert.NotEqual(t, RandomID.NewID(), RandomID.NewID())
+	})
+
+	t.Run("ulid", func(t *testing.T) {
+		id := ULID.NewID()
+		assert.Regexp(t, regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`), id)
+		var b [16]byte
+		putMillis(b[:6], time.Unix(1469918176, 385000000))
+		assert.Equal(t, "01ARYZ6S41", encodeCrockford(b)[:10])
+		sorted(ULID)
+	})
+
+	t.Run("uuidv7", func(t *testing.T) {
+		assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), UUIDv7.NewID())
+		sorted(UUIDv7)
+	})
+
+	t.Run("snowflake", func(t *testing.T) {
+		gen := Snowflake(5)
+		prev := int64(0)
+		for i := 0; i < 5000; i++ {
+			id, err := strconv.ParseInt(gen.NewID(), 10, 64)
+			if !assert.NoError(t, err) || !assert.True(t, id > prev) {
+				return
+			}
+			assert.Equal(t, int64(5), id>>12&0x3ff)
+			prev = id
+		}
+	})
+}
+
+func TestPackID(t *testing.T) {
+	w := &writer{}
+	WithPackID(IDGeneratorFunc(func() string { return "abc" }))(w)
+
+	for i, expected := range []string{"ABC-0", "ABC-1"} {
+		group := &api.LogGroup{}
+		if assert.NoError(t, proto.Unmarshal(w.packs.next(), group), i) && assert.Len(t, group.LogTags, 1) {
+			assert.Equal(t, PackIDTagKey, group.LogTags[0].GetKey())
+			assert.Equal(t, expected, group.LogTags[0].GetValue())
+		}
+	}
+
+	WithPackID(nil)(w)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9A-F]{16}$`), w.packs.prefix)
+}
+
+func TestLogID(t *testing.T) {
+	c := NewConverter(DefaultMessageKey, DefaultLevelKey, SyslogLevelMapping, nil, nil)
+	c.LogIDKey = "log_id"
+	c.IDs = IDGeneratorFunc(func() string { return "id-1" })
+
+	entry := logrus.NewEntry(logrus.New())
+	assert.Equal(t, "id-1", c.Message(entry).Contents["log_id"])
+	assert.Equal(t, "own", c.Message(entry.WithField("log_id", "own")).Contents["log_id"])
+
+	c.LogIDKey = ""
+	assert.NotContains(t, c.Message(entry).Contents, "log_id")
+}
@@ -0,0 +1,121 @@
+package slsh
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultImportBatchSize = 1000
+	MaxImportBatchSize     = 4096 // PutLogs 单次请求的日志条数上限
+)
+
+// 历史日志导入配置
+type ImportOptions struct {
+	BatchSize   int           // 每次请求的日志条数, 可选, 默认为 1000, 最大为 4096
+	Rate        float64       // 每秒最多发送的请求数, 可选, 默认不限制
+	MaxAge      time.Duration // 允许导入的最早时间距当前的时长, 通常为日志库保存时间, 可选, 默认不检查
+	MaxSkew     time.Duration // 允许导入的最晚时间超出当前的时长, 可选, 默认不检查
+	SkipInvalid bool          // 跳过时间超出范围的日志, 默认返回错误且不发送任何日志
+	Progress    func(p ImportProgress)
+}
+
+// 导入进度, 每批发送成功后回调
+type ImportProgress struct {
+	Total   int
+	Sent    int
+	Skipped int
+}
+
+// 导入中途失败, Sent 之前的日志已写入
+type ImportError struct {
+	Sent int
+	Err  error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("import interrupted after %d message(s): %v", e.Sent, e.Err)
+}
+
+func (e *ImportError) Unwrap() error { return e.Err }
+
+// ImportMessages 绕过缓存将历史日志分批同步写入, 适合迁移旧日志, 按 Rate 限速并检查时间范围.
+// 日志直接交给 Writer 发送, 不经过 Config.MaxAge 等发送批次处理, 时间范围只由 opts.MaxAge 与 opts.MaxSkew 检查;
+// Writer 编码时仍按 Config.TimeClamp 调整超出窗口的时间戳, 导入早于 TimeClamp.Past 的日志前应关闭 TimeClamp.
+// Writer 实现 OptionsWriter 时 (例如 NewWriter 创建的 Writer) 每次请求受 ctx 约束, ctx 结束时中止请求并返回 *ImportError
+func (h *Hook) ImportMessages(ctx context.Context, messages []Message, opts ImportOptions) error {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultImportBatchSize
+	}
+	if opts.BatchSize > MaxImportBatchSize {
+		opts.BatchSize = MaxImportBatchSize
+	}
+
+	progress := ImportProgress{Total: len(messages)}
+	valid, err := opts.filter(time.Now(), messages)
+	if err != nil {
+		return err
+	}
+	progress.Skipped = len(messages) - len(valid)
+
+	var gap time.Duration
+	if opts.Rate > 0 {
+		gap = time.Duration(float64(time.Second) / opts.Rate)
+	}
+
+	for i := 0; i < len(valid); i += opts.BatchSize {
+		if i > 0 && gap > 0 {
+			timer := time.NewTimer(gap)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return &ImportError{Sent: progress.Sent, Err: ctx.Err()}
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return &ImportError{Sent: progress.Sent, Err: err}
+		}
+
+		end := i + opts.BatchSize
+		if end > len(valid) {
+			end = len(valid)
+		}
+		if err := h.importBatch(ctx, valid[i:end]); err != nil {
+			return &ImportError{Sent: progress.Sent, Err: err}
+		}
+
+		progress.Sent = end
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+	return nil
+}
+
+func (h *Hook) importBatch(ctx context.Context, messages []Message) error {
+	if w, ok := h.writer.(OptionsWriter); ok {
+		return w.WriteMessageOptions(ctx, RequestOptions{}, messages...)
+	}
+	return h.writer.WriteMessage(messages...)
+}
+
+// filter 检查时间范围, SkipInvalid 时返回范围内的日志, 否则遇到超出范围的日志返回错误
+func (o ImportOptions) filter(now time.Time, messages []Message) ([]Message, error) {
+	if o.MaxAge <= 0 && o.MaxSkew <= 0 {
+		return messages, nil
+	}
+
+	valid := make([]Message, 0, len(messages))
+	for i, m := range messages {
+		if o.MaxAge > 0 && m.Time.Before(now.Add(-o.MaxAge)) ||
+			o.MaxSkew > 0 && m.Time.After(now.Add(o.MaxSkew)) {
+			if !o.SkipInvalid {
+				return nil, fmt.Errorf("message %d: time %s out of import range", i, m.Time.Format(time.RFC3339))
+			}
+			continue
+		}
+		valid = append(valid, m)
+	}
+	return valid, nil
+}
@@ -0,0 +1,105 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportMessages(t *testing.T) {
+	newHook := func(batches *[]int, fail int) *Hook {
+		writer := &MockWriter{onWriteMessage: func(messages ...Message) error {
+			if len(*batches) == fail {
+				return errors.New("fail")
+			}
+			*batches = append(*batches, len(messages))
+			return nil
+		}}
+		return NewCustom(DefaultTimeout, DefaultVisibleLevels, &MockConverter{}, writer, &MockService{
+			onStart: func() {},
+			onStop:  func(ctx context.Context) error { return nil },
+		})
+	}
+	newMessages := func(n int, age time.Duration) []Message {
+		messages := make([]Message, n)
+		for i := range messages {
+			messages[i] = Message{Time: time.Now().Add(-age)}
+		}
+		return messages
+	}
+
+	t.Run("batches", func(t *testing.T) {
+		var batches []int
+		var progress []ImportProgress
+		hook := newHook(&batches, -1)
+
+		err := hook.ImportMessages(context.TODO(), newMessages(25, time.Hour), ImportOptions{
+			BatchSize: 10,
+			Rate:      1000,
+			Progress:  func(p ImportProgress) { progress = append(progress, p) },
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []int{10, 10, 5}, batches)
+		assert.Equal(t, ImportProgress{Total: 25, Sent: 25}, progress[2])
+	})
+
+	t.Run("time range", func(t *testing.T) {
+		var batches []int
+		hook := newHook(&batches, -1)
+		messages := append(newMessages(3, time.Hour), newMessages(2, 48*time.Hour)...)
+
+		err := hook.ImportMessages(context.TODO(), messages, ImportOptions{MaxAge: 24 * time.Hour})
+		assert.Error(t, err)
+		assert.Empty(t, batches)
+
+		var last ImportProgress
+		err = hook.ImportMessages(context.TODO(), messages, ImportOptions{
+			MaxAge:      24 * time.Hour,
+			SkipInvalid: true,
+			Progress:    func(p ImportProgress) { last = p },
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []int{3}, batches)
+		assert.Equal(t, ImportProgress{Total: 5, Sent: 3, Skipped: 2}, last)
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		var batches []int
+		hook := newHook(&batches, 1)
+
+		err := hook.ImportMessages(context.TODO(), newMessages(25, time.Hour), ImportOptions{BatchSize: 10})
+		var iErr *ImportError
+		if assert.True(t, errors.As(err, &iErr)) {
+			assert.Equal(t, 10, iErr.Sent)
+		}
+	})
+	t.Run("context", func(t *testing.T) {
+		var received context.Context
+		writer := optionsWriter(func(ctx context.Context, opts RequestOptions, messages ...Message) error {
+			received = ctx
+			return ctx.Err()
+		})
+		hook := NewCustom(DefaultTimeout, DefaultVisibleLevels, &MockConverter{}, writer, &MockService{
+			onStart: func() {},
+			onStop:  func(ctx context.Context) error { return nil },
+		})
+
+		ctx := context.WithValue(context.TODO(), t, "import")
+		assert.NoError(t, hook.ImportMessages(ctx, newMessages(1, time.Hour), ImportOptions{}))
+		assert.Equal(t, ctx, received)
+	})
+}
+
+// optionsWriter 同时实现 Writer 与 OptionsWriter
+type optionsWriter func(ctx context.Context, opts RequestOptions, messages ...Message) error
+
+func (f optionsWriter) WriteMessage(messages ...Message) error {
+	return f(context.Background(), RequestOptions{}, messages...)
+}
+
+func (f optionsWriter) WriteMessageOptions(ctx context.Context, opts RequestOptions, messages ...Message) error {
+	return f(ctx, opts, messages...)
+}
@@ -0,0 +1,90 @@
+package slsh
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// 阿里云日志字段索引类型
+const (
+	IndexText   = "text"
+	IndexLong   = "long"
+	IndexDouble = "double"
+)
+
+// 阿里云日志默认分词符
+var DefaultIndexTokens = []string{",", " ", "'", "\"", ";", "=", "(", ")", "[", "]", "{", "}", "?", "@", "&",
+	"<", ">", "/", ":", "\n", "\t", "\r"}
+
+// 字段索引配置, 对应 CreateIndex 接口中 keys 的取值
+type IndexKey struct {
+	Type          string   `json:"type"`
+	DocValue      bool     `json:"doc_value"`
+	Token         []string `json:"token,omitempty"`
+	CaseSensitive bool     `json:"caseSensitive"`
+}
+
+// TypeRegistry 记录各字段出现过的值类型, 用于生成与日志内容匹配的索引配置, 使数值字段可以直接参与统计分析.
+// 整数记为 long, 浮点数记为 double, 同一字段出现多种类型时退化为 text
+type TypeRegistry struct {
+	mu    sync.RWMutex
+	types map[string]string
+}
+
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{types: make(map[string]string)}
+}
+
+func (r *TypeRegistry) observe(key string, value interface{}) {
+	typ := IndexText
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		typ = IndexLong
+	case float32, float64:
+		typ = IndexDouble
+	}
+
+	r.mu.RLock()
+	current, ok := r.types[key]
+	r.mu.RUnlock()
+	if ok && (current == typ || current == IndexText) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if current, ok := r.types[key]; ok && current != typ {
+		typ = IndexText
+	}
+	r.types[key] = typ
+}
+
+// Types 返回字段与索引类型的对应关系
+func (r *TypeRegistry) Types() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make(map[string]string, len(r.types))
+	for k, v := range r.types {
+		types[k] = v
+	}
+	return types
+}
+
+// IndexKeys 返回字段索引配置, 可序列化为 CreateIndex / UpdateIndex 接口的 keys 参数
+func (r *TypeRegistry) IndexKeys() map[string]IndexKey {
+	types := r.Types()
+	keys := make(map[string]IndexKey, len(types))
+	for k, typ := range types {
+		key := IndexKey{Type: typ, DocValue: true}
+		if typ == IndexText {
+			key.Token = DefaultIndexTokens
+		}
+		keys[k] = key
+	}
+	return keys
+}
+
+// MarshalJSON 以 {"keys": {...}} 格式输出索引配置
+func (r *TypeRegistry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"keys": r.IndexKeys()})
+}
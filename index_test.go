@@ -0,0 +1,35 @@
+package slsh
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeRegistry(t *testing.T) {
+	types := NewTypeRegistry()
+	c := NewConverter(DefaultMessageKey, DefaultLevelKey, SyslogLevelMapping, nil, nil)
+	c.Types = types
+
+	c.Message(logrus.WithFields(logrus.Fields{"status": 200, "latency": 1.5, "path": "/", "mixed": 1}))
+	c.Message(logrus.WithFields(logrus.Fields{"status": 404, "mixed": "one"}))
+
+	assert.Equal(t, map[string]string{
+		"status":  IndexLong,
+		"latency": IndexDouble,
+		"path":    IndexText,
+		"mixed":   IndexText,
+	}, types.Types())
+
+	keys := types.IndexKeys()
+	assert.Equal(t, IndexKey{Type: IndexLong, DocValue: true}, keys["status"])
+	assert.NotEmpty(t, keys["path"].Token)
+
+	data, err := json.Marshal(types)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(data), `"keys":{`)
+		assert.Contains(t, string(data), `"status":{"type":"long","doc_value":true,"caseSensitive":false}`)
+	}
+}
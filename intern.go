@@ -0,0 +1,50 @@
+package slsh
+
+import "sync"
+
+// 参与驻留的字符串最大长度, 更长的值通常不会重复出现
+const DefaultInternMaxLen = 64
+
+// Interner 缓存重复出现的字段值 (服务名, 环境, 状态等), 使相同内容共享同一份内存, 减少缓存中的内存占用与 GC 扫描.
+// 缓存满后不再加入新值
+type Interner struct {
+	mu      sync.RWMutex
+	values  map[string]string
+	size    int
+	MaxLen  int
+	metrics Metrics
+}
+
+func NewInterner(size int, metrics Metrics) *Interner {
+	if metrics == nil {
+		metrics = nopMetrics{}
+	}
+	return &Interner{
+		values:  make(map[string]string, size),
+		size:    size,
+		MaxLen:  DefaultInternMaxLen,
+		metrics: metrics,
+	}
+}
+
+func (i *Interner) Intern(s string) string {
+	if len(s) > i.MaxLen {
+		return s
+	}
+
+	i.mu.RLock()
+	v, ok := i.values[s]
+	i.mu.RUnlock()
+	if ok {
+		i.metrics.Count(MetricInternHit, 1)
+		return v
+	}
+
+	i.metrics.Count(MetricInternMiss, 1)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if len(i.values) < i.size {
+		i.values[s] = s
+	}
+	return s
+}
@@ -0,0 +1,50 @@
+package slsh
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterner(t *testing.T) {
+	data := func(s string) uintptr { return *(*uintptr)(unsafe.Pointer(&s)) }
+
+	t.Run("intern", func(t *testing.T) {
+		metrics := &MockMetrics{}
+		i := NewInterner(2, metrics)
+
+		a := i.Intern(strings.Repeat("a", 3))
+		b := i.Intern(strings.Repeat("a", 3))
+		assert.Equal(t, data(a), data(b))
+
+		i.Intern("b")
+		c1, c2 := i.Intern(strings.Repeat("c", 3)), i.Intern(strings.Repeat("c", 3))
+		assert.NotEqual(t, data(c1), data(c2), "cache is full")
+
+		assert.Equal(t, int64(1), metrics.counter(MetricInternHit))
+		assert.Equal(t, int64(4), metrics.counter(MetricInternMiss))
+	})
+
+	t.Run("long value", func(t *testing.T) {
+		i := NewInterner(10, nil)
+		i.Intern(strings.Repeat("x", DefaultInternMaxLen+1))
+		assert.Empty(t, i.values)
+	})
+
+	t.Run("converter", func(t *testing.T) {
+		c := NewConverter(DefaultMessageKey, DefaultLevelKey, SyslogLevelMapping, nil, nil)
+		c.Interner = NewInterner(10, nil)
+
+		newEntry := func() *logrus.Entry {
+			return logrus.WithField("env", strings.Repeat("p", 4)).WithField("code", 200)
+		}
+		m1, m2 := c.Message(newEntry()), c.Message(newEntry())
+		assert.Equal(t, "pppp", m1.Contents["env"])
+		assert.Equal(t, "200", m2.Contents["code"])
+		assert.Equal(t, data(m1.Contents["env"]), data(m2.Contents["env"]))
+		assert.Equal(t, data(m1.Contents["code"]), data(m2.Contents["code"]))
+	})
+}
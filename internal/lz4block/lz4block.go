@@ -0,0 +1,116 @@
+// Package lz4block 实现日志组的 lz4 块压缩: 快速模式复用哈希表, 数据不可压缩时输出单个字面量序列,
+// 输出与 pierrec/lz4 的块格式兼容
+package lz4block
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/pierrec/lz4"
+)
+
+// 当前平台 int 的最大值, 32 位平台上为 math.MaxInt32
+const MaxInt = int64(^uint(0) >> 1)
+
+var (
+	ErrShortBuffer = errors.New("lz4: destination buffer too short")
+	ErrTooLarge    = errors.New("lz4: source too large")
+)
+
+// Compress 压缩 data, level 大于 0 时使用 HC 模式并作为搜索深度.
+// reuse 为 false 时快速模式使用的哈希表不放回池中, 供内存紧张时释放
+func Compress(data []byte, level int, reuse bool) ([]byte, error) {
+	size, ok := Bound(int64(len(data)), MaxInt)
+	if !ok {
+		return nil, ErrTooLarge
+	}
+	out := make([]byte, size)
+	var n int
+	var err error
+	if level > 0 {
+		n, err = lz4.CompressBlockHC(data, out, level)
+	} else {
+		table := hashTablePool.Get().(*hashTable)
+		table.reset()
+		n, err = lz4.CompressBlock(data, out, table[:])
+		if reuse {
+			hashTablePool.Put(table)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		if n, err = CopyIncompressible(data, out); err != nil {
+			return nil, err
+		}
+	}
+	return out[:n], nil
+}
+
+// Uncompress 解压压缩前长度为 rawSize 的数据
+func Uncompress(data []byte, rawSize int) ([]byte, error) {
+	if rawSize < 0 {
+		return nil, fmt.Errorf("lz4: invalid raw size %d", rawSize)
+	}
+	raw := make([]byte, rawSize)
+	n, err := lz4.UncompressBlock(data, raw)
+	if err != nil {
+		return nil, err
+	}
+	return raw[:n], nil
+}
+
+// lz4 快速模式的哈希表, 记录各哈希值最近出现的位置, 约 512KB, 复用以避免每次压缩重新分配
+type hashTable [1 << 16]int
+
+var hashTablePool = sync.Pool{New: func() interface{} { return new(hashTable) }}
+
+// reset 清空上次压缩留下的位置, 残留位置指向的是其他缓冲区的内容, 每次压缩前必须调用
+func (t *hashTable) reset() { *t = hashTable{} }
+
+// CopyIncompressible 将无法压缩的数据以单个字面量序列的 lz4 块写入 dst, dst 长度不足时返回错误
+func CopyIncompressible(src, dst []byte) (int, error) {
+	if len(dst) < IncompressibleBound(len(src)) {
+		return 0, ErrShortBuffer
+	}
+
+	lLen, di := len(src), 0
+	if lLen < 0xF {
+		dst[di] = byte(lLen << 4)
+	} else {
+		dst[di] = 0xF0
+		di++
+		for lLen -= 0xF; lLen >= 0xFF; lLen -= 0xFF {
+			dst[di] = 0xFF
+			di++
+		}
+		dst[di] = byte(lLen)
+	}
+	di++
+	di += copy(dst[di:], src)
+	return di, nil
+}
+
+// Bound 以 int64 计算压缩输出所需的长度 (lz4 块上限与 CopyIncompressible 上限中的较大值),
+// 超过 limit 时返回 false, 避免 32 位平台上 int 溢出
+func Bound(n, limit int64) (int, bool) {
+	size := n + n/0xFF + 16
+	if bound := 1 + n/0xFF + 1 + n; bound > size {
+		size = bound
+	}
+	if size > limit {
+		return 0, false
+	}
+	return int(size), true
+}
+
+// IncompressibleBound 返回 CopyIncompressible 写入 n 字节数据所需的长度:
+// 1 字节 token, 长度 >= 15 时追加 (n-15)/255+1 字节长度扩展, 再加上数据本身
+func IncompressibleBound(n int) int {
+	if n < 0xF {
+		return 1 + n
+	}
+	return 1 + (n-0xF)/0xFF + 1 + n
+}
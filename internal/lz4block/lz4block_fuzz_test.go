@@ -0,0 +1,44 @@
+//go:build go1.18
+// +build go1.18
+
+package lz4block
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pierrec/lz4"
+)
+
+func FuzzCopyIncompressible(f *testing.F) {
+	for _, size := range []int{1, 14, 15, 16, 269, 270, 271} {
+		f.Add(bytes.Repeat([]byte{'x'}, size), 0)
+		f.Add(bytes.Repeat([]byte{'x'}, size), -1)
+	}
+
+	f.Fuzz(func(t *testing.T, src []byte, slack int) {
+		if len(src) == 0 || slack < -len(src) || slack > 16 {
+			return
+		}
+		dst := make([]byte, IncompressibleBound(len(src))+slack)
+
+		n, err := CopyIncompressible(src, dst)
+		if slack < 0 {
+			if err != ErrShortBuffer {
+				t.Fatalf("expected ErrShortBuffer with %d bytes missing, got %v", -slack, err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]byte, len(src))
+		m, err := lz4.UncompressBlock(dst[:n], out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out[:m], src) {
+			t.Fatalf("round trip mismatch: got %d bytes, want %d", m, len(src))
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package lz4block
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/lz4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	samples := [][]byte{[]byte("a"), []byte(strings.Repeat("request handled ", 1000))}
+	for _, size := range []int{14, 270, 4096, 100 << 10} {
+		random := make([]byte, size)
+		r.Read(random)
+		samples = append(samples, random)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, raw := range samples {
+			data, err := Compress(raw, 0, true)
+			if !assert.NoError(t, err) {
+				continue
+			}
+			out, err := Uncompress(data, len(raw))
+			if assert.NoError(t, err) {
+				assert.Equal(t, raw, out)
+			}
+		}
+	})
+
+	t.Run("stale hash table", func(t *testing.T) {
+		// 先压缩较长的数据填满哈希表, 之后的压缩结果应与使用全新哈希表时一致
+		for _, raw := range samples {
+			var fresh hashTable
+			expected := make([]byte, lz4.CompressBlockBound(len(raw)))
+			n, err := lz4.CompressBlock(raw, expected, fresh[:])
+			if !assert.NoError(t, err) {
+				continue
+			}
+
+			_, err = Compress(samples[len(samples)-1], 0, true)
+			assert.NoError(t, err)
+			data, err := Compress(raw, 0, true)
+			if assert.NoError(t, err) && n > 0 {
+				assert.Equal(t, expected[:n], data)
+			}
+		}
+
+		table := new(hashTable)
+		table[0], table[len(table)-1] = 1, 1
+		table.reset()
+		assert.Equal(t, hashTable{}, *table)
+	})
+
+	_, err := Uncompress([]byte{0x10, 'a'}, -1)
+	assert.Error(t, err)
+}
+
+func TestCopyIncompressible(t *testing.T) {
+	for _, size := range []int{0, 1, 14, 15, 16, 269, 270, 271, 524, 525, 4096} {
+		src := []byte(strings.Repeat("x", size))
+		bound := IncompressibleBound(size)
+
+		dst := make([]byte, bound)
+		n, err := CopyIncompressible(src, dst)
+		if assert.NoError(t, err, size) {
+			assert.Equal(t, bound, n, size)
+			out := make([]byte, size)
+			m, err := lz4.UncompressBlock(dst[:n], out)
+			if size > 0 && assert.NoError(t, err, size) {
+				assert.Equal(t, src, out[:m], size)
+			}
+		}
+
+		_, err = CopyIncompressible(src, dst[:bound-1])
+		assert.Equal(t, ErrShortBuffer, err, size)
+	}
+}
+
+func TestBound(t *testing.T) {
+	for _, n := range []int{0, 1, 15, 270, 4096, 100 << 10} {
+		size, ok := Bound(int64(n), MaxInt)
+		if assert.True(t, ok, n) {
+			assert.True(t, size >= lz4.CompressBlockBound(n), n)
+			assert.True(t, size >= IncompressibleBound(n), n)
+		}
+	}
+
+	// 模拟 32 位平台: 接近 2GB 的输入在加上压缩开销后超出 int 范围
+	_, ok := Bound(math.MaxInt32-100, math.MaxInt32)
+	assert.False(t, ok)
+	_, ok = Bound(1<<30, math.MaxInt32)
+	assert.True(t, ok)
+}
@@ -0,0 +1,77 @@
+package signer
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var gmt = time.FixedZone("GMT", 0)
+
+// DateCache 格式化 Date 请求头 (RFC1123, GMT), 同一秒内复用格式化结果, 并发安全
+type DateCache struct {
+	v atomic.Value // dateEntry
+}
+
+type dateEntry struct {
+	sec  int64
+	date string
+}
+
+// Format 返回 t 所在秒的 Date 请求头
+func (c *DateCache) Format(t time.Time) string {
+	sec := t.Unix()
+	if e, ok := c.v.Load().(dateEntry); ok && e.sec == sec {
+		return e.date
+	}
+	date := time.Unix(sec, 0).In(gmt).Format(time.RFC1123)
+	c.v.Store(dateEntry{sec: sec, date: date})
+	return date
+}
+
+// Presigned 缓存请求中与 Date 无关的待签名部分 (请求头排序与资源规范化),
+// Date 变化时只重新计算 HMAC, 同一秒内 (Date 与密钥均不变) 复用签名. 请求头或 URL 变化后需重新创建, 并发安全
+type Presigned struct {
+	head string // Date 之前的部分, 以换行结尾
+	tail string // Date 之后的部分, 以换行开头
+
+	mu        sync.Mutex
+	date      string
+	secret    []byte
+	signature string
+}
+
+// NewPresigned 按 req 的请求头与 URL 创建, 忽略 req 中的 Date
+func NewPresigned(req *http.Request, prefixes []string) *Presigned {
+	const marker = "\x00date\x00"
+	r := *req
+	r.Header = req.Header.Clone()
+	r.Header["Date"] = []string{marker}
+	str := StringToSign(&r, prefixes)
+	i := strings.Index(str, marker)
+	return &Presigned{head: str[:i], tail: str[i+len(marker):]}
+}
+
+// StringToSign 返回指定 Date 的待签名字符串, 与 StringToSign 函数的结果相同
+func (p *Presigned) StringToSign(date string) string {
+	return p.head + date + p.tail
+}
+
+// Sign 返回指定 Date 的签名, Date 与密钥均与上次相同时直接返回上次的签名
+func (p *Presigned) Sign(secret []byte, date string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.signature != "" && p.date == date && bytes.Equal(p.secret, secret) {
+		return p.signature, nil
+	}
+
+	signature, err := Sign(secret, p.StringToSign(date))
+	if err != nil {
+		return "", err
+	}
+	p.date, p.secret, p.signature = date, append(p.secret[:0], secret...), signature
+	return signature, nil
+}
@@ -0,0 +1,68 @@
+package signer
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDateCache(t *testing.T) {
+	var c DateCache
+	base := time.Date(2015, 11, 9, 6, 3, 3, 0, time.UTC)
+
+	assert.Equal(t, "Mon, 09 Nov 2015 06:03:03 GMT", c.Format(base))
+	assert.Equal(t, "Mon, 09 Nov 2015 06:03:03 GMT", c.Format(base.Add(999*time.Millisecond)))
+	assert.Equal(t, "Mon, 09 Nov 2015 06:03:04 GMT", c.Format(base.Add(time.Second)))
+	assert.Equal(t, "Mon, 09 Nov 2015 06:03:03 GMT", c.Format(base.Add(time.Second-time.Nanosecond)))
+
+	// 本地时区的时间同样格式化为 GMT
+	local := base.In(time.FixedZone("CST", 8*3600))
+	assert.Equal(t, "Mon, 09 Nov 2015 06:03:03 GMT", c.Format(local))
+}
+
+func TestPresigned(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://project.example.com/logstores/store/shards/route?key=abc", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	req.Header = http.Header{
+		"Content-Md5":          []string{"1DD45FA4A70A9300CC9FE7305AF2C494"},
+		"Content-Type":         []string{"application/x-protobuf"},
+		"Date":                 []string{"Mon, 09 Nov 2015 06:03:03 GMT"},
+		"X-Log-Apiversion":     []string{"0.6.0"},
+		"X-Log-Bodyrawsize":    []string{"50"},
+		"X-Acs-Security-Token": []string{"token"},
+	}
+	p := NewPresigned(req, DefaultPrefixes)
+
+	var dates DateCache
+	base := time.Date(2015, 11, 9, 6, 3, 3, 0, time.UTC)
+	for _, offset := range []time.Duration{0, 999 * time.Millisecond, time.Second, time.Second + time.Millisecond, time.Minute} {
+		date := dates.Format(base.Add(offset))
+		req.Header.Set("Date", date)
+		want, err := Sign([]byte("secret"), StringToSign(req, DefaultPrefixes))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, StringToSign(req, DefaultPrefixes), p.StringToSign(date), offset)
+		got, err := p.Sign([]byte("secret"), date)
+		if assert.NoError(t, err) {
+			assert.Equal(t, want, got, offset)
+		}
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		date := dates.Format(base)
+		first, _ := p.Sign([]byte("secret"), date)
+		second, _ := p.Sign([]byte("secret"), date)
+		assert.Equal(t, first, second)
+
+		rotated, _ := p.Sign([]byte("rotated"), date)
+		assert.NotEqual(t, first, rotated)
+		next, _ := p.Sign([]byte("rotated"), dates.Format(base.Add(time.Second)))
+		assert.NotEqual(t, rotated, next)
+	})
+}
@@ -0,0 +1,83 @@
+// Package signer 实现日志服务 API 的请求签名:
+//
+//	Signature = base64(hmac-sha1(StringToSign, AccessKeySecret))
+package signer
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DefaultPrefixes 为参与签名的请求头前缀 (CanonicalizedSLSHeaders), 不可修改
+var DefaultPrefixes = []string{"X-Log-", "X-Acs-"}
+
+// StringToSign 返回请求的待签名字符串, prefixes 为参与签名的请求头前缀 (规范化形式)
+func StringToSign(req *http.Request, prefixes []string) string {
+	arr := make([]string, 0, 10)
+	arr = append(arr,
+		req.Method,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		req.Header.Get("Date"),
+	)
+
+	// Calc CanonicalizedSLSHeaders
+	sections := make([]string, 0, 4)
+	for k, v := range req.Header {
+		if len(v) > 0 && hasAnyPrefix(k, prefixes) {
+			str := fmt.Sprintf("%s:%s", strings.ToLower(k), strings.TrimSpace(strings.Join(v, ",")))
+			sections = append(sections, str)
+		}
+	}
+	sort.Strings(sections)
+	arr = append(arr, sections...)
+
+	// Calc CanonicalizedResource
+	canoResource := req.URL.EscapedPath()
+
+	if req.URL.RawQuery != "" {
+		values := req.URL.Query()
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		queries := make([]string, 0, len(keys))
+		for _, k := range keys {
+			for _, v := range values[k] {
+				queries = append(queries, k+"="+v)
+			}
+		}
+		canoResource += "?" + strings.Join(queries, "&")
+	}
+
+	arr = append(arr, canoResource)
+
+	return strings.Join(arr, "\n")
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign 返回待签名字符串的签名
+func Sign(secret []byte, stringToSign string) (string, error) {
+	mac := hmac.New(sha1.New, secret)
+	if _, err := mac.Write([]byte(stringToSign)); err != nil {
+		return "", err
+	}
+
+	digest := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return digest, nil
+}
@@ -0,0 +1,41 @@
+package signer
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	uri := "http://test-project.regionid.example.com/logstores/test-logstore"
+	req, err := http.NewRequest("POST", uri, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	req.Header = http.Header{
+		"Date":                  []string{"Mon, 09 Nov 2015 06:03:03 GMT"},
+		"Host":                  []string{"test-project.regionid.example.com"},
+		"X-Log-Apiversion":      []string{"0.6.0"},
+		"X-Log-Signaturemethod": []string{"hmac-sha1"},
+		"Content-Md5":           []string{"1DD45FA4A70A9300CC9FE7305AF2C494"},
+		"Content-Length":        []string{"52"},
+		"X-Log-Bodyrawsize":     []string{"50"},
+		"X-Log-Compresstype":    []string{"lz4"},
+	}
+
+	sig, err := Sign([]byte("321"), StringToSign(req, DefaultPrefixes))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "v/969+iSsYwGFtAXAy1xaK9rNDI=", sig)
+	}
+
+	t.Run("query", func(t *testing.T) {
+		req, err := http.NewRequest("GET", uri+"?type=log&query=level%3A%20error&from=1&to=2", nil)
+		if assert.NoError(t, err) {
+			req.Header = http.Header{"Date": []string{"Mon, 09 Nov 2015 06:03:03 GMT"}}
+			assert.Equal(t, "GET\n\n\nMon, 09 Nov 2015 06:03:03 GMT\n"+
+				"/logstores/test-logstore?from=1&query=level: error&to=2&type=log", StringToSign(req, DefaultPrefixes))
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package wal
+
+import "os"
+
+// FileLock 是跨进程的互斥文件锁, 防止多个进程同时追加或重放同一落盘文件.
+// 仅在支持的平台 (类 Unix 与 Windows) 上生效, 其余平台退化为进程内无锁
+type FileLock struct {
+	f *os.File
+}
+
+// Lock 打开 (或创建) path 并阻塞直至获得排他锁
+func Lock(path string) (*FileLock, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &FileLock{f: f}, nil
+}
+
+// Unlock 释放锁并关闭文件, 锁文件保留以便复用
+func (l *FileLock) Unlock() error {
+	if err := unlockFile(l.f); err != nil {
+		_ = l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package wal
+
+import "os"
+
+const lockSupported = false
+
+func lockFile(f *os.File) error   { return nil }
+func unlockFile(f *os.File) error { return nil }
@@ -0,0 +1,49 @@
+package wal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLock(t *testing.T) {
+	if !lockSupported {
+		t.Skip("file locking is not supported on this platform")
+	}
+	dir, err := ioutil.TempDir("", "wal")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "test.lock")
+
+	l1, err := Lock(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	chLocked := make(chan *FileLock)
+	go func() {
+		l2, err := Lock(path)
+		assert.NoError(t, err)
+		chLocked <- l2
+	}()
+
+	select {
+	case <-chLocked:
+		assert.Fail(t, "lock acquired twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.NoError(t, l1.Unlock())
+	select {
+	case l2 := <-chLocked:
+		assert.NoError(t, l2.Unlock())
+	case <-time.After(time.Second):
+		assert.Fail(t, "lock not released")
+	}
+}
@@ -0,0 +1,122 @@
+// Package wal 实现带 CRC32 校验的追加写日志文件, 每条记录格式为:
+//
+//	magic(4) | length(4) | crc32c(4) | payload(length)
+//
+// 读取时跳过校验失败或被截断的记录, 并通过 magic 重新定位后续记录
+package wal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+const (
+	headerSize    = 12
+	MaxRecordSize = 64 << 20
+)
+
+var (
+	magic = []byte{'S', 'L', 'S', 'H'}
+	table = crc32.MakeTable(crc32.Castagnoli)
+
+	ErrRecordTooLarge = errors.New("wal: record too large")
+)
+
+type Writer struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Open 以追加模式打开 (或创建) 文件
+func Open(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{f: f}, nil
+}
+
+// Append 写入一条记录并落盘
+func (w *Writer) Append(payload []byte) error {
+	if len(payload) > MaxRecordSize {
+		return ErrRecordTooLarge
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.f.Write(Encode(payload)); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+// Encode 返回记录的完整帧
+func Encode(payload []byte) []byte {
+	frame := make([]byte, headerSize+len(payload))
+	copy(frame, magic)
+	binary.LittleEndian.PutUint32(frame[4:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[8:], crc32.Checksum(payload, table))
+	copy(frame[headerSize:], payload)
+	return frame
+}
+
+// Decode 解析所有完整且校验通过的记录, corrupted 为跳过的损坏记录数
+func Decode(data []byte) (records [][]byte, corrupted int) {
+	for len(data) > 0 {
+		i := bytes.Index(data, magic)
+		if i < 0 {
+			return records, corrupted + 1
+		}
+		if i > 0 {
+			corrupted++
+			data = data[i:]
+		}
+
+		if len(data) < headerSize {
+			return records, corrupted + 1
+		}
+		size := binary.LittleEndian.Uint32(data[4:])
+		sum := binary.LittleEndian.Uint32(data[8:])
+		if size > MaxRecordSize || int(size) > len(data)-headerSize ||
+			crc32.Checksum(data[headerSize:headerSize+int(size)], table) != sum {
+			// 跳过当前 magic, 从下一个位置重新定位
+			corrupted++
+			data = data[1:]
+			if j := bytes.Index(data, magic); j >= 0 {
+				data = data[j:]
+			} else {
+				data = nil
+			}
+			continue
+		}
+
+		records = append(records, data[headerSize:headerSize+int(size)])
+		data = data[headerSize+int(size):]
+	}
+	return records, corrupted
+}
+
+// ReadFile 读取文件中的所有有效记录, 文件不存在时返回空
+func ReadFile(path string) (records [][]byte, corrupted int, err error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	records, corrupted = Decode(data)
+	return records, corrupted, nil
+}
@@ -0,0 +1,89 @@
+package wal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	r1, r2, r3 := []byte("first"), []byte(""), []byte("third record")
+	data := append(append(Encode(r1), Encode(r2)...), Encode(r3)...)
+
+	records, corrupted := Decode(data)
+	assert.Equal(t, [][]byte{r1, r2, r3}, records)
+	assert.Equal(t, 0, corrupted)
+}
+
+func TestDecodeCorrupted(t *testing.T) {
+	r1, r2, r3 := []byte("first"), []byte("second"), []byte("third")
+	f1, f2, f3 := Encode(r1), Encode(r2), Encode(r3)
+
+	t.Run("checksum", func(t *testing.T) {
+		bad := append([]byte{}, f2...)
+		bad[len(bad)-1] ^= 0xFF
+		records, corrupted := Decode(concat(f1, bad, f3))
+		assert.Equal(t, [][]byte{r1, r3}, records)
+		assert.Equal(t, 1, corrupted)
+	})
+
+	t.Run("length", func(t *testing.T) {
+		bad := append([]byte{}, f2...)
+		bad[4] = 0xFF
+		records, corrupted := Decode(concat(f1, bad, f3))
+		assert.Equal(t, [][]byte{r1, r3}, records)
+		assert.Equal(t, 1, corrupted)
+	})
+
+	t.Run("magic", func(t *testing.T) {
+		bad := append([]byte{}, f2...)
+		bad[0] = 'X'
+		records, corrupted := Decode(concat(f1, bad, f3))
+		assert.Equal(t, [][]byte{r1, r3}, records)
+		assert.Equal(t, 1, corrupted)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		records, corrupted := Decode(concat(f1, f2, f3[:len(f3)-2]))
+		assert.Equal(t, [][]byte{r1, r2}, records)
+		assert.Equal(t, 1, corrupted)
+	})
+}
+
+func TestWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "test.wal")
+	records, corrupted, err := ReadFile(path)
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+	assert.Equal(t, 0, corrupted)
+
+	w, err := Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, w.Append([]byte("a")))
+	assert.NoError(t, w.Append([]byte("b")))
+	assert.NoError(t, w.Close())
+
+	records, corrupted, err = ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, records)
+	assert.Equal(t, 0, corrupted)
+}
+
+func concat(frames ...[]byte) []byte {
+	var data []byte
+	for _, f := range frames {
+		data = append(data, f...)
+	}
+	return data
+}
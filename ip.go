@@ -0,0 +1,42 @@
+package slsh
+
+import (
+	"net"
+	"strings"
+)
+
+// IPNormalizer 规范化客户端 IP 字段: 去除端口, 从 X-Forwarded-For 列表中取最左侧的合法 IP.
+// 无法解析的值移至 "<key>_raw" 字段, 避免阿里云日志 IP 类型索引拒绝
+type IPNormalizer struct {
+	Keys []string // 需要规范化的字段, 例如 "remote_addr", "x_forwarded_for"
+}
+
+func (n *IPNormalizer) Modify(contents map[string]string) {
+	for _, k := range n.Keys {
+		v, ok := contents[k]
+		if !ok {
+			continue
+		}
+		if ip := NormalizeIP(v); ip != "" {
+			contents[k] = ip
+		} else {
+			delete(contents, k)
+			contents[k+"_raw"] = v
+		}
+	}
+}
+
+// NormalizeIP 返回逗号分隔列表中第一个合法的 IP, 不合法时返回空字符串
+func NormalizeIP(value string) string {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if host, _, err := net.SplitHostPort(part); err == nil {
+			part = host
+		}
+		part = strings.TrimSuffix(strings.TrimPrefix(part, "["), "]")
+		if ip := net.ParseIP(part); ip != nil {
+			return ip.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,32 @@
+package slsh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeIP(t *testing.T) {
+	cases := map[string]string{
+		"10.0.0.1":                  "10.0.0.1",
+		"10.0.0.1:8080":             "10.0.0.1",
+		"[::1]:80":                  "::1",
+		"[2001:db8::1]":             "2001:db8::1",
+		"2001:DB8::1":               "2001:db8::1",
+		"unknown, 1.2.3.4, 5.6.7.8": "1.2.3.4",
+		" 1.2.3.4 ,10.0.0.1":        "1.2.3.4",
+		"unknown":                   "",
+		"":                          "",
+	}
+	for in, out := range cases {
+		assert.Equal(t, out, NormalizeIP(in), in)
+	}
+}
+
+func TestIPNormalizer(t *testing.T) {
+	n := &IPNormalizer{Keys: []string{"remote_addr", "client_ip", "missing"}}
+	contents := map[string]string{"remote_addr": "1.2.3.4:5678", "client_ip": "-"}
+	n.Modify(contents)
+
+	assert.Equal(t, map[string]string{"remote_addr": "1.2.3.4", "client_ip_raw": "-"}, contents)
+}
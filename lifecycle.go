@@ -0,0 +1,65 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 日志被丢弃的原因
+const (
+	DropStopped   = "stopped"    // Hook 已关闭
+	DropTimeout   = "timeout"    // 写缓存或审计写入超时
+	DropFailed    = "failed"     // 发送失败且未落盘
+	DropQueueFull = "queue_full" // TryFire 或 entry 临近截止时缓存已满
+	DropQuota     = "quota"      // 该类日志超出缓存配额, 参考 Quota
+	DropRejected  = "rejected"   // 批次二分后被服务端拒绝的单条日志, 参考 Config.Bisect
+	DropExpired   = "expired"    // 发送或落盘重放时超出最长保留时长, 参考 MaxAge
+	DropPanic     = "panic"      // Fire 中转换或处理日志时, 或发送协程发送时发生 panic, 参考 PanicError
+)
+
+// 生命周期回调, 均为可选, 用于对接应用的就绪检查与停机编排, 或上报自定义指标.
+// 回调在发送协程或 Fire 中同步调用, 不应阻塞
+type Lifecycle struct {
+	OnStart func()                                            // 发送协程已启动, 审计模式下在 New 返回前调用
+	OnFlush func(count int, elapsed time.Duration, err error) // 一次批量发送完成, 审计模式下不回调
+	OnDrop  func(reason string, count int)                    // 日志被丢弃, Hook.Push 返回错误时由调用方处理, 不回调
+	OnClose func(err error)                                   // Hook 首次关闭完成, err 为关闭超时等错误
+}
+
+func (l Lifecycle) start() {
+	if l.OnStart != nil {
+		l.OnStart()
+	}
+}
+
+func (l Lifecycle) flush(count int, elapsed time.Duration, err error) {
+	if l.OnFlush != nil {
+		l.OnFlush(count, elapsed, err)
+	}
+}
+
+func (l Lifecycle) drop(reason string, count int) {
+	if l.OnDrop != nil && count > 0 {
+		l.OnDrop(reason, count)
+	}
+}
+
+// dropReason 根据写入错误判断丢弃原因
+func dropReason(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return DropTimeout
+	}
+	if errors.Is(err, ErrQueueFull) {
+		return DropQueueFull
+	}
+	return DropFailed
+}
+
+// spilledError 表示发送失败但日志已落盘, 之后会重新发送
+type spilledError struct {
+	err error
+}
+
+func (e *spilledError) Error() string { return e.err.Error() }
+func (e *spilledError) Unwrap() error { return e.err }
@@ -0,0 +1,83 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type lifecycleRecorder struct {
+	mu      sync.Mutex
+	started int
+	flushed []int
+	drops   map[string]int
+	closed  []error
+}
+
+func (r *lifecycleRecorder) lifecycle() Lifecycle {
+	r.drops = map[string]int{}
+	return Lifecycle{
+		OnStart: func() { r.mu.Lock(); r.started++; r.mu.Unlock() },
+		OnFlush: func(count int, _ time.Duration, _ error) {
+			r.mu.Lock()
+			r.flushed = append(r.flushed, count)
+			r.mu.Unlock()
+		},
+		OnDrop:  func(reason string, count int) { r.mu.Lock(); r.drops[reason] += count; r.mu.Unlock() },
+		OnClose: func(err error) { r.mu.Lock(); r.closed = append(r.closed, err); r.mu.Unlock() },
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	t.Run("service", func(t *testing.T) {
+		var r lifecycleRecorder
+		calls := 0
+		s := NewService(2, time.Hour, func(messages ...Message) error {
+			calls++
+			switch calls {
+			case 1:
+				return errors.New("unavailable")
+			case 2:
+				return &spilledError{err: errors.New("unavailable")}
+			}
+			return nil
+		})
+		s.MinGap = 0
+		s.Lifecycle = r.lifecycle()
+
+		go s.Start()
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, s.Push(context.TODO(), Message{}))
+		}
+		assert.NoError(t, s.Stop(context.TODO()))
+		assert.NoError(t, s.Push(context.TODO(), Message{}))
+
+		assert.Equal(t, 1, r.started)
+		assert.Equal(t, []int{2, 2, 1}, r.flushed)
+		assert.Equal(t, map[string]int{DropFailed: 2, DropStopped: 1}, r.drops)
+	})
+
+	t.Run("hook", func(t *testing.T) {
+		var r lifecycleRecorder
+		service := MockService{
+			onPush:  func(ctx context.Context, message Message) error { <-ctx.Done(); return ctx.Err() },
+			onStart: func() {},
+			onStop:  func(ctx context.Context) error { return nil },
+		}
+		converter := &MockConverter{onMessage: func(entry *logrus.Entry) Message { return Message{} }}
+		hook := NewCustom(time.Millisecond, DefaultVisibleLevels, converter, &MockWriter{}, service)
+		hook.lifecycle = r.lifecycle()
+
+		assert.Error(t, hook.Fire(&logrus.Entry{Level: logrus.InfoLevel}))
+		assert.NoError(t, hook.Close())
+		assert.NoError(t, hook.Close())
+
+		assert.Equal(t, map[string]int{DropTimeout: 1}, r.drops)
+		assert.Equal(t, []error{nil}, r.closed)
+	})
+}
@@ -0,0 +1,69 @@
+package slsh
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MaxAge 在发送 (含落盘重放) 时丢弃过旧的日志, 使长时间故障恢复后优先发送新日志,
+// 并避免积压的日志超出服务端接收时间窗口导致整批写入失败
+type MaxAge struct {
+	Default time.Duration                  // 最长保留时长, 0 表示不限制
+	Levels  map[logrus.Level]time.Duration // 按级别覆盖 Default, 例如 Debug 日志只保留 10 分钟, 0 表示不限制
+}
+
+type ageLimiter struct {
+	fallback  time.Duration
+	levelKey  string
+	levels    map[string]time.Duration // LevelKey 字段值对应的最长保留时长
+	clock     Clock
+	lifecycle Lifecycle
+	metrics   Metrics
+}
+
+func newAgeLimiter(a *MaxAge, levelKey string, mapping LevelMapping, clock Clock, lifecycle Lifecycle,
+	metrics Metrics) *ageLimiter {
+	l := &ageLimiter{
+		fallback:  a.Default,
+		levelKey:  levelKey,
+		levels:    make(map[string]time.Duration, len(a.Levels)),
+		clock:     clock,
+		lifecycle: lifecycle,
+		metrics:   metrics,
+	}
+	for level, age := range a.Levels {
+		l.levels[strconv.Itoa(mapping(level))] = age
+	}
+	return l
+}
+
+// expired 判断日志是否超出其级别的最长保留时长
+func (l *ageLimiter) expired(now time.Time, m Message) bool {
+	age, ok := l.levels[m.Contents[l.levelKey]]
+	if !ok {
+		age = l.fallback
+	}
+	return age > 0 && now.Sub(m.Time) > age
+}
+
+func (l *ageLimiter) wrap(flush func(...Message) error) func(...Message) error {
+	return func(messages ...Message) error {
+		now := l.clock.Now()
+		fresh := make([]Message, 0, len(messages))
+		for _, m := range messages {
+			if !l.expired(now, m) {
+				fresh = append(fresh, m)
+			}
+		}
+		if expired := len(messages) - len(fresh); expired > 0 {
+			l.metrics.Count(MetricExpired, int64(expired))
+			l.lifecycle.drop(DropExpired, expired)
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+		return flush(fresh...)
+	}
+}
@@ -0,0 +1,91 @@
+package slsh
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedClock struct {
+	Clock
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time { return c.now }
+
+func TestMaxAge(t *testing.T) {
+	now := time.Unix(1577836800, 0)
+	message := func(age time.Duration, l logrus.Level) Message {
+		return Message{Time: now.Add(-age), Contents: map[string]string{DefaultLevelKey: strconv.Itoa(SyslogLevelMapping(l))}}
+	}
+
+	policy := &MaxAge{Default: time.Hour, Levels: map[logrus.Level]time.Duration{logrus.DebugLevel: time.Minute, logrus.ErrorLevel: 0}}
+	newLimiter := func(metrics Metrics, drops *int) *ageLimiter {
+		lifecycle := Lifecycle{OnDrop: func(reason string, count int) {
+			assert.Equal(t, DropExpired, reason)
+			*drops += count
+		}}
+		return newAgeLimiter(policy, DefaultLevelKey, SyslogLevelMapping, fixedClock{now: now}, lifecycle, metrics)
+	}
+
+	t.Run("filter", func(t *testing.T) {
+		var delivered []Message
+		var drops int
+		metrics := &MockMetrics{}
+		flush := newLimiter(metrics, &drops).wrap(func(messages ...Message) error {
+			delivered = append(delivered, messages...)
+			return nil
+		})
+
+		messages := []Message{
+			message(30*time.Second, logrus.DebugLevel),
+			message(2*time.Minute, logrus.DebugLevel),
+			message(30*time.Minute, logrus.InfoLevel),
+			message(2*time.Hour, logrus.InfoLevel),
+			message(48*time.Hour, logrus.ErrorLevel),
+		}
+		assert.NoError(t, flush(messages...))
+		assert.Equal(t, []Message{messages[0], messages[2], messages[4]}, delivered)
+		assert.Equal(t, 2, drops)
+		assert.Equal(t, int64(2), metrics.counter(MetricExpired))
+
+		delivered = nil
+		assert.NoError(t, flush(messages[1], messages[3]))
+		assert.Empty(t, delivered)
+		assert.Equal(t, 4, drops)
+	})
+
+	t.Run("spill", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "maxage")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+		s, err := newSpill(dir, nil, &MockMetrics{})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// 落盘时仍在保留时长内, 重放时已过期
+		assert.NoError(t, s.append([]Message{message(59*time.Minute, logrus.InfoLevel), message(time.Minute, logrus.InfoLevel)}))
+		now = now.Add(30 * time.Minute)
+		defer func() { now = now.Add(-30 * time.Minute) }()
+
+		var delivered []Message
+		var drops int
+		flush := s.wrap(newLimiter(&MockMetrics{}, &drops).wrap(func(messages ...Message) error {
+			delivered = append(delivered, messages...)
+			return nil
+		}))
+		assert.NoError(t, flush())
+		if assert.Len(t, delivered, 1) {
+			assert.True(t, delivered[0].Time.Equal(now.Add(-31*time.Minute)))
+		}
+		assert.Equal(t, 1, drops)
+	})
+}
@@ -0,0 +1,69 @@
+package slsh
+
+import (
+	"sort"
+	"strconv"
+)
+
+// 字段数超出上限时记录被省略字段数的字段
+const OmittedFieldsKey = "omitted_fields"
+
+// fieldLimit 限制单条日志的字段数, 按优先级保留字段, 其余字段仅记录数量
+type fieldLimit struct {
+	max  int
+	rank map[string]int // 优先保留的字段及其顺序, 不在其中的字段按字典序排在之后
+}
+
+// WithMaxFields 限制单条日志最多保留 n 个字段 (不含 OmittedFieldsKey), 避免超出索引字段数限制及载荷过大.
+// 依次保留 message, level, priority 中的字段, 其余按字典序保留, 被省略的字段数记录在 OmittedFieldsKey 字段,
+// n <= 0 时不限制. 与 SplitFields 不同, 超出的字段会被丢弃
+func WithMaxFields(n int, priority ...string) WriterOption {
+	return func(w *writer) {
+		if n <= 0 {
+			w.fields = fieldLimit{}
+			return
+		}
+		keys := append([]string{DefaultMessageKey, DefaultLevelKey}, priority...)
+		rank := make(map[string]int, len(keys))
+		for _, k := range keys {
+			if _, ok := rank[k]; !ok {
+				rank[k] = len(rank)
+			}
+		}
+		w.fields = fieldLimit{max: n, rank: rank}
+	}
+}
+
+func (l fieldLimit) enabled() bool { return l.max > 0 }
+
+// limit 返回限制字段数后的日志, 需要省略字段时复制 Contents, 不修改原日志
+func (l fieldLimit) limit(message Message) Message {
+	if l.max <= 0 || len(message.Contents) <= l.max {
+		return message
+	}
+
+	keys := make([]string, 0, len(message.Contents))
+	for k := range message.Contents {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ri, iok := l.rank[keys[i]]
+		rj, jok := l.rank[keys[j]]
+		if iok != jok {
+			return iok
+		}
+		if iok {
+			return ri < rj
+		}
+		return keys[i] < keys[j]
+	})
+
+	contents := make(map[string]string, l.max+1)
+	for _, k := range keys[:l.max] {
+		contents[k] = message.Contents[k]
+	}
+	contents[OmittedFieldsKey] = strconv.Itoa(len(keys) - l.max)
+	// 只保留部分字段, 时间, 主题与来源沿用原日志
+	message.Contents = contents
+	return message
+}
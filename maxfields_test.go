@@ -0,0 +1,55 @@
+package slsh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxFields(t *testing.T) {
+	limited := func(n int, priority ...string) fieldLimit {
+		w := &writer{}
+		WithMaxFields(n, priority...)(w)
+		return w.fields
+	}
+	m := Message{Time: time.Unix(1, 0), Topic: "t", Source: "s", Contents: map[string]string{
+		"message": "m", "level": "4", "user": "u", "a": "1", "b": "2", "c": "3",
+	}}
+
+	t.Run("priority", func(t *testing.T) {
+		got := limited(4, "user").limit(m)
+		assert.Equal(t, map[string]string{
+			"message": "m", "level": "4", "user": "u", "a": "1", OmittedFieldsKey: "2",
+		}, got.Contents)
+		assert.Equal(t, m.Time, got.Time)
+		assert.Equal(t, "t", got.Topic)
+		assert.Equal(t, "s", got.Source)
+		assert.Len(t, m.Contents, 6)
+	})
+
+	t.Run("lexicographic", func(t *testing.T) {
+		got := limited(3).limit(m)
+		assert.Equal(t, map[string]string{
+			"message": "m", "level": "4", "a": "1", OmittedFieldsKey: "3",
+		}, got.Contents)
+	})
+
+	t.Run("within limit", func(t *testing.T) {
+		assert.Equal(t, m, limited(6).limit(m))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.False(t, limited(0).enabled())
+		assert.Equal(t, m, limited(0).limit(m))
+	})
+
+	t.Run("encode", func(t *testing.T) {
+		w := &writer{fields: limited(2)}
+		assert.Equal(t,
+			(&writer{}).appendGroup(nil, Message{Time: m.Time, Contents: map[string]string{
+				"message": "m", "level": "4", OmittedFieldsKey: "4",
+			}}),
+			w.appendGroup(nil, Message{Time: m.Time, Contents: m.Contents}))
+	})
+}
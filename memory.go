@@ -0,0 +1,55 @@
+package slsh
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// 内存紧张状态的保持时间, 期间编码与压缩缓冲区用完即丢弃, 不再放回池中
+const memoryPressureHold = 5 * time.Second
+
+// 检查进程内存用量的间隔
+const memoryCheckInterval = time.Second
+
+// 内存紧张状态的截止时间 (unix 纳秒), 进程内所有 Hook 共享缓冲池, 因此为全局状态
+var pressureUntil int64
+
+func signalMemoryPressure() {
+	atomic.StoreInt64(&pressureUntil, time.Now().Add(memoryPressureHold).UnixNano())
+}
+
+func underMemoryPressure() bool {
+	return time.Now().UnixNano() < atomic.LoadInt64(&pressureUntil)
+}
+
+// 支持立即发送缓存日志的 Service
+type Flusher interface {
+	FlushNow()
+}
+
+// ReleaseMemory 在进程内存紧张时调用 (例如收到 cgroup 内存告警), 立即发送缓存中的日志并释放对其的引用,
+// 随后一段时间内编码与压缩缓冲区不再复用, 避免日志组件加剧 OOM
+func (h *Hook) ReleaseMemory() {
+	signalMemoryPressure()
+	if f, ok := h.service.(Flusher); ok {
+		f.FlushNow()
+	}
+}
+
+// watchMemory 定期检查内存用量, 达到内存上限的 ratio 比例时调用 ReleaseMemory, ctx 取消后退出
+func (h *Hook) watchMemory(ctx context.Context, ratio float64) {
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if memoryPressured(ratio) {
+				h.ReleaseMemory()
+			}
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build go1.19
+// +build go1.19
+
+package slsh
+
+import (
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+)
+
+// memoryPressured 判断 Go 运行时管理的内存 (不含已归还系统的部分) 是否达到 GOMEMLIMIT 的 ratio 比例, 未设置上限时返回 false
+func memoryPressured(ratio float64) bool {
+	limit := debug.SetMemoryLimit(-1)
+	if limit == math.MaxInt64 {
+		return false
+	}
+
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	used := samples[0].Value.Uint64() - samples[1].Value.Uint64()
+	return float64(used) >= ratio*float64(limit)
+}
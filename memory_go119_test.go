@@ -0,0 +1,22 @@
+//go:build go1.19
+// +build go1.19
+
+package slsh
+
+import (
+	"math"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryPressured(t *testing.T) {
+	limit := debug.SetMemoryLimit(math.MaxInt64)
+	defer debug.SetMemoryLimit(limit)
+
+	assert.False(t, memoryPressured(0.9))
+
+	debug.SetMemoryLimit(1 << 20)
+	assert.True(t, memoryPressured(0.9))
+}
@@ -0,0 +1,7 @@
+//go:build !go1.19
+// +build !go1.19
+
+package slsh
+
+// Go 1.19 之前没有内存上限, 仅支持通过 Hook.ReleaseMemory 主动触发
+func memoryPressured(float64) bool { return false }
@@ -0,0 +1,55 @@
+package slsh
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseMemory(t *testing.T) {
+	defer atomic.StoreInt64(&pressureUntil, 0)
+
+	chFlush := make(chan int, 1)
+	s := NewService(10, time.Hour, func(messages ...Message) error { chFlush <- len(messages); return nil })
+	s.MinGap = time.Hour
+	hook := NewCustom(DefaultTimeout, DefaultVisibleLevels, &MockConverter{}, &MockWriter{}, s)
+	defer func() { _ = hook.Close() }()
+
+	assert.NoError(t, hook.Push(context.TODO(), Message{}))
+	assert.NoError(t, hook.Push(context.TODO(), Message{}))
+	assert.False(t, underMemoryPressure())
+
+	// 日志写入缓存前就触发时不会发送, 重试直到两条日志都被读取
+	deadline := time.After(time.Second)
+	for flushed := 0; flushed < 2; {
+		hook.ReleaseMemory()
+		select {
+		case n := <-chFlush:
+			flushed += n
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			assert.Fail(t, "buffered messages not flushed")
+			return
+		}
+	}
+	assert.True(t, underMemoryPressure())
+}
+
+func TestConfigMemoryLimitRatio(t *testing.T) {
+	c := Config{
+		Endpoint:         "example.com",
+		AccessKey:        "123",
+		AccessSecret:     "321",
+		Project:          "test-project",
+		Store:            "test-store",
+		Topic:            "test-topic",
+		MemoryLimitRatio: 1.5,
+	}
+	assert.Error(t, c.validate())
+
+	c.MemoryLimitRatio = 0.9
+	assert.NoError(t, c.validate())
+}
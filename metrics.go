@@ -0,0 +1,35 @@
+package slsh
+
+// 指标名称
+const (
+	MetricInFlightWait      = "inflight_wait_seconds" // 等待发送并发名额的耗时
+	MetricSpilled           = "spilled_messages"      // 发送失败后落盘的日志条数
+	MetricReplayed          = "replayed_messages"     // 从磁盘重新发送成功的日志条数
+	MetricCorrupted         = "corrupted_records"     // 落盘文件中校验失败而被跳过的记录数
+	MetricDuplicated        = "duplicated_records"    // 重放时内容重复或已发送而被跳过的落盘记录数
+	MetricRetried           = "retried_requests"      // 发送失败后重试的请求数
+	MetricRetryElapsed      = "retry_elapsed_batches" // 重试总时长超过 MaxRetryElapsed 而放弃发送的批次数
+	MetricSignatureMismatch = "signature_mismatches"  // 服务端签名校验失败的请求数
+	MetricInternHit         = "intern_hits"           // 字段值命中驻留缓存的次数
+	MetricInternMiss        = "intern_misses"         // 字段值未命中驻留缓存的次数
+	MetricCompressionRatio  = "compression_ratio"     // 每批次压缩前后的长度之比, 跳过压缩的批次为 1
+	MetricCompressionSkip   = "compression_skipped"   // 因内容已压缩而跳过压缩的批次数
+	MetricRejected          = "rejected_messages"     // 批次二分后被服务端拒绝而丢弃的日志条数
+	MetricExpired           = "expired_messages"      // 超出 MaxAge 而在发送前丢弃的日志条数
+	MetricSnapshot          = "snapshot_messages"     // 关闭时保存到落盘目录, 下次启动时恢复的日志条数
+	MetricShardMessages     = "sharded_messages"      // 分配给分片写入实例的日志条数, 仅以 ShardMetric 名称上报
+	MetricWorkerRestarts    = "worker_restarts"       // 后台协程 (发送, 内存监控等) 因 panic 退出后重启的次数
+
+	MetricDeliveryErrorPrefix = "delivery_errors_" // 按类型统计的传输错误数, 后缀为 DeliveryKind, 例如 "delivery_errors_tls"
+)
+
+// 指标上报接口, 可对接 Prometheus 等监控系统, 实现需并发安全
+type Metrics interface {
+	Count(name string, delta int64)
+	Observe(name string, value float64)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) Count(string, int64)     {}
+func (nopMetrics) Observe(string, float64) {}
@@ -0,0 +1,34 @@
+package slsh
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError 表示 Fire 中转换或写入日志时发生并已恢复的 panic, 通过 ErrorHandler 上报, 不会传递到调用方的协程
+type PanicError struct {
+	Value string // panic 的值
+	Stack []byte // 发生 panic 时的调用栈
+}
+
+func (e *PanicError) Error() string {
+	return "slsh: recovered from panic: " + e.Value
+}
+
+// panicValue 格式化 panic 的值, 值本身的 String 或 Error 方法再次 panic 时返回其类型
+func panicValue(r interface{}) (s string) {
+	defer func() {
+		if recover() != nil {
+			s = fmt.Sprintf("%T", r)
+		}
+	}()
+	return fmt.Sprint(r)
+}
+
+// recovered 上报 Fire 中恢复的 panic, 该日志被丢弃
+func (h *Hook) recovered(r interface{}) *PanicError {
+	err := &PanicError{Value: panicValue(r), Stack: debug.Stack()}
+	h.errors.record(err, 1)
+	h.lifecycle.drop(DropPanic, 1)
+	return err
+}
@@ -0,0 +1,70 @@
+package slsh
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Payload 是编码并压缩后的日志组, 可自行保存 (例如写入消息队列), 之后通过 writer.Send 发送
+type Payload struct {
+	Data         []byte // 请求体
+	RawSize      int    // 压缩前的长度
+	CompressType string // 压缩类型, 参考 CompressTypeXXX, 为空表示未压缩
+	DictID       string // 压缩使用的字典 ID, 参考 WithDictionary, 为空表示未使用字典
+}
+
+// EncodeBatch 将日志编码为 protobuf 格式的日志组并压缩, opts 可指定压缩实现, 日志组标签等,
+// 与 NewWriter 使用相同的选项时, 结果与 WriteMessage 发送的请求体一致
+func EncodeBatch(topic, source string, messages []Message, opts ...WriterOption) (Payload, error) {
+	w := NewWriter(&url.URL{}, topic, source, "", nil, nil, opts...)
+	raw, err := w.encode(messages...)
+	if err != nil {
+		return Payload{}, err
+	}
+	data, rawSize, compressType, dictID, err := w.compressBatch(raw, messages)
+	if err != nil {
+		return Payload{}, err
+	}
+	return Payload{Data: data, RawSize: rawSize, CompressType: compressType, DictID: dictID}, nil
+}
+
+// Header 返回与载荷内容相关的请求头, 不含 Date, Host 与签名, 字典 ID 使用 DefaultCompressDictHeader
+func (p Payload) Header() http.Header {
+	pp := p.payload()
+	h := http.Header{
+		"Content-Type":      hContentType,
+		"Content-Length":    []string{strconv.Itoa(len(p.Data))},
+		"Content-Md5":       []string{pp.md5},
+		"X-Log-Bodyrawsize": []string{pp.rawSize},
+	}
+	if pp.compressType != nil {
+		h["X-Log-Compresstype"] = pp.compressType
+	}
+	if pp.dict != nil {
+		h[DefaultCompressDictHeader] = pp.dict
+	}
+	return h
+}
+
+func (p Payload) payload() payload {
+	return newPayload(p.Data, p.RawSize, p.CompressType, p.DictID)
+}
+
+// 单次写入请求的可选参数
+type RequestOptions struct {
+	HashKey string            // 按哈希键写入对应分片 (128 位十六进制, 例如 MD5), 可选, 默认负载均衡写入
+	Headers map[string]string // 附加的请求头, 例如特殊写入模式的 x-log-* 请求头, 可选, 不覆盖内置请求头, 匹配签名前缀的参与签名
+}
+
+// url 返回写入地址, 设置 HashKey 时使用 /shards/route?key=<HashKey>
+func (o RequestOptions) url(uri *url.URL) string {
+	if o.HashKey == "" {
+		return uri.String()
+	}
+	u := *uri
+	u.Path = strings.TrimSuffix(u.Path, "/lb") + "/route"
+	u.RawQuery = url.Values{"key": {o.HashKey}}.Encode()
+	return u.String()
+}
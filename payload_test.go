@@ -0,0 +1,138 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/signer"
+)
+
+func TestEncodeBatch(t *testing.T) {
+	var bodies [][]byte
+	var headers []http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, body)
+		headers = append(headers, req.Header)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient)
+
+	t.Run("send", func(t *testing.T) {
+		bodies, headers = nil, nil
+		// 单字段日志的编码与 map 遍历顺序无关, 两次编码结果可直接比较
+		p, err := EncodeBatch(DefaultTopic, DefaultSource, []Message{ShortMessage})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, CompressTypeLZ4, p.CompressType)
+
+		assert.NoError(t, w.WriteMessage(ShortMessage))
+		assert.NoError(t, w.Send(p))
+		if assert.Len(t, bodies, 2) {
+			assert.Equal(t, bodies[0], bodies[1])
+			assert.Equal(t, p.Data, bodies[1])
+			for k, v := range p.Header() {
+				assert.Equal(t, v, headers[0][k], k)
+				assert.Equal(t, v, headers[1][k], k)
+			}
+		}
+	})
+
+	t.Run("uncompressed", func(t *testing.T) {
+		bodies, headers = nil, nil
+		p, err := EncodeBatch(DefaultTopic, DefaultSource, Messages, WithCompressor(identityCompressor{}))
+		if !assert.NoError(t, err) {
+			return
+		}
+		p.CompressType = ""
+
+		assert.NotContains(t, p.Header(), "X-Log-Compresstype")
+		assert.NoError(t, w.Send(p))
+		if assert.Len(t, headers, 1) {
+			assert.NotContains(t, headers[0], "X-Log-Compresstype")
+			assert.Equal(t, p.Data, bodies[0])
+		}
+	})
+
+	t.Run("pre-signed", func(t *testing.T) {
+		bodies, headers = nil, nil
+		p, err := EncodeBatch(DefaultTopic, DefaultSource, Messages)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		req, err := w.SignPayload(context.TODO(), p)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NotEmpty(t, req.Header.Get("Authorization"))
+
+		resp, err := http.DefaultClient.Do(req)
+		if assert.NoError(t, err) {
+			_ = resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+		if assert.Len(t, bodies, 1) {
+			assert.Equal(t, p.Data, bodies[0])
+			assert.Equal(t, req.Header.Get("Authorization"), headers[0].Get("Authorization"))
+		}
+	})
+}
+
+func TestWriterSendPayloadContext(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+		WithRetry(5, time.Hour))
+
+	p, err := EncodeBatch(DefaultTopic, DefaultSource, Messages)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 20*time.Millisecond)
+	defer cancel()
+	err = w.SendPayload(ctx, p)
+	var hErr *HTTPError
+	assert.True(t, errors.As(err, &hErr))
+	assert.Equal(t, 1, requests)
+}
+
+func TestWriterRequestOptions(t *testing.T) {
+	var req *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { req = r }))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL + "/logstores/store/shards/lb")
+	w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient)
+
+	opts := RequestOptions{
+		HashKey: "5f4dcc3b5aa765d61d8327deb882cf99",
+		Headers: map[string]string{"x-log-exptime": "60", "Content-Type": "text/plain"},
+	}
+	if assert.NoError(t, w.WriteMessageOptions(context.TODO(), opts, ShortMessage)) && assert.NotNil(t, req) {
+		assert.Equal(t, "/logstores/store/shards/route", req.URL.Path)
+		assert.Equal(t, opts.HashKey, req.URL.Query().Get("key"))
+		assert.Equal(t, "60", req.Header.Get("X-Log-Exptime"))
+		assert.Equal(t, "application/x-protobuf", req.Header.Get("Content-Type"))
+		assert.Contains(t, signer.StringToSign(req, signer.DefaultPrefixes), "x-log-exptime:60")
+		assert.Contains(t, signer.StringToSign(req, signer.DefaultPrefixes), "/shards/route?key="+opts.HashKey)
+	}
+}
@@ -0,0 +1,56 @@
+package slsh
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/validator"
+)
+
+// 预设配置, 按负载类型组合批量, 并发, 重试与落盘参数, 仅填充未设置 (零值) 的字段, 显式配置优先
+type Preset string
+
+const (
+	// 交互式服务: 小批量, 短间隔, 流量稀疏时立即发送, 快速重试
+	PresetLowLatency Preset = "low_latency"
+	// 高频日志: 大批量, 长间隔, 较高并发与压缩率, 启用字段值驻留
+	PresetHighThroughput Preset = "high_throughput"
+	// 尽量不丢日志: 多次重试, 失败落盘, 错误日志优先发送
+	PresetDurable Preset = "durable"
+)
+
+// apply 填充未设置的字段, 布尔字段只会被设为 true
+func (p Preset) apply(c *Config) error {
+	switch p {
+	case "":
+	case PresetLowLatency:
+		c.BufferSize = validator.CoalesceInt(c.BufferSize, 20)
+		c.Interval = validator.CoalesceDur(c.Interval, 200*time.Millisecond)
+		c.MinFlushGap = validator.CoalesceDur(c.MinFlushGap, 20*time.Millisecond)
+		c.MaxInFlight = validator.CoalesceInt(c.MaxInFlight, 4)
+		c.MaxAttempts = validator.CoalesceInt(c.MaxAttempts, 2)
+		c.RetryBackoff = validator.CoalesceDur(c.RetryBackoff, 50*time.Millisecond)
+		c.IdleFlush = true
+		c.WarmUp = true
+	case PresetHighThroughput:
+		c.BufferSize = validator.CoalesceInt(c.BufferSize, 2000)
+		c.Interval = validator.CoalesceDur(c.Interval, 5*time.Second)
+		c.MinFlushGap = validator.CoalesceDur(c.MinFlushGap, 500*time.Millisecond)
+		c.MaxInFlight = validator.CoalesceInt(c.MaxInFlight, 8)
+		c.MaxAttempts = validator.CoalesceInt(c.MaxAttempts, 3)
+		c.CompressionLevel = validator.CoalesceInt(c.CompressionLevel, CompressionBalanced)
+		c.InternSize = validator.CoalesceInt(c.InternSize, 1024)
+	case PresetDurable:
+		c.BufferSize = validator.CoalesceInt(c.BufferSize, 500)
+		c.Timeout = validator.CoalesceDur(c.Timeout, 2*time.Second)
+		c.MaxAttempts = validator.CoalesceInt(c.MaxAttempts, 5)
+		c.RetryBackoff = validator.CoalesceDur(c.RetryBackoff, 200*time.Millisecond)
+		c.SpillDir = validator.CoalesceStr(c.SpillDir,
+			filepath.Join(os.TempDir(), "slsh-"+c.Project+"-"+c.Store))
+		c.PriorityLane = true
+	default:
+		return validator.IllegalArgument("Preset", "unknown preset "+string(p))
+	}
+	return nil
+}
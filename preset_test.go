@@ -0,0 +1,64 @@
+package slsh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreset(t *testing.T) {
+	newConfig := func(preset Preset) Config {
+		return Config{
+			Endpoint:     "example.com",
+			AccessKey:    "123",
+			AccessSecret: "321",
+			Project:      "test-project",
+			Store:        "test-store",
+			Topic:        "test-topic",
+			Preset:       preset,
+		}
+	}
+
+	t.Run("low latency", func(t *testing.T) {
+		c := newConfig(PresetLowLatency)
+		c.BufferSize = 50
+		if assert.NoError(t, c.validate()) {
+			assert.Equal(t, 50, c.BufferSize)
+			assert.Equal(t, 200*time.Millisecond, c.Interval)
+			assert.True(t, c.IdleFlush)
+			assert.Equal(t, 2, c.MaxAttempts)
+		}
+	})
+
+	t.Run("high throughput", func(t *testing.T) {
+		c := newConfig(PresetHighThroughput)
+		if assert.NoError(t, c.validate()) {
+			assert.Equal(t, 2000, c.BufferSize)
+			assert.Equal(t, CompressionBalanced, c.CompressionLevel)
+			assert.Equal(t, DefaultTimeout, c.Timeout)
+		}
+	})
+
+	t.Run("durable", func(t *testing.T) {
+		c := newConfig(PresetDurable)
+		if assert.NoError(t, c.validate()) {
+			assert.Contains(t, c.SpillDir, "slsh-test-project-test-store")
+			assert.True(t, c.PriorityLane)
+			assert.Equal(t, 5, c.MaxAttempts)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		c := newConfig("fast")
+		assert.Error(t, c.validate())
+	})
+
+	t.Run("none", func(t *testing.T) {
+		c := newConfig("")
+		if assert.NoError(t, c.validate()) {
+			assert.Equal(t, DefaultBufferSize, c.BufferSize)
+			assert.False(t, c.IdleFlush)
+		}
+	})
+}
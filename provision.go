@@ -0,0 +1,316 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/validator"
+)
+
+// 告警严重度
+const (
+	AlertSeverityReport   = 2
+	AlertSeverityLow      = 4
+	AlertSeverityMedium   = 6
+	AlertSeverityHigh     = 8
+	AlertSeverityCritical = 10
+)
+
+const errJobExist = "JobAlreadyExist"
+
+// 管理接口配置, 字段含义与 ConsumerConfig 相同
+type ProvisionerConfig struct {
+	Endpoint     string
+	AccessKey    string
+	AccessSecret string
+	Credentials  CredentialsProvider
+	Project      string
+	Store        string // 告警与定时 SQL 默认查询的日志库
+	HTTPS        bool
+	HttpClient   *http.Client
+}
+
+// Provisioner 创建或更新与 Hook 写入的字段配套的告警规则与定时 SQL 作业, 便于以 Go 代码管理完整的日志链路配置.
+// 只覆盖常用字段, 更复杂的配置请使用官方 SDK 或控制台
+type Provisioner struct {
+	writer  *writer
+	project string
+	store   string
+}
+
+func NewProvisioner(c ProvisionerConfig) (*Provisioner, error) {
+	consumer, err := NewConsumer(ConsumerConfig{
+		Endpoint:     c.Endpoint,
+		AccessKey:    c.AccessKey,
+		AccessSecret: c.AccessSecret,
+		Credentials:  c.Credentials,
+		Project:      c.Project,
+		Store:        c.Store,
+		HTTPS:        c.HTTPS,
+		HttpClient:   c.HttpClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Provisioner{writer: consumer.writer, project: c.Project, store: c.Store}, nil
+}
+
+// AlertRule 告警规则 (作业类型 Alert), 按固定间隔执行查询, 结果满足 Condition 时按 ActionPolicyID 通知
+type AlertRule struct {
+	Name           string            // 规则名称, 项目内唯一, 作为创建或更新的依据
+	DisplayName    string            // 显示名称, 可选, 默认为 Name
+	Description    string            // 描述, 可选, 同时作为告警内容
+	Store          string            // 查询的日志库, 可选, 默认为 ProvisionerConfig.Store
+	Query          string            // 查询与分析语句
+	Window         time.Duration     // 查询的时间范围 (截至检查时), 可选, 默认为 15 分钟
+	Interval       time.Duration     // 检查间隔, 可选, 默认为 1 分钟
+	Condition      string            // 触发条件, 例如 "rate > 0.05", 为空时查询有结果即触发
+	Severity       int               // 严重度, 参考 AlertSeverityXXX, 可选, 默认为 AlertSeverityHigh
+	ActionPolicyID string            // 行动策略 ID, 可选, 为空时使用默认策略
+	Labels         map[string]string // 标签, 可选
+	Disabled       bool
+}
+
+// ErrorRateAlert 返回按 levelKey 统计错误率的告警规则, 级别值由 mapping 映射 (与 Config 一致),
+// Error 及更严重级别的日志占比在 window 内超过 threshold 时触发. levelKey 为空时使用 DefaultLevelKey,
+// mapping 为空时使用 SyslogLevelMapping
+func ErrorRateAlert(name, levelKey string, mapping LevelMapping, threshold float64, window time.Duration) AlertRule {
+	if levelKey == "" {
+		levelKey = DefaultLevelKey
+	}
+	if mapping == nil {
+		mapping = SyslogLevelMapping
+	}
+
+	// 映射值可能随级别递增或递减, 逐个列出 Error 及更严重的级别
+	levels := ""
+	for _, l := range []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel} {
+		if levels != "" {
+			levels += ", "
+		}
+		levels += strconv.Itoa(mapping(l))
+	}
+	return AlertRule{
+		Name:        name,
+		Description: fmt.Sprintf("error rate above %g", threshold),
+		Query: fmt.Sprintf(`* | select sum(if(try_cast(%q as bigint) in (%s), 1, 0)) * 1.0 / count(*) as rate`,
+			levelKey, levels),
+		Window:    window,
+		Condition: "rate > " + strconv.FormatFloat(threshold, 'g', -1, 64),
+		Severity:  AlertSeverityHigh,
+	}
+}
+
+// ErrorBudgetAlert 返回 ErrorBudget 告警日志出现时触发的告警规则, 使写入端检测到的日志丢失同时触发通知
+func ErrorBudgetAlert(name string) AlertRule {
+	return AlertRule{
+		Name:        name,
+		Description: "slsh delivery success rate below error budget",
+		Query:       fmt.Sprintf(`%s: %s | select count(*) as alerts`, AlertKey, AlertErrorBudget),
+		Condition:   "alerts > 0",
+		Severity:    AlertSeverityCritical,
+	}
+}
+
+func (r AlertRule) job(project, store string) map[string]interface{} {
+	window := r.Window
+	if window <= 0 {
+		window = 15 * time.Minute
+	}
+	severity := r.Severity
+	if severity == 0 {
+		severity = AlertSeverityHigh
+	}
+	if r.Store != "" {
+		store = r.Store
+	}
+	keys := make([]string, 0, len(r.Labels))
+	for k := range r.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	labels := make([]map[string]string, 0, len(keys))
+	for _, k := range keys {
+		labels = append(labels, map[string]string{"key": k, "value": r.Labels[k]})
+	}
+	policy := map[string]interface{}{"alertPolicyId": "sls.builtin.dynamic", "repeatInterval": "1h", "useDefault": r.ActionPolicyID == ""}
+	if r.ActionPolicyID != "" {
+		policy["actionPolicyId"] = r.ActionPolicyID
+	}
+
+	return map[string]interface{}{
+		"name":        r.Name,
+		"displayName": validator.CoalesceStr(r.DisplayName, r.Name),
+		"description": r.Description,
+		"type":        "Alert",
+		"state":       jobState(r.Disabled),
+		"schedule":    jobSchedule(r.Interval),
+		"configuration": map[string]interface{}{
+			"version":   "2.0",
+			"type":      "default",
+			"dashboard": "internal-alert-analysis",
+			"queryList": []map[string]interface{}{{
+				"storeType":    "log",
+				"project":      project,
+				"store":        store,
+				"query":        r.Query,
+				"timeSpanType": "Relative",
+				"start":        "-" + strconv.Itoa(int(window/time.Second)) + "s",
+				"end":          "now",
+				"powerSqlMode": "auto",
+			}},
+			"groupConfiguration": map[string]interface{}{"type": "no_group", "fields": []string{}},
+			"joinConfigurations": []interface{}{},
+			"severityConfigurations": []map[string]interface{}{{
+				"severity":      severity,
+				"evalCondition": map[string]string{"condition": r.Condition, "countCondition": ""},
+			}},
+			"labels": labels,
+			"annotations": []map[string]string{
+				{"key": "title", "value": validator.CoalesceStr(r.DisplayName, r.Name)},
+				{"key": "desc", "value": r.Description},
+			},
+			"autoAnnotation":      true,
+			"sendResolved":        false,
+			"threshold":           1,
+			"noDataFire":          false,
+			"noDataSeverity":      AlertSeverityMedium,
+			"policyConfiguration": policy,
+		},
+	}
+}
+
+// ScheduledSQL 定时 SQL 作业, 定期对源日志库执行 SQL 并将结果写入目标日志库, 例如按级别汇总的错误数
+type ScheduledSQL struct {
+	Name        string        // 作业名称, 项目内唯一, 作为创建或更新的依据
+	DisplayName string        // 显示名称, 可选, 默认为 Name
+	Description string        // 描述, 可选
+	Store       string        // 源日志库, 可选, 默认为 ProvisionerConfig.Store
+	Script      string        // 查询与分析语句
+	Interval    time.Duration // 执行间隔, 同时作为每次处理的时间窗口, 可选, 默认为 1 分钟
+	DestProject string        // 目标项目, 可选, 默认为当前项目
+	DestStore   string        // 目标日志库
+	DestRegion  string        // 目标接入点, 例如 "cn-hangzhou-intranet.log.aliyuncs.com"
+	RoleArn     string        // 读取源日志库使用的 RAM 角色
+	DestRoleArn string        // 写入目标日志库使用的 RAM 角色, 可选, 默认为 RoleArn
+	From        time.Time     // 开始处理的时间, 可选, 默认为当前时间
+	Disabled    bool
+}
+
+func (s ScheduledSQL) job(project, store string) map[string]interface{} {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if s.Store != "" {
+		store = s.Store
+	}
+	from := s.From
+	if from.IsZero() {
+		from = time.Now()
+	}
+	minutes := strconv.Itoa(int(interval / time.Minute))
+	if minutes == "0" {
+		minutes = "1"
+	}
+
+	return map[string]interface{}{
+		"name":        s.Name,
+		"displayName": validator.CoalesceStr(s.DisplayName, s.Name),
+		"description": s.Description,
+		"type":        "ScheduledSQL",
+		"state":       jobState(s.Disabled),
+		"schedule":    jobSchedule(interval),
+		"configuration": map[string]interface{}{
+			"script":              s.Script,
+			"sqlType":             "searchQuery",
+			"resourcePool":        "enhanced",
+			"roleArn":             s.RoleArn,
+			"destRoleArn":         validator.CoalesceStr(s.DestRoleArn, s.RoleArn),
+			"sourceLogstore":      store,
+			"destEndpoint":        s.DestRegion,
+			"destProject":         validator.CoalesceStr(s.DestProject, project),
+			"destLogstore":        s.DestStore,
+			"dataFormat":          "log2log",
+			"fromTimeExpr":        "@m-" + minutes + "m",
+			"toTimeExpr":          "@m",
+			"maxRunTimeInSeconds": 600,
+			"maxRetries":          20,
+			"fromTime":            from.Unix(),
+			"toTime":              0,
+			"parameters":          map[string]string{},
+		},
+	}
+}
+
+// ApplyAlert 创建告警规则, 同名规则已存在时更新
+func (p *Provisioner) ApplyAlert(ctx context.Context, rule AlertRule) error {
+	if rule.Name == "" || rule.Query == "" {
+		return errors.New("slsh: alert rule requires Name and Query")
+	}
+	return p.apply(ctx, rule.Name, rule.job(p.project, p.store))
+}
+
+// ApplyScheduledSQL 创建定时 SQL 作业, 同名作业已存在时更新
+func (p *Provisioner) ApplyScheduledSQL(ctx context.Context, job ScheduledSQL) error {
+	if job.Name == "" || job.Script == "" || job.DestStore == "" || job.RoleArn == "" {
+		return errors.New("slsh: scheduled SQL requires Name, Script, DestStore and RoleArn")
+	}
+	return p.apply(ctx, job.Name, job.job(p.project, p.store))
+}
+
+// DeleteJob 删除告警规则或定时 SQL 作业, 不存在时返回 nil
+func (p *Provisioner) DeleteJob(ctx context.Context, name string) error {
+	err := p.do(ctx, http.MethodDelete, "/jobs/"+url.PathEscape(name), nil)
+	if statusCode(err) == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+func (p *Provisioner) apply(ctx context.Context, name string, job map[string]interface{}) error {
+	err := p.do(ctx, http.MethodPost, "/jobs", job)
+	var aErr *AliyunError
+	if errors.As(err, &aErr) && aErr.Code == errJobExist {
+		return p.do(ctx, http.MethodPut, "/jobs/"+url.PathEscape(name), job)
+	}
+	return err
+}
+
+func (p *Provisioner) do(ctx context.Context, method, path string, body interface{}) error {
+	w := p.writer
+	var resp *http.Response
+	var err error
+	if body == nil {
+		resp, err = w.request(ctx, method, path, nil, nil, nil)
+	} else {
+		resp, err = w.requestJSON(ctx, method, path, nil, body)
+	}
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp.Body, w.maxBody)
+	return w.validateResponse(resp)
+}
+
+func jobState(disabled bool) string {
+	if disabled {
+		return "Disabled"
+	}
+	return "Enabled"
+}
+
+func jobSchedule(interval time.Duration) map[string]interface{} {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return map[string]interface{}{"type": "FixedRate", "interval": strconv.Itoa(int(interval/time.Second)) + "s"}
+}
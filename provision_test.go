@@ -0,0 +1,128 @@
+package slsh
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvisioner(t *testing.T) {
+	type request struct {
+		method, path string
+		body         map[string]interface{}
+	}
+	var requests []request
+	exists := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		sig, err := signature(Secret("secret"), req)
+		assert.NoError(t, err)
+		assert.Equal(t, "LOG key:"+sig, req.Header.Get("Authorization"))
+		assert.Equal(t, "project.example.com", req.Host)
+
+		r := request{method: req.Method, path: req.URL.Path}
+		if data, _ := ioutil.ReadAll(req.Body); len(data) > 0 {
+			assert.NoError(t, json.Unmarshal(data, &r.body))
+		}
+		requests = append(requests, r)
+
+		switch {
+		case req.Method == http.MethodPost && exists:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errorCode":"JobAlreadyExist","errorMessage":"job already exists"}`))
+		case req.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errorCode":"JobNotExist","errorMessage":"job not exist"}`))
+		}
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	p, err := NewProvisioner(ProvisionerConfig{
+		Endpoint:     "example.com",
+		AccessKey:    "key",
+		AccessSecret: "secret",
+		Project:      "project",
+		Store:        "store",
+		HttpClient: &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+			return http.DefaultTransport.RoundTrip(req)
+		})},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("create", func(t *testing.T) {
+		requests = nil
+		rule := ErrorRateAlert("error-rate", "", nil, 0.05, 10*time.Minute)
+		rule.Labels = map[string]string{"team": "infra"}
+		assert.NoError(t, p.ApplyAlert(context.TODO(), rule))
+		if assert.Len(t, requests, 1) {
+			assert.Equal(t, http.MethodPost, requests[0].method)
+			assert.Equal(t, "/jobs", requests[0].path)
+			body := requests[0].body
+			assert.Equal(t, "Alert", body["type"])
+			assert.Equal(t, "Enabled", body["state"])
+			config := body["configuration"].(map[string]interface{})
+			query := config["queryList"].([]interface{})[0].(map[string]interface{})
+			assert.Equal(t, "store", query["store"])
+			assert.Equal(t, "-600s", query["start"])
+			assert.Equal(t, `* | select sum(if(try_cast("level" as bigint) in (0, 2, 3), 1, 0)) * 1.0 / count(*) as rate`, query["query"])
+			severity := config["severityConfigurations"].([]interface{})[0].(map[string]interface{})
+			assert.Equal(t, float64(AlertSeverityHigh), severity["severity"])
+			assert.Equal(t, "rate > 0.05", severity["evalCondition"].(map[string]interface{})["condition"])
+			assert.Equal(t, []interface{}{map[string]interface{}{"key": "team", "value": "infra"}}, config["labels"])
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		requests, exists = nil, true
+		defer func() { exists = false }()
+		assert.NoError(t, p.ApplyAlert(context.TODO(), ErrorBudgetAlert("budget")))
+		if assert.Len(t, requests, 2) {
+			assert.Equal(t, http.MethodPut, requests[1].method)
+			assert.Equal(t, "/jobs/budget", requests[1].path)
+			assert.Equal(t, requests[0].body, requests[1].body)
+		}
+	})
+
+	t.Run("scheduled sql", func(t *testing.T) {
+		requests = nil
+		job := ScheduledSQL{
+			Name:      "errors-by-level",
+			Script:    `* | select "level", count(*) as n group by "level"`,
+			Interval:  5 * time.Minute,
+			DestStore: "stats",
+			RoleArn:   "acs:ram::1:role/sql",
+			From:      time.Unix(1577836800, 0),
+		}
+		assert.NoError(t, p.ApplyScheduledSQL(context.TODO(), job))
+		if assert.Len(t, requests, 1) {
+			body := requests[0].body
+			assert.Equal(t, "ScheduledSQL", body["type"])
+			assert.Equal(t, "300s", body["schedule"].(map[string]interface{})["interval"])
+			config := body["configuration"].(map[string]interface{})
+			assert.Equal(t, "store", config["sourceLogstore"])
+			assert.Equal(t, "project", config["destProject"])
+			assert.Equal(t, "acs:ram::1:role/sql", config["destRoleArn"])
+			assert.Equal(t, "@m-5m", config["fromTimeExpr"])
+			assert.Equal(t, float64(1577836800), config["fromTime"])
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.NoError(t, p.DeleteJob(context.TODO(), "missing"))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.Error(t, p.ApplyAlert(context.TODO(), AlertRule{Name: "empty"}))
+		assert.Error(t, p.ApplyScheduledSQL(context.TODO(), ScheduledSQL{Name: "n", Script: "*"}))
+	})
+}
@@ -0,0 +1,117 @@
+package slsh
+
+import (
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/validator"
+)
+
+// ErrQuotaExceeded 表示该类日志占用的缓存已达配额
+var ErrQuotaExceeded = errors.New("slsh: quota exceeded")
+
+// 按字段值划分的缓存配额, 多个组件共用一个 Hook 时, 防止某类日志 (例如突发的访问日志) 占满缓存而延迟其他日志.
+// Fire 时超出配额的日志被丢弃并通过 Lifecycle.OnDrop 回调, Hook.Push 返回 ErrQuotaExceeded
+type Quota struct {
+	Key     string             // 用于分类的日志字段, 例如 "component", 缺少该字段的日志归为空字符串类
+	Shares  map[string]float64 // 各类日志最多占用缓存 (BufferSize, 按创建时的值计算) 的比例, 取值 (0, 1]
+	Default float64            // 未列出的类别的比例, 为 0 时不限制
+}
+
+func (q *Quota) validate() error {
+	if q.Key == "" {
+		return validator.IllegalArgument("Quota", "Key is required")
+	}
+	for class, share := range q.Shares {
+		if share <= 0 || share > 1 {
+			return validator.IllegalArgument("Quota", "share of "+class+" must be in (0, 1]")
+		}
+	}
+	if q.Default < 0 || q.Default > 1 {
+		return validator.IllegalArgument("Quota", "Default must be in [0, 1]")
+	}
+	return nil
+}
+
+// quotaLimiter 统计各类日志从写入缓存到发送结束之间占用的条数
+type quotaLimiter struct {
+	key      string
+	limits   map[string]int
+	fallback int // 未列出类别的上限, 为 0 时不限制
+	mu       sync.Mutex
+	pending  map[string]int
+}
+
+func newQuotaLimiter(q *Quota, capacity int) *quotaLimiter {
+	limit := func(share float64) int {
+		if share <= 0 {
+			return 0
+		}
+		return int(math.Max(1, math.Ceil(share*float64(capacity))))
+	}
+
+	l := &quotaLimiter{
+		key:      q.Key,
+		limits:   make(map[string]int, len(q.Shares)),
+		fallback: limit(q.Default),
+		pending:  make(map[string]int),
+	}
+	for class, share := range q.Shares {
+		l.limits[class] = limit(share)
+	}
+	return l
+}
+
+func (l *quotaLimiter) limit(class string) int {
+	if limit, ok := l.limits[class]; ok {
+		return limit
+	}
+	return l.fallback
+}
+
+// acquire 在配额内时占用一条并返回 true, l 为 nil 时不限制
+func (l *quotaLimiter) acquire(message Message) bool {
+	if l == nil {
+		return true
+	}
+	class := message.Contents[l.key]
+	limit := l.limit(class)
+	if limit == 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.pending[class] >= limit {
+		return false
+	}
+	l.pending[class]++
+	return true
+}
+
+func (l *quotaLimiter) release(messages ...Message) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, m := range messages {
+		class := m.Contents[l.key]
+		if l.limit(class) == 0 {
+			continue
+		}
+		if l.pending[class]--; l.pending[class] <= 0 {
+			delete(l.pending, class)
+		}
+	}
+}
+
+// wrap 在发送结束 (无论成功与否) 后释放配额
+func (l *quotaLimiter) wrap(flush func(...Message) error) func(...Message) error {
+	return func(messages ...Message) error {
+		defer l.release(messages...)
+		return flush(messages...)
+	}
+}
@@ -0,0 +1,76 @@
+package slsh
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuota(t *testing.T) {
+	newMessage := func(component string) Message {
+		return Message{Contents: map[string]string{"component": component}}
+	}
+
+	t.Run("limiter", func(t *testing.T) {
+		l := newQuotaLimiter(&Quota{Key: "component", Shares: map[string]float64{"access": 0.3}}, 10)
+
+		for i := 0; i < 3; i++ {
+			assert.True(t, l.acquire(newMessage("access")))
+		}
+		assert.False(t, l.acquire(newMessage("access")))
+		for i := 0; i < 20; i++ {
+			assert.True(t, l.acquire(newMessage("order")))
+		}
+
+		l.release(newMessage("access"), newMessage("order"))
+		assert.True(t, l.acquire(newMessage("access")))
+		assert.Equal(t, map[string]int{"access": 3}, l.pending)
+	})
+
+	t.Run("default share", func(t *testing.T) {
+		l := newQuotaLimiter(&Quota{Key: "component", Default: 0.01}, 10)
+		assert.True(t, l.acquire(newMessage("")))
+		assert.False(t, l.acquire(newMessage("")))
+		assert.True(t, l.acquire(newMessage("order")))
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		assert.Error(t, (&Quota{}).validate())
+		assert.Error(t, (&Quota{Key: "k", Shares: map[string]float64{"a": 0}}).validate())
+		assert.Error(t, (&Quota{Key: "k", Default: 2}).validate())
+		assert.NoError(t, (&Quota{Key: "k", Shares: map[string]float64{"a": 0.5}}).validate())
+	})
+
+	t.Run("hook", func(t *testing.T) {
+		chFlush := make(chan []string, 10)
+		quota := newQuotaLimiter(&Quota{Key: "component", Shares: map[string]float64{"access": 0.2}}, 10)
+		service := NewService(10, time.Hour, quota.wrap(func(messages ...Message) error {
+			var components []string
+			for _, m := range messages {
+				components = append(components, m.Contents["component"])
+			}
+			chFlush <- components
+			return nil
+		}))
+		converter := NewConverter(DefaultMessageKey, DefaultLevelKey, SyslogLevelMapping, nil, nil)
+		hook := NewCustom(time.Second, DefaultVisibleLevels, converter, &MockWriter{}, service)
+		hook.quota = quota
+		drops := map[string]int{}
+		hook.lifecycle.OnDrop = func(reason string, count int) { drops[reason] += count }
+
+		// 访问日志突发时只占用 2 条缓存, 错误日志不受影响
+		assert.NoError(t, hook.Push(context.TODO(), newMessage("access")))
+		assert.NoError(t, hook.Push(context.TODO(), newMessage("access")))
+		assert.Equal(t, ErrQuotaExceeded, hook.Push(context.TODO(), newMessage("access")))
+		assert.NoError(t, hook.Fire(&logrus.Entry{Level: logrus.InfoLevel, Data: logrus.Fields{"component": "access"}}))
+		assert.NoError(t, hook.Fire(&logrus.Entry{Level: logrus.ErrorLevel, Data: logrus.Fields{"component": "order"}}))
+		assert.Equal(t, map[string]int{DropQuota: 1}, drops)
+
+		assert.NoError(t, hook.Close())
+		assert.Equal(t, []string{"access", "access", "order"}, <-chFlush)
+		assert.Empty(t, quota.pending)
+	})
+}
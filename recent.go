@@ -0,0 +1,62 @@
+package slsh
+
+import (
+	"sync"
+	"time"
+)
+
+const DefaultRecentErrors = 10
+
+// 日志发送失败记录
+type ErrorRecord struct {
+	Time  time.Time
+	Err   error
+	Count int // 受影响的日志条数
+}
+
+// errorRing 保留最近的发送失败记录, 并在每次失败时调用 handler
+type errorRing struct {
+	mu      sync.Mutex
+	records []ErrorRecord
+	next    int
+	full    bool
+	handler func(ErrorRecord)
+}
+
+func newErrorRing(size int, handler func(ErrorRecord)) *errorRing {
+	if size <= 0 {
+		size = DefaultRecentErrors
+	}
+	return &errorRing{records: make([]ErrorRecord, size), handler: handler}
+}
+
+func (r *errorRing) record(err error, count int) {
+	rec := ErrorRecord{Time: time.Now(), Err: err, Count: count}
+
+	r.mu.Lock()
+	r.records[r.next] = rec
+	r.next = (r.next + 1) % len(r.records)
+	r.full = r.full || r.next == 0
+	r.mu.Unlock()
+
+	if r.handler != nil {
+		r.handler(rec)
+	}
+}
+
+// recent 按时间先后返回记录副本
+func (r *errorRing) recent() []ErrorRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]ErrorRecord(nil), r.records[:r.next]...)
+	}
+	return append(append([]ErrorRecord(nil), r.records[r.next:]...), r.records[:r.next]...)
+}
+
+// RecentErrors 返回最近的发送失败记录 (含缓存写入超时), 按时间先后排列,
+// 可用于在应用健康检查中提示日志投递降级
+func (h *Hook) RecentErrors() []ErrorRecord {
+	return h.errors.recent()
+}
@@ -0,0 +1,64 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentErrors(t *testing.T) {
+	t.Run("ring", func(t *testing.T) {
+		var handled []ErrorRecord
+		r := newErrorRing(3, func(rec ErrorRecord) { handled = append(handled, rec) })
+		assert.Empty(t, r.recent())
+
+		for i := 0; i < 5; i++ {
+			r.record(errors.New(strconv.Itoa(i)), i)
+		}
+
+		recent := r.recent()
+		if assert.Len(t, recent, 3) {
+			assert.Equal(t, "2", recent[0].Err.Error())
+			assert.Equal(t, "4", recent[2].Err.Error())
+			assert.Equal(t, 4, recent[2].Count)
+		}
+		assert.Len(t, handled, 5)
+	})
+
+	t.Run("fire", func(t *testing.T) {
+		service := &MockService{
+			onPush:  func(ctx context.Context, message Message) error { return context.DeadlineExceeded },
+			onStart: func() {},
+			onStop:  func(ctx context.Context) error { return nil },
+		}
+		hook := NewCustom(DefaultTimeout, DefaultVisibleLevels, &MockConverter{
+			onMessage: func(entry *logrus.Entry) Message { return Message{} },
+		}, &MockWriter{}, service)
+
+		logger := logrus.New()
+		logger.AddHook(hook)
+		logger.Info("dropped")
+
+		if recent := hook.RecentErrors(); assert.Len(t, recent, 1) {
+			assert.Equal(t, context.DeadlineExceeded, recent[0].Err)
+		}
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		chErr := make(chan int, 1)
+		s := NewService(2, time.Hour, func(messages ...Message) error { return errors.New("fail") })
+		s.OnError = func(err error, count int) { chErr <- count }
+
+		go s.Start()
+		assert.NoError(t, s.Push(context.TODO(), Message{}))
+		assert.NoError(t, s.Push(context.TODO(), Message{}))
+		assert.NoError(t, s.Stop(context.TODO()))
+
+		assert.Equal(t, 2, <-chErr)
+	})
+}
@@ -0,0 +1,31 @@
+package slsh
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetryElapsedError 表示批次的重试时长超过 WithMaxRetryElapsed 的限制而放弃发送, Err 为最后一次发送的错误.
+// 该批次随之进入失败处理 (设置 SpillDir 时落盘, 否则丢弃), 不再占用内存等待重试
+type RetryElapsedError struct {
+	Attempts int
+	Elapsed  time.Duration
+	Err      error
+}
+
+func (e *RetryElapsedError) Error() string {
+	return fmt.Sprintf("slsh: gave up retrying after %d attempts in %v: %v", e.Attempts, e.Elapsed.Truncate(time.Millisecond), e.Err)
+}
+
+func (e *RetryElapsedError) Unwrap() error { return e.Err }
+
+// WithMaxRetryElapsed 限制单批次从首次发送起的重试总时长, 下一次重试将超出 d 时不再等待, 返回 *RetryElapsedError,
+// 使长时间故障期间每个批次在内存中停留的时间有上限. 需配合 WithRetry 使用, d <= 0 时不限制
+func WithMaxRetryElapsed(d time.Duration) WriterOption {
+	return func(w *writer) { w.maxElapsed = d }
+}
+
+// retryExpired 判断等待 backoff 后重试是否超出重试总时长
+func (w *writer) retryExpired(first time.Time, backoff time.Duration) bool {
+	return w.maxElapsed > 0 && time.Since(first)+backoff > w.maxElapsed
+}
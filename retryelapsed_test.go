@@ -0,0 +1,75 @@
+package slsh
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxRetryElapsed(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	t.Run("give up", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		metrics := &MockMetrics{}
+		writer := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+			WithRetry(1000, 20*time.Millisecond), WithMaxRetryElapsed(50*time.Millisecond), WithMetrics(metrics))
+
+		err := writer.WriteMessage(ShortMessage)
+		var rErr *RetryElapsedError
+		if assert.True(t, errors.As(err, &rErr), err) {
+			// 第二次失败时已等待 20ms, 再等待 40ms 将超出 50ms
+			assert.Equal(t, 2, rErr.Attempts)
+			assert.True(t, rErr.Elapsed < 50*time.Millisecond, rErr.Elapsed)
+		}
+		assert.Equal(t, http.StatusServiceUnavailable, statusCode(err))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+		assert.Equal(t, int64(1), metrics.counter(MetricRetryElapsed))
+		assert.Equal(t, int64(1), metrics.counter(MetricRetried))
+	})
+
+	t.Run("spill", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "spill")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+		metrics := &MockMetrics{}
+		s, err := newSpill(dir, nil, metrics)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		writer := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+			WithRetry(1000, 10*time.Millisecond), WithMaxRetryElapsed(30*time.Millisecond))
+		start := time.Now()
+		err = s.wrap(writer.WriteMessage)(ShortMessage, LongMessage)
+		assert.True(t, time.Since(start) < time.Second)
+
+		var rErr *RetryElapsedError
+		assert.True(t, errors.As(err, &rErr), err)
+		assert.Equal(t, int64(2), metrics.counter(MetricSpilled))
+	})
+
+	t.Run("config", func(t *testing.T) {
+		c := Config{Endpoint: "example.com", AccessKey: "key", AccessSecret: "secret", Project: "project",
+			Store: "store", Topic: "topic", MaxRetryElapsed: -time.Second}
+		if err := c.validate(); assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "MaxRetryElapsed")
+		}
+	})
+}
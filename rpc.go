@@ -0,0 +1,132 @@
+package slsh
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 调用 STS, KMS 接口的默认超时, 凭证在锁内刷新, 超时期间使用该凭证的请求均会等待
+const DefaultRPCTimeout = 10 * time.Second
+
+// rpcClient 调用阿里云 RPC 风格接口 (STS, KMS 等), 签名规则参考:
+// https://help.aliyun.com/document_detail/315526.html
+type rpcClient struct {
+	endpoint string // 接入地址, 例如: "sts.aliyuncs.com"
+	version  string
+	client   *http.Client
+	timeout  time.Duration // 单次调用超时, 为 0 时使用 DefaultRPCTimeout
+}
+
+func (c *rpcClient) call(ctx context.Context, action string, params map[string]string, creds *Credentials, out interface{}) error {
+	query := url.Values{}
+	for k, v := range params {
+		query.Set(k, v)
+	}
+	query.Set("Action", action)
+	query.Set("Version", c.version)
+	query.Set("Format", "JSON")
+	query.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+
+	if creds != nil {
+		nonce := make([]byte, 16)
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return err
+		}
+		query.Set("AccessKeyId", creds.AccessKey)
+		query.Set("SignatureMethod", "HMAC-SHA1")
+		query.Set("SignatureVersion", "1.0")
+		query.Set("SignatureNonce", hex.EncodeToString(nonce))
+		if creds.SecurityToken != "" {
+			query.Set("SecurityToken", creds.SecurityToken)
+		}
+		sign, err := rpcSignature(creds.AccessSecret, http.MethodGet, query)
+		if err != nil {
+			return err
+		}
+		query.Set("Signature", sign)
+	}
+
+	client := c.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	timeout := c.timeout
+	if timeout <= 0 {
+		timeout = DefaultRPCTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+c.endpoint+"/?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp.Body, DefaultMaxResponseBody)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		data, err := ioutil.ReadAll(io.LimitReader(resp.Body, DefaultMaxResponseBody))
+		if err != nil {
+			return err
+		}
+		var body struct {
+			Code      string
+			Message   string
+			RequestId string
+		}
+		if err := json.Unmarshal(data, &body); err != nil || body.Code == "" {
+			return &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(data)}
+		}
+		return &AliyunError{
+			HTTPCode:  int32(resp.StatusCode),
+			Code:      body.Code,
+			Message:   body.Message,
+			RequestID: body.RequestId,
+		}
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, DefaultMaxResponseBody)).Decode(out)
+}
+
+func rpcSignature(secret Secret, method string, query url.Values) (string, error) {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = percentEncode(k) + "=" + percentEncode(query.Get(k))
+	}
+
+	signStr := method + "&" + percentEncode("/") + "&" + percentEncode(strings.Join(pairs, "&"))
+
+	mac := hmac.New(sha1.New, append(append([]byte{}, secret...), '&'))
+	if _, err := mac.Write([]byte(signStr)); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func percentEncode(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.Replace(s, "+", "%20", -1)
+	s = strings.Replace(s, "*", "%2A", -1)
+	return strings.Replace(s, "%7E", "~", -1)
+}
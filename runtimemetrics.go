@@ -0,0 +1,73 @@
+package slsh
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// 运行时指标日志的默认主题
+const DefaultRuntimeMetricsTopic = "runtime_metrics"
+
+// 运行时指标字段, GC 相关字段为距上次采集的增量
+const (
+	RuntimeGoroutines   = "goroutines"
+	RuntimeHeapAlloc    = "heap_alloc"     // 堆上存活对象占用的字节数
+	RuntimeHeapInuse    = "heap_inuse"     // 使用中的堆 span 字节数
+	RuntimeHeapObjects  = "heap_objects"   // 堆上存活对象数
+	RuntimeSys          = "sys"            // 从操作系统获取的内存总量
+	RuntimeNextGC       = "next_gc"        // 下一次 GC 的堆大小目标
+	RuntimeGCCount      = "gc_count"       // 新增的 GC 次数
+	RuntimeGCPauseTotal = "gc_pause_total" // 新增的 GC 停顿总时长 (纳秒)
+	RuntimeGCPauseMax   = "gc_pause_max"   // 新增 GC 中最长的一次停顿 (纳秒), 仅统计最近 256 次
+)
+
+// runtimeMetrics 根据两次 MemStats 快照生成指标日志内容
+func runtimeMetrics(prev, cur *runtime.MemStats) map[string]string {
+	var pauseMax uint64
+	from := prev.NumGC
+	if cur.NumGC-from > 256 {
+		from = cur.NumGC - 256
+	}
+	// 第 n 次 GC 的停顿时长保存在 PauseNs[(n+255)%256]
+	for n := from + 1; n <= cur.NumGC; n++ {
+		if pause := cur.PauseNs[(n+255)%256]; pause > pauseMax {
+			pauseMax = pause
+		}
+	}
+
+	return map[string]string{
+		RuntimeGoroutines:   strconv.Itoa(runtime.NumGoroutine()),
+		RuntimeHeapAlloc:    strconv.FormatUint(cur.HeapAlloc, 10),
+		RuntimeHeapInuse:    strconv.FormatUint(cur.HeapInuse, 10),
+		RuntimeHeapObjects:  strconv.FormatUint(cur.HeapObjects, 10),
+		RuntimeSys:          strconv.FormatUint(cur.Sys, 10),
+		RuntimeNextGC:       strconv.FormatUint(cur.NextGC, 10),
+		RuntimeGCCount:      strconv.FormatUint(uint64(cur.NumGC-prev.NumGC), 10),
+		RuntimeGCPauseTotal: strconv.FormatUint(cur.PauseTotalNs-prev.PauseTotalNs, 10),
+		RuntimeGCPauseMax:   strconv.FormatUint(pauseMax, 10),
+	}
+}
+
+// collectRuntimeMetrics 每隔 interval 采集一次运行时指标, 直接通过 w 发送 (不经过缓存), ctx 取消后退出.
+// 发送失败记入 RecentErrors, 不重试
+func (h *Hook) collectRuntimeMetrics(ctx context.Context, w Writer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	prev, cur := new(runtime.MemStats), new(runtime.MemStats)
+	runtime.ReadMemStats(prev)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			runtime.ReadMemStats(cur)
+			if err := w.WriteMessage(Message{Time: now, Contents: runtimeMetrics(prev, cur)}); err != nil {
+				h.errors.record(err, 1)
+			}
+			prev, cur = cur, prev
+		}
+	}
+}
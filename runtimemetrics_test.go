@@ -0,0 +1,76 @@
+package slsh
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeMetrics(t *testing.T) {
+	t.Run("delta", func(t *testing.T) {
+		prev := &runtime.MemStats{NumGC: 2, PauseTotalNs: 30}
+		prev.PauseNs[0], prev.PauseNs[1] = 10, 20
+
+		cur := *prev
+		cur.NumGC, cur.PauseTotalNs, cur.HeapAlloc = 4, 120, 1024
+		cur.PauseNs[2], cur.PauseNs[3] = 60, 30
+
+		contents := runtimeMetrics(prev, &cur)
+		assert.Equal(t, "2", contents[RuntimeGCCount])
+		assert.Equal(t, "90", contents[RuntimeGCPauseTotal])
+		assert.Equal(t, "60", contents[RuntimeGCPauseMax])
+		assert.Equal(t, "1024", contents[RuntimeHeapAlloc])
+		assert.NotEqual(t, "0", contents[RuntimeGoroutines])
+	})
+
+	t.Run("wrap", func(t *testing.T) {
+		// 两次采集间 GC 超过 256 次时只统计环形缓冲区中保留的停顿
+		prev := &runtime.MemStats{NumGC: 1}
+		cur := &runtime.MemStats{NumGC: 1000}
+		cur.PauseNs[(1000+255)%256] = 7
+		assert.Equal(t, "7", runtimeMetrics(prev, cur)[RuntimeGCPauseMax])
+		assert.Equal(t, "999", runtimeMetrics(prev, cur)[RuntimeGCCount])
+	})
+
+	t.Run("collect", func(t *testing.T) {
+		chMessage := make(chan Message, 10)
+		hook := &Hook{errors: newErrorRing(0, nil)}
+		ctx, cancel := context.WithCancel(context.TODO())
+		defer cancel()
+
+		w := MockWriter{onWriteMessage: func(messages ...Message) error {
+			for _, m := range messages {
+				chMessage <- m
+			}
+			return nil
+		}}
+		go hook.collectRuntimeMetrics(ctx, w, time.Millisecond)
+
+		select {
+		case m := <-chMessage:
+			assert.Contains(t, m.Contents, RuntimeHeapInuse)
+			assert.False(t, m.Time.IsZero())
+		case <-time.After(time.Second):
+			assert.Fail(t, "runtime metrics not collected")
+		}
+	})
+
+	t.Run("config", func(t *testing.T) {
+		c := Config{
+			Endpoint:     "example.com",
+			AccessKey:    "123",
+			AccessSecret: "321",
+			Project:      "test-project",
+			Store:        "test-store",
+			Topic:        "test-topic",
+		}
+		assert.NoError(t, c.validate())
+		assert.Equal(t, DefaultRuntimeMetricsTopic, c.RuntimeTopic)
+
+		c.RuntimeMetrics = -time.Second
+		assert.Error(t, c.validate())
+	})
+}
@@ -2,6 +2,7 @@ package slsh
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -9,16 +10,32 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/validator"
 )
 
 type service struct {
 	BufferSize int
 	Interval   time.Duration
+	MinGap     time.Duration // 两次刷新的最小间隔, 间隔内的刷新触发会被合并
+	IdleFlush  bool          // 流量稀疏时 (上一个刷新间隔内没有新日志) 立即发送新日志, 不等待刷新间隔
+	Adaptive   bool          // 收到限流错误时自动加大刷新间隔, 恢复后逐步还原
 	Flush      func(...Message) error
+	OnError    func(err error, count int) // 发送失败回调, 可选
+	Lifecycle  Lifecycle                  // 生命周期回调, 可选, 不含 OnClose
+	Clock      Clock                      // 时钟, 默认为 SystemClock, 需在 Start 前设置
+	Snapshot   func(...Message) error     // 关闭时若最近一次发送失败, 不再尝试发送而是保存缓存中的日志, 可选
+	Restore    []Message                  // 启动时先加入发送批次的日志, 例如上次关闭时保存的日志, 可选
+	Metrics    Metrics                    // 指标上报, 可选, 发送协程因 panic 重启时累加 MetricWorkerRestarts
+	pending    int                        // 正在发送的日志条数, 只由发送协程访问
 	chMessage  chan Message
+	chPriority chan Message
+	chFlush    chan struct{}
 	chQuit     chan struct{}
 	onClose    *sync.Once
-	stopped    bool
+	stopped    bool           // 由 mu 保护
+	pushing    sync.WaitGroup // 正在写入通道的调用, Stop 等待其结束后才关闭通道
+	mu         sync.RWMutex
 }
 
 func NewService(bufferSize int, interval time.Duration, flush func(...Message) error) *service {
@@ -27,77 +44,227 @@ func NewService(bufferSize int, interval time.Duration, flush func(...Message) e
 		Interval:   interval,
 		Flush:      flush,
 		chMessage:  make(chan Message, bufferSize),
+		chPriority: make(chan Message, bufferSize),
+		chFlush:    make(chan struct{}, 1),
 		chQuit:     make(chan struct{}),
 		onClose:    &sync.Once{},
+		Clock:      SystemClock,
 	}
 }
 
 func (s *service) Push(ctx context.Context, message Message) error {
+	if !s.enter(message) {
+		return nil
+	}
+	defer s.pushing.Done()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case s.chMessage <- message:
+		return nil
+	}
+}
+
+// enter 在 Stop 之前登记一次写入并返回 true, 已停止时丢弃日志并返回 false
+func (s *service) enter(message Message) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	if s.stopped {
 		s.trace("Discard message %v", message)
+		s.Lifecycle.drop(DropStopped, 1)
+		return false
+	}
+	s.pushing.Add(1)
+	return true
+}
+
+// ErrQueueFull 表示缓存已满, 非阻塞写入时返回
+var ErrQueueFull = errors.New("slsh: queue is full")
+
+// TryPush 与 Push 相同, 但缓存已满时不等待, 返回 ErrQueueFull
+func (s *service) TryPush(message Message) error {
+	if !s.enter(message) {
+		return nil
+	}
+	defer s.pushing.Done()
+
+	select {
+	case s.chMessage <- message:
 		return nil
+	default:
+		return ErrQueueFull
 	}
+}
+
+// PushPriority 将日志写入优先通道, 积压时先于 Push 写入的日志发送
+func (s *service) PushPriority(ctx context.Context, message Message) error {
+	if !s.enter(message) {
+		return nil
+	}
+	defer s.pushing.Done()
 
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
-	case s.chMessage <- message:
+	case s.chPriority <- message:
 		return nil
 	}
 }
 
+// FlushNow 通知发送协程立即发送缓存中的日志 (不受 MinGap 限制) 并清除对已发送日志的引用, 不等待发送完成
+func (s *service) FlushNow() {
+	select {
+	case s.chFlush <- struct{}{}:
+	default:
+	}
+}
+
+// Tune 在运行时调整批量大小与刷新间隔, 下一次刷新判断时生效, 非正数保持不变
+func (s *service) Tune(bufferSize int, interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.BufferSize = validator.CoalesceInt(bufferSize, s.BufferSize)
+	s.Interval = validator.CoalesceDur(interval, s.Interval)
+}
+
+func (s *service) batch() (int, time.Duration) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.BufferSize, s.Interval
+}
+
+// Start 运行发送协程, 直到 Stop 后发送完剩余日志. 发送中发生 panic 时丢弃当前批次, 通过 OnError 上报 PanicError,
+// 退避后重新开始接收日志, 参考 MaxRestartBackoff
 func (s *service) Start() {
 	s.trace("aliyun-log-service start")
 	defer s.trace("aliyun-log-service stopped")
+	s.Lifecycle.start()
+	supervise("sender", s.Metrics, s.recovered, s.run)
+}
 
-	flushTime := time.Now()
-	buffer := make([]Message, 0, s.BufferSize)
+// recovered 上报发送协程中恢复的 panic, 当前批次被丢弃
+func (s *service) recovered(err *PanicError) {
+	lost := s.pending
+	s.pending = 0
+	if s.OnError != nil {
+		s.OnError(err, lost)
+	}
+	s.Lifecycle.drop(DropPanic, lost)
+}
+
+func (s *service) run() {
+	bufferSize, interval := s.batch()
+	batcher := NewBatcher(bufferSize, interval, s.Clock)
+	batcher.MinGap = s.MinGap
+	batcher.IdleFlush = s.IdleFlush
+	for _, message := range s.Restore {
+		batcher.Add(message)
+	}
+	s.Restore = nil
+	var throttle throttle
+	var failing bool
 
 	tryFlush := func(force bool) {
-		if size := len(buffer); size <= 0 ||
-			!force && size < s.BufferSize && time.Since(flushTime) < s.Interval {
+		batcher.BufferSize, batcher.Interval = s.batch()
+		buffer := batcher.Ready(force)
+		if buffer == nil {
 			return
 		}
+		defer batcher.Done()
 
-		defer func() {
-			flushTime = time.Now()
-			buffer = buffer[:0]
-		}()
-
-		st := time.Now()
+		st := s.Clock.Now()
 
-		if err := s.Flush(buffer...); err != nil {
+		s.pending = len(buffer)
+		err := s.Flush(buffer...)
+		s.pending = 0
+		elapsed := s.Clock.Now().Sub(st)
+		failing = err != nil
+		s.Lifecycle.flush(len(buffer), elapsed, err)
+		if s.Adaptive {
+			batcher.MinGap = s.MinGap
+			if gap := throttle.observe(err); gap > batcher.MinGap {
+				batcher.MinGap = gap
+			}
+		}
+		if err != nil {
 			_, _ = fmt.Fprintf(os.Stderr, "Fail to flush logs: %v\n", err)
+			if s.OnError != nil {
+				s.OnError(err, len(buffer))
+			}
+			if _, spilled := err.(*spilledError); !spilled {
+				s.Lifecycle.drop(DropFailed, len(buffer))
+			}
 			return
 		}
 
-		s.trace("[%v] Flush %d logs",
-			time.Since(st).Truncate(time.Millisecond), len(buffer))
+		s.trace("[%v] Flush %d logs", elapsed.Truncate(time.Millisecond), len(buffer))
 	}
 
+	chPriority := s.chPriority
 Loop:
 	for {
-		timer := time.NewTimer(s.Interval / 10)
+		_, batcher.Interval = s.batch()
+
+		// 优先通道非空时先读取, 积压时高优先级日志先进入发送批次
+		select {
+		case message, ok := <-chPriority:
+			if ok {
+				batcher.Add(message)
+				tryFlush(false)
+				continue
+			}
+			chPriority = nil
+		default:
+		}
+
+		timer := s.Clock.NewTimer(batcher.Interval / 10)
 		select {
-		case <-timer.C:
+		case <-timer.C():
+		case message, ok := <-chPriority:
+			if !ok {
+				chPriority = nil
+				break
+			}
+			batcher.Add(message)
 		case message, ok := <-s.chMessage:
 			if !ok {
 				break Loop
 			}
-			buffer = append(buffer, message)
+			batcher.Add(message)
+		case <-s.chFlush:
+			tryFlush(true)
+			batcher.Release()
 		}
 		tryFlush(false)
 		timer.Stop()
 	}
 
+	for message := range s.chPriority {
+		batcher.Add(message)
+	}
+	// 远端不可用时直接保存剩余日志, 避免关闭时因重试超时而丢失
+	if buffer := batcher.Ready(true); failing && buffer != nil && s.Snapshot != nil {
+		if err := s.Snapshot(buffer...); err == nil {
+			s.trace("Snapshot %d logs", len(buffer))
+			batcher.Done()
+		}
+	}
 	tryFlush(true)
 	close(s.chQuit)
 }
 
 func (s *service) Stop(ctx context.Context) (err error) {
 	s.onClose.Do(func() {
+		s.mu.Lock()
 		s.stopped = true
-		close(s.chMessage)
+		s.mu.Unlock()
+		// 等待已登记的写入结束后再关闭通道, 发送协程持续接收, 阻塞的写入最迟在其 ctx 结束时返回
+		go func() {
+			s.pushing.Wait()
+			close(s.chPriority)
+			close(s.chMessage)
+		}()
 		select {
 		case <-ctx.Done():
 			err = ctx.Err()
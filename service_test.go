@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"math"
+	"sync"
 	"testing"
 	"time"
 
@@ -41,6 +42,29 @@ func TestService(t *testing.T) {
 		assert.Equal(t, 2+int(math.Ceil(float64(deliverSize)/float64(bufferSize))), cFlush)
 	})
 
+	t.Run("snapshot", func(t *testing.T) {
+		var flushed, saved []Message
+		s := NewService(2, time.Hour, func(messages ...Message) error {
+			flushed = append(flushed, messages...)
+			return errors.New("unavailable")
+		})
+		s.Restore = []Message{{Contents: map[string]string{"n": "0"}}}
+		s.Snapshot = func(messages ...Message) error { saved = append(saved, messages...); return nil }
+
+		go s.Start()
+		for _, n := range []string{"1", "2"} {
+			assert.NoError(t, s.Push(context.TODO(), Message{Contents: map[string]string{"n": n}}))
+		}
+		assert.NoError(t, s.Stop(context.TODO()))
+
+		if assert.Len(t, flushed, 2) {
+			assert.Equal(t, "0", flushed[0].Contents["n"])
+		}
+		if assert.Len(t, saved, 1) {
+			assert.Equal(t, "2", saved[0].Contents["n"])
+		}
+	})
+
 	t.Run("stopped", func(t *testing.T) {
 		cMessage := 0
 		s := NewService(1, time.Millisecond,
@@ -100,4 +124,130 @@ func TestService(t *testing.T) {
 		err = s.Stop(context.TODO())
 		assert.NoError(t, err)
 	})
+	t.Run("min gap", func(t *testing.T) {
+		cMessage := 0
+		cFlush := 0
+		s := NewService(1, time.Millisecond,
+			func(messages ...Message) error { cMessage += len(messages); cFlush++; return nil })
+		s.MinGap = time.Second
+
+		go s.Start()
+
+		for i := 0; i < 10; i++ {
+			err := s.Push(context.TODO(), Message{})
+			assert.NoError(t, err)
+		}
+
+		err := s.Stop(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, 10, cMessage)
+		assert.Equal(t, 1, cFlush)
+	})
+
+	t.Run("idle flush", func(t *testing.T) {
+		chFlush := make(chan int, 10)
+		s := NewService(10, time.Hour,
+			func(messages ...Message) error { chFlush <- len(messages); return nil })
+		s.MinGap = 0
+		s.IdleFlush = true
+
+		go s.Start()
+		defer func() { _ = s.Stop(context.TODO()) }()
+
+		assert.NoError(t, s.Push(context.TODO(), Message{}))
+		select {
+		case n := <-chFlush:
+			assert.Equal(t, 1, n)
+		case <-time.After(time.Second):
+			assert.Fail(t, "lone message not flushed")
+		}
+
+		// 刚发送过, 不再视为空闲, 恢复批量发送
+		assert.NoError(t, s.Push(context.TODO(), Message{}))
+		select {
+		case <-chFlush:
+			assert.Fail(t, "message flushed without batching")
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+	t.Run("try push", func(t *testing.T) {
+		s := NewService(1, time.Hour, func(messages ...Message) error { return nil })
+
+		assert.NoError(t, s.TryPush(Message{}))
+		assert.Equal(t, ErrQueueFull, s.TryPush(Message{}))
+
+		go s.Start()
+		assert.NoError(t, s.Stop(context.TODO()))
+		assert.NoError(t, s.TryPush(Message{}))
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		var flushed []string
+		chStarted, chGate := make(chan struct{}), make(chan struct{})
+		s := NewService(1, time.Hour, func(messages ...Message) error {
+			if len(flushed) == 0 {
+				close(chStarted)
+				<-chGate
+			}
+			for _, m := range messages {
+				flushed = append(flushed, m.Contents["id"])
+			}
+			return nil
+		})
+		s.MinGap = 0
+
+		go s.Start()
+
+		newMessage := func(id string) Message { return Message{Contents: map[string]string{"id": id}} }
+		assert.NoError(t, s.Push(context.TODO(), newMessage("n1")))
+		<-chStarted
+
+		// 发送阻塞期间普通日志积压, 优先日志应先于积压的普通日志发送
+		assert.NoError(t, s.Push(context.TODO(), newMessage("n2")))
+		assert.NoError(t, s.PushPriority(context.TODO(), newMessage("p1")))
+		close(chGate)
+
+		assert.NoError(t, s.Stop(context.TODO()))
+		assert.Equal(t, []string{"n1", "p1", "n2"}, flushed)
+	})
+	t.Run("push during stop", func(t *testing.T) {
+		var flushed, dropped int64
+		var mu sync.Mutex
+		s := NewService(10, time.Millisecond, func(messages ...Message) error {
+			mu.Lock()
+			flushed += int64(len(messages))
+			mu.Unlock()
+			return nil
+		})
+		s.Lifecycle = Lifecycle{OnDrop: func(reason string, count int) {
+			mu.Lock()
+			dropped += int64(count)
+			mu.Unlock()
+		}}
+
+		go s.Start()
+
+		// 并发写入与 Stop 竞争时不应向已关闭的通道写入, 每条日志要么发送要么计入丢弃
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					if i%2 == 0 {
+						_ = s.PushPriority(context.TODO(), Message{})
+					} else {
+						_ = s.Push(context.TODO(), Message{})
+					}
+				}
+			}(i)
+		}
+		time.Sleep(time.Millisecond)
+		assert.NoError(t, s.Stop(context.TODO()))
+		wg.Wait()
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, int64(800), flushed+dropped)
+	})
 }
@@ -0,0 +1,96 @@
+package slsh
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/validator"
+)
+
+// 运行时可调整的配置, 通过 Hook.UpdateSettings 热更新
+type Settings struct {
+	MinLevel    logrus.Level             // 推送日志的最低级别, 仅推送 level <= MinLevel 的日志, 为 0 (PanicLevel) 时保持不变, 只推送 Panic 日志时使用 SetMinLevel
+	SampleRates map[logrus.Level]float64 // 按级别采样比例, 取值 [0, 1], 未配置的级别全量推送
+	BufferSize  int                      // 本地缓存日志条数, 为 0 时保持不变
+	Interval    time.Duration            // 缓存刷新间隔, 为 0 时保持不变
+}
+
+func (s Settings) validate() error {
+	if s.MinLevel > logrus.TraceLevel {
+		return validator.IllegalArgument("MinLevel", "is out of range")
+	}
+	for level, rate := range s.SampleRates {
+		if rate < 0 || rate > 1 {
+			return validator.IllegalArgument("SampleRates",
+				"rate of level "+level.String()+" must be in [0, 1]")
+		}
+	}
+	return nil
+}
+
+func (s Settings) accept(level logrus.Level) bool {
+	if level > s.MinLevel {
+		return false
+	}
+	rate, ok := s.SampleRates[level]
+	return !ok || rate >= 1 || rand.Float64() < rate
+}
+
+// 支持运行时调整批量参数的 Service
+type Tuner interface {
+	Tune(bufferSize int, interval time.Duration)
+}
+
+// Settings 返回当前生效的运行时配置
+func (h *Hook) Settings() Settings {
+	return h.settings.Load().(Settings)
+}
+
+// UpdateSettings 线程安全地替换运行时配置, 可由配置监听或管理接口调用
+func (h *Hook) UpdateSettings(s Settings) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	current := h.Settings()
+	if s.MinLevel == logrus.PanicLevel {
+		s.MinLevel = current.MinLevel
+	}
+	s.BufferSize = validator.CoalesceInt(s.BufferSize, current.BufferSize)
+	s.Interval = validator.CoalesceDur(s.Interval, current.Interval)
+
+	rates := make(map[logrus.Level]float64, len(s.SampleRates))
+	for level, rate := range s.SampleRates {
+		rates[level] = rate
+	}
+	s.SampleRates = rates
+
+	if tuner, ok := h.service.(Tuner); ok &&
+		(s.BufferSize != current.BufferSize || s.Interval != current.Interval) {
+		tuner.Tune(s.BufferSize, s.Interval)
+	}
+
+	h.settings.Store(s)
+	return nil
+}
+
+// SetMinLevel 在运行时调整推送日志的最低级别, 其余配置保持不变, 适合在故障排查期间临时调高日志详细程度.
+// 只推送 VisibleLevels 中的级别, Fire 按最低级别过滤, 调整后无需重新 AddHook
+func (h *Hook) SetMinLevel(level logrus.Level) error {
+	if err := (Settings{MinLevel: level}).validate(); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.Settings()
+	s.MinLevel = level
+	h.settings.Store(s)
+	return nil
+}
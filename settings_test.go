@@ -0,0 +1,136 @@
+package slsh
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettings(t *testing.T) {
+	newHook := func(pushed *int) *Hook {
+		service := &MockService{
+			onPush:  func(ctx context.Context, message Message) error { *pushed++; return nil },
+			onStart: func() {},
+			onStop:  func(ctx context.Context) error { return nil },
+		}
+		converter := &MockConverter{
+			onMessage: func(entry *logrus.Entry) Message { return Message{} },
+		}
+		writer := &MockWriter{
+			onWriteMessage: func(messages ...Message) error { return nil },
+		}
+		return NewCustom(DefaultTimeout, logrus.AllLevels, converter, writer, service)
+	}
+
+	t.Run("min level", func(t *testing.T) {
+		pushed := 0
+		hook := newHook(&pushed)
+
+		err := hook.UpdateSettings(Settings{MinLevel: logrus.WarnLevel})
+		assert.NoError(t, err)
+
+		logger := logrus.New()
+		logger.AddHook(hook)
+		logger.Info("skipped")
+		logger.Warn("pushed")
+		logger.Error("pushed")
+
+		assert.Equal(t, 2, pushed)
+		assert.Equal(t, logrus.WarnLevel, hook.Settings().MinLevel)
+	})
+
+	t.Run("set min level", func(t *testing.T) {
+		pushed := 0
+		hook := newHook(&pushed)
+
+		// 在调整前注册, logrus 缓存了全部级别, 由 Fire 过滤
+		logger := logrus.New()
+		logger.SetLevel(logrus.TraceLevel)
+		logger.AddHook(hook)
+
+		assert.NoError(t, hook.SetMinLevel(logrus.ErrorLevel))
+		// Levels 不随最低级别变化, 由 Fire 过滤
+		assert.Equal(t, logrus.AllLevels, hook.Levels())
+		logger.Warn("skipped")
+		assert.Equal(t, 0, pushed)
+
+		assert.NoError(t, hook.SetMinLevel(logrus.WarnLevel))
+		logger.Info("skipped")
+		logger.Warn("pushed")
+		assert.Equal(t, 1, pushed)
+
+		assert.NoError(t, hook.SetMinLevel(logrus.DebugLevel))
+		logger.Debug("pushed")
+		assert.Equal(t, 2, pushed)
+
+		assert.Error(t, hook.SetMinLevel(logrus.TraceLevel+1))
+	})
+
+	t.Run("sampling", func(t *testing.T) {
+		pushed := 0
+		hook := newHook(&pushed)
+
+		err := hook.UpdateSettings(Settings{
+			MinLevel:    logrus.TraceLevel,
+			SampleRates: map[logrus.Level]float64{logrus.InfoLevel: 0},
+		})
+		assert.NoError(t, err)
+
+		logger := logrus.New()
+		logger.AddHook(hook)
+		for i := 0; i < 10; i++ {
+			logger.Info("dropped")
+		}
+		logger.Warn("pushed")
+
+		assert.Equal(t, 1, pushed)
+	})
+
+	t.Run("keep min level", func(t *testing.T) {
+		pushed := 0
+		hook := newHook(&pushed)
+		assert.NoError(t, hook.UpdateSettings(Settings{MinLevel: logrus.InfoLevel}))
+
+		// 只调整采样比例时 MinLevel 保持不变, 而不是变为零值 PanicLevel
+		err := hook.UpdateSettings(Settings{SampleRates: map[logrus.Level]float64{logrus.DebugLevel: 0}})
+		assert.NoError(t, err)
+		assert.Equal(t, logrus.InfoLevel, hook.Settings().MinLevel)
+
+		logger := logrus.New()
+		logger.AddHook(hook)
+		logger.Info("pushed")
+		assert.Equal(t, 1, pushed)
+
+		assert.NoError(t, hook.SetMinLevel(logrus.PanicLevel))
+		assert.Equal(t, logrus.PanicLevel, hook.Settings().MinLevel)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		hook := newHook(new(int))
+
+		err := hook.UpdateSettings(Settings{
+			MinLevel:    logrus.TraceLevel,
+			SampleRates: map[logrus.Level]float64{logrus.InfoLevel: 1.5},
+		})
+		assert.Error(t, err)
+
+		err = hook.UpdateSettings(Settings{MinLevel: logrus.TraceLevel + 1})
+		assert.Error(t, err)
+	})
+
+	t.Run("tune", func(t *testing.T) {
+		s := NewService(10, time.Second, func(messages ...Message) error { return nil })
+		hook := NewCustom(DefaultTimeout, DefaultVisibleLevels, &MockConverter{}, &MockWriter{}, s)
+		defer func() { _ = hook.Close() }()
+
+		err := hook.UpdateSettings(Settings{MinLevel: logrus.InfoLevel, BufferSize: 20})
+		assert.NoError(t, err)
+
+		size, interval := s.batch()
+		assert.Equal(t, 20, size)
+		assert.Equal(t, time.Second, interval)
+	})
+}
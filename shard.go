@@ -0,0 +1,147 @@
+package slsh
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// ShardMetric 返回分片写入实例的指标名称, 例如 ShardMetric(1, MetricRetried) 为 "shard_1_retried_requests".
+// 分片写入实例的指标同时以原名称上报, 原名称的指标为各分片之和
+func ShardMetric(shard int, name string) string {
+	return "shard_" + strconv.Itoa(shard) + "_" + name
+}
+
+type shardMetrics struct {
+	metrics Metrics
+	shard   int
+}
+
+func (m shardMetrics) Count(name string, delta int64) {
+	m.metrics.Count(name, delta)
+	m.metrics.Count(ShardMetric(m.shard, name), delta)
+}
+
+func (m shardMetrics) Observe(name string, value float64) {
+	m.metrics.Observe(name, value)
+	m.metrics.Observe(ShardMetric(m.shard, name), value)
+}
+
+// shardedWriter 将批次按分片键拆分给多个写入实例并行发送, 各实例使用独立的连接池, 并发限制与重试
+type shardedWriter struct {
+	writers []Writer
+	clients []*http.Client
+	key     string
+	metrics Metrics
+}
+
+// newShardedWriter 创建 n 个写入实例, build 使用复制的 client 与按分片上报的 metrics 创建实例
+func newShardedWriter(n int, key string, client *http.Client, metrics Metrics,
+	build func(client *http.Client, metrics Metrics) Writer) *shardedWriter {
+	s := &shardedWriter{writers: make([]Writer, n), clients: make([]*http.Client, n), key: key, metrics: metrics}
+	for i := range s.writers {
+		s.clients[i] = shardClient(client)
+		s.writers[i] = build(s.clients[i], shardMetrics{metrics: metrics, shard: i})
+	}
+	return s
+}
+
+// shard 返回日志所属的分片, 未设置分片键时按批次内顺序轮流分配
+func (s *shardedWriter) shard(i int, m Message) int {
+	if s.key == "" {
+		return i % len(s.writers)
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(m.Topic))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(m.Source))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(m.Contents[s.key]))
+	return int(h.Sum32() % uint32(len(s.writers)))
+}
+
+// WriteMessage 并行发送各分片的日志, 部分分片失败时返回 partialError, 落盘时只保存失败分片的日志
+func (s *shardedWriter) WriteMessage(messages ...Message) error {
+	groups := make([][]Message, len(s.writers))
+	for i, m := range messages {
+		k := s.shard(i, m)
+		groups[k] = append(groups[k], m)
+	}
+
+	errs := make([]error, len(s.writers))
+	var wg sync.WaitGroup
+	for i, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		s.metrics.Count(ShardMetric(i, MetricShardMessages), int64(len(group)))
+		wg.Add(1)
+		go func(i int, group []Message) {
+			defer wg.Done()
+			errs[i] = s.writers[i].WriteMessage(group...)
+		}(i, group)
+	}
+	wg.Wait()
+
+	var pending []Message
+	var first error
+	for i, err := range errs {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			pending = append(pending, groups[i]...)
+		}
+	}
+	if first != nil && len(pending) < len(messages) {
+		return &partialError{pending: pending, err: first}
+	}
+	return first
+}
+
+// Reset 关闭各分片的空闲连接, 各分片共用凭证, 只刷新一次
+func (s *shardedWriter) Reset() error {
+	for _, client := range s.clients[1:] {
+		client.CloseIdleConnections()
+	}
+	if r, ok := s.writers[0].(Resetter); ok {
+		return r.Reset()
+	}
+	return nil
+}
+
+func (s *shardedWriter) WarmUp(ctx context.Context) error {
+	for _, w := range s.writers {
+		if w, ok := w.(Warmer); ok {
+			if err := w.WarmUp(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Ping 检查网络, 凭证与日志库是否可用, 各分片使用相同的接入点与凭证, 只检查第一个分片
+func (s *shardedWriter) Ping(ctx context.Context) error {
+	if p, ok := s.writers[0].(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// shardClient 复制 client 并使用独立的连接池, 无法复制的自定义 Transport 保持共用
+func shardClient(client *http.Client) *http.Client {
+	rt := client.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	transport, ok := rt.(*http.Transport)
+	if !ok {
+		return client
+	}
+	clone := *client
+	clone.Transport = transport.Clone()
+	return &clone
+}
@@ -0,0 +1,125 @@
+package slsh
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+)
+
+func TestShardedWriter(t *testing.T) {
+	messages := make([]Message, 12)
+	for i := range messages {
+		messages[i] = Message{Contents: map[string]string{"n": strconv.Itoa(i), "user": strconv.Itoa(i % 4)}}
+	}
+
+	var mu sync.Mutex
+	received := make(map[int][][]Message)
+	failing := -1
+	newWriter := func(key string, metrics Metrics) *shardedWriter {
+		shard := 0
+		return newShardedWriter(3, key, http.DefaultClient, metrics, func(client *http.Client, metrics Metrics) Writer {
+			i := shard
+			shard++
+			return MockWriter{onWriteMessage: func(messages ...Message) error {
+				metrics.Count(MetricRetried, 1)
+				mu.Lock()
+				defer mu.Unlock()
+				if i == failing {
+					return errors.New("unavailable")
+				}
+				received[i] = append(received[i], messages)
+				return nil
+			}}
+		})
+	}
+
+	t.Run("round robin", func(t *testing.T) {
+		received = make(map[int][][]Message)
+		metrics := &MockMetrics{}
+		assert.NoError(t, newWriter("", metrics).WriteMessage(messages...))
+		for i := 0; i < 3; i++ {
+			if assert.Len(t, received[i], 1) {
+				assert.Len(t, received[i][0], 4)
+			}
+			assert.Equal(t, int64(4), metrics.counter(ShardMetric(i, MetricShardMessages)))
+			assert.Equal(t, int64(1), metrics.counter(ShardMetric(i, MetricRetried)))
+		}
+		assert.Equal(t, int64(3), metrics.counter(MetricRetried))
+	})
+
+	t.Run("key", func(t *testing.T) {
+		received = make(map[int][][]Message)
+		w := newWriter("user", &MockMetrics{})
+		assert.NoError(t, w.WriteMessage(messages...))
+		// 相同 user 的日志由同一分片发送
+		shards := make(map[string]int)
+		total := 0
+		for shard, batches := range received {
+			for _, m := range batches[0] {
+				if prev, ok := shards[m.Contents["user"]]; ok {
+					assert.Equal(t, prev, shard)
+				}
+				shards[m.Contents["user"]] = shard
+				total++
+			}
+		}
+		assert.Len(t, shards, 4)
+		assert.Equal(t, len(messages), total)
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		received, failing = make(map[int][][]Message), 1
+		defer func() { failing = -1 }()
+
+		err := newWriter("", &MockMetrics{}).WriteMessage(messages...)
+		var pErr *partialError
+		if assert.True(t, errors.As(err, &pErr)) {
+			assert.Equal(t, []Message{messages[1], messages[4], messages[7], messages[10]}, pErr.pending)
+		}
+		assert.Len(t, received, 2)
+
+		// 全部分片失败时返回原始错误
+		failing = 0
+		err = newWriter("", &MockMetrics{}).WriteMessage(messages[0])
+		assert.False(t, errors.As(err, &pErr))
+		assert.Error(t, err)
+	})
+}
+
+func TestShardedHook(t *testing.T) {
+	var mu sync.Mutex
+	logs := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, _ := ioutil.ReadAll(req.Body)
+		raw, err := uncompressLZ4(data, req.Header.Get("X-Log-Bodyrawsize"))
+		assert.NoError(t, err)
+		group := &api.LogGroup{}
+		assert.NoError(t, proto.Unmarshal(raw, group))
+		mu.Lock()
+		logs += len(group.Logs)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	metrics := &MockMetrics{}
+	sharded := newShardedWriter(2, "", http.DefaultClient, metrics, func(client *http.Client, metrics Metrics) Writer {
+		return NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, client, WithMetrics(metrics))
+	})
+	assert.NotEqual(t, sharded.clients[0].Transport, sharded.clients[1].Transport)
+	assert.NotEqual(t, http.DefaultTransport, sharded.clients[0].Transport)
+
+	assert.NoError(t, sharded.WriteMessage(Messages...))
+	assert.Equal(t, len(Messages), logs)
+	assert.NoError(t, sharded.Reset())
+}
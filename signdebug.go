@@ -0,0 +1,65 @@
+package slsh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 待签名字符串中 STS token 所在行的前缀
+const signTokenPrefix = "x-acs-security-token:"
+
+// redactSignString 隐去待签名字符串中的 STS token, 结果可能被写入日志或错误信息
+func redactSignString(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, signTokenPrefix) {
+			lines[i] = signTokenPrefix + "REDACTED"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiffSignString 逐行比较本地待签名字符串与期望值 (例如服务端或官方 SDK 输出的 SignString),
+// 返回差异描述, 一致时返回空字符串. 期望值中的字面量 "\n" 会被视为换行, 本地已隐去的 STS token 不参与比较
+func DiffSignString(local, expected string) string {
+	expected = strings.Replace(expected, `\n`, "\n", -1)
+	got, want := strings.Split(local, "\n"), strings.Split(expected, "\n")
+
+	var diffs []string
+	for i := 0; i < len(got) || i < len(want); i++ {
+		var g, w string
+		if i < len(got) {
+			g = got[i]
+		}
+		if i < len(want) {
+			w = want[i]
+		}
+		if g == signTokenPrefix+"REDACTED" && strings.HasPrefix(w, signTokenPrefix) {
+			continue
+		}
+		if g != w {
+			diffs = append(diffs, fmt.Sprintf("line %d (%s): got %q, want %q", i+1, signLine(i, g, w), g, w))
+		}
+	}
+	return strings.Join(diffs, "\n")
+}
+
+// signLine 返回待签名字符串第 i 行的含义
+func signLine(i int, lines ...string) string {
+	switch i {
+	case 0:
+		return "method"
+	case 1:
+		return "content-md5"
+	case 2:
+		return "content-type"
+	case 3:
+		return "date"
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "x-log-") || strings.HasPrefix(line, "x-acs-") {
+			return "header"
+		}
+	}
+	return "resource"
+}
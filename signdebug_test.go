@@ -0,0 +1,61 @@
+package slsh
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignatureDebug(t *testing.T) {
+	uri, _ := url.Parse("http://project.endpoint/logstores/store/shards/lb")
+
+	var signStr string
+	w := NewWriter(uri, "topic", "source", "key", Secret("very-secret"), http.DefaultClient,
+		WithSignatureDebug(func(s string) { signStr = s }))
+
+	req, err := w.buildRequest(newPayload([]byte("data"), 3, CompressTypeLZ4, ""), RequestOptions{}, nil)
+	assert.NoError(t, err)
+	assert.NotContains(t, signStr, "very-secret")
+	assert.Contains(t, signStr, "POST\n")
+	assert.Contains(t, signStr, "x-log-bodyrawsize:3")
+
+	digest, err := signature(Secret("very-secret"), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "LOG key:"+digest, req.Header.Get("Authorization"))
+
+	t.Run("security token", func(t *testing.T) {
+		creds := Credentials{AccessKey: "key", AccessSecret: Secret("very-secret"), SecurityToken: "sts-token"}
+		w := NewWriter(uri, "topic", "source", "", nil, http.DefaultClient,
+			WithCredentialsProvider(staticCredentials(creds)), WithSignatureDebug(func(s string) { signStr = s }))
+
+		_, err := w.buildRequest(newPayload([]byte("data"), 3, CompressTypeLZ4, ""), RequestOptions{}, nil)
+		assert.NoError(t, err)
+		assert.NotContains(t, signStr, "sts-token")
+		assert.Contains(t, signStr, "x-acs-security-token:REDACTED\n")
+		assert.Empty(t, DiffSignString(signStr, strings.Replace(signStr, "REDACTED", "sts-token", 1)))
+	})
+}
+
+func TestDiffSignString(t *testing.T) {
+	local := "POST\nMD5\napplication/x-protobuf\nDate\nx-log-apiversion:0.6.0\n/logstores/store/shards/lb"
+
+	t.Run("equal", func(t *testing.T) {
+		assert.Empty(t, DiffSignString(local, local))
+	})
+
+	t.Run("escaped", func(t *testing.T) {
+		expected := `POST\nMD5\napplication/x-protobuf\nOther\nx-log-apiversion:0.6.0\n/logstores/store/shards/lb`
+		assert.Equal(t, `line 4 (date): got "Date", want "Other"`, DiffSignString(local, expected))
+	})
+
+	t.Run("missing header", func(t *testing.T) {
+		expected := "POST\nMD5\napplication/x-protobuf\nDate\nx-log-apiversion:0.6.0\nx-log-bodyrawsize:3\n" +
+			"/logstores/store/shards/lb"
+		diff := DiffSignString(local, expected)
+		assert.Contains(t, diff, `line 6 (header): got "/logstores/store/shards/lb", want "x-log-bodyrawsize:3"`)
+		assert.Contains(t, diff, `line 7 (resource): got "", want "/logstores/store/shards/lb"`)
+	})
+}
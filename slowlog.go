@@ -0,0 +1,55 @@
+package slsh
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// SlowWrite 是耗时超过阈值的一次写入 (含重试) 的慢日志, 用于定位发送链路的长尾延迟.
+// 签名与网络耗时为各次尝试之和, 网络耗时包含等待并发名额, 重试退避的等待只计入 Total
+type SlowWrite struct {
+	Messages        int           // 日志条数, 发送 Payload 时为 0
+	RawBytes        int           // 压缩前的长度
+	CompressedBytes int           // 请求体长度
+	Attempts        int           // 发送次数
+	Encode          time.Duration // 编码耗时
+	Compress        time.Duration // 压缩耗时
+	Sign            time.Duration // 构造请求与签名耗时
+	Network         time.Duration // 发送请求并读取响应的耗时
+	Total           time.Duration // 总耗时
+	Err             error         // 最终结果, 成功时为空
+}
+
+func (s SlowWrite) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "total=%v encode=%v compress=%v sign=%v network=%v attempts=%d messages=%d bytes=%d/%d",
+		s.Total, s.Encode, s.Compress, s.Sign, s.Network, s.Attempts, s.Messages, s.CompressedBytes, s.RawBytes)
+	if s.Err != nil {
+		fmt.Fprintf(&b, " err=%q", s.Err.Error())
+	}
+	return b.String()
+}
+
+// WithSlowWrite 在一次写入 (含重试) 耗时超过 threshold 时回调 fn, fn 为空时输出到标准错误
+func WithSlowWrite(threshold time.Duration, fn func(SlowWrite)) WriterOption {
+	return func(w *writer) {
+		if fn == nil {
+			fn = func(s SlowWrite) { _, _ = fmt.Fprintf(os.Stderr, "slsh: slow write %v\n", s) }
+		}
+		w.slow, w.onSlow = threshold, fn
+	}
+}
+
+// reportSlow 计算总耗时, 超过阈值时回调慢日志
+func (w *writer) reportSlow(s *SlowWrite, start time.Time, err error) {
+	if w.slow <= 0 {
+		return
+	}
+	if s.Total = time.Since(start); s.Total < w.slow {
+		return
+	}
+	s.Err = err
+	w.onSlow(*s)
+}
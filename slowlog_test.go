@@ -0,0 +1,57 @@
+package slsh
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterSlowWrite(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		time.Sleep(20 * time.Millisecond)
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	var slow []SlowWrite
+	writer := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+		WithRetry(2, time.Millisecond), WithSlowWrite(30*time.Millisecond, func(s SlowWrite) { slow = append(slow, s) }))
+
+	t.Run("slow", func(t *testing.T) {
+		assert.NoError(t, writer.WriteMessage(Messages...))
+		if assert.Len(t, slow, 1) {
+			s := slow[0]
+			assert.Equal(t, len(Messages), s.Messages)
+			assert.Equal(t, 2, s.Attempts)
+			assert.True(t, s.Network >= 40*time.Millisecond)
+			assert.True(t, s.Total >= s.Encode+s.Compress+s.Sign+s.Network)
+			assert.NotZero(t, s.RawBytes)
+			assert.NotZero(t, s.CompressedBytes)
+			assert.NoError(t, s.Err)
+		}
+	})
+
+	t.Run("fast", func(t *testing.T) {
+		// 阈值远大于单次请求耗时, 避免机器繁忙时误报
+		slow = nil
+		writer := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+			WithSlowWrite(time.Second, func(s SlowWrite) { slow = append(slow, s) }))
+		assert.NoError(t, writer.WriteMessage(ShortMessage))
+		assert.Empty(t, slow)
+	})
+
+	t.Run("string", func(t *testing.T) {
+		s := SlowWrite{Total: time.Second, Network: time.Second, Attempts: 1, Messages: 2, Err: errors.New("timeout")}
+		assert.Equal(t, `total=1s encode=0s compress=0s sign=0s network=1s attempts=1 messages=2 bytes=0/0 err="timeout"`, s.String())
+	})
+}
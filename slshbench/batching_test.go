@@ -0,0 +1,101 @@
+package slshbench
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-log-go-sdk/producer"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+	"github.com/kyochou/go-logrus-aliyun-log-hook/slshooktest"
+)
+
+var contents = map[string]string{
+	"message": "request handled",
+	"level":   "info",
+	"path":    "/api/v1/users",
+	"status":  "200",
+}
+
+// BenchmarkBatching 对比 Hook 与 producer 从写入到全部发送完成的吞吐, 分配次数及每条日志的内存占用
+func BenchmarkBatching(b *testing.B) {
+	b.Run("hook", func(b *testing.B) {
+		srv := slshooktest.NewServer(nil)
+		defer srv.Close()
+
+		hook, err := slsh.New(slsh.Config{
+			Endpoint:     "bench.example.com",
+			AccessKey:    "any",
+			AccessSecret: "any",
+			Project:      "any",
+			Store:        "any",
+			Topic:        "any",
+			HttpClient:   srv.Client(),
+			BufferSize:   4096,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		measure(b, srv, func() error {
+			return hook.Push(context.Background(), slsh.Message{Time: time.Now(), Contents: contents})
+		}, hook.Close)
+	})
+
+	b.Run("producer", func(b *testing.B) {
+		srv := slshooktest.NewServer(nil)
+		defer srv.Close()
+
+		config := producer.GetDefaultProducerConfig()
+		config.Endpoint = srv.URL()
+		config.AccessKeyID = "any"
+		config.AccessKeySecret = "any"
+		config.MaxBatchCount = 4096
+		p := producer.InitProducer(config)
+		p.Start()
+
+		measure(b, srv, func() error {
+			return p.SendLog("any", "any", "any", "", producer.GenerateLog(uint32(time.Now().Unix()), contents))
+		}, func() error { p.SafeClose(); return nil })
+	})
+}
+
+// measure 执行 b.N 次 send 后调用 closeFn 等待发送完成, 额外上报:
+// msgs/s 吞吐, B/msg 每条日志的累计分配字节数, heap-B/msg 关闭前每条日志驻留的堆内存
+func measure(b *testing.B, srv *slshooktest.Server, send func() error, closeFn func() error) {
+	var before, pending, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := send(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&pending)
+	b.StartTimer()
+
+	if err := closeFn(); err != nil {
+		b.Fatal(err)
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+
+	if logs := srv.Logs(); logs != b.N {
+		b.Fatalf("delivered %d of %d messages", logs, b.N)
+	}
+
+	n := float64(b.N)
+	b.ReportMetric(n/b.Elapsed().Seconds(), "msgs/s")
+	b.ReportMetric(float64(after.TotalAlloc-before.TotalAlloc)/n, "B/msg")
+	heap := float64(0)
+	if pending.HeapInuse > before.HeapInuse {
+		heap = float64(pending.HeapInuse - before.HeapInuse)
+	}
+	b.ReportMetric(heap/n, "heap-B/msg")
+}
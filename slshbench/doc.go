@@ -0,0 +1,5 @@
+// Package slshbench 对比 slsh 与官方 SDK (github.com/aliyun/aliyun-log-go-sdk) 的单次写入及 producer 批量发送性能,
+// 独立为模块以免主模块引入 SDK 依赖.
+//
+//	cd slshbench && go test -run ^$ -bench . -count 5 -benchmem
+package slshbench
@@ -0,0 +1,14 @@
+module github.com/kyochou/go-logrus-aliyun-log-hook/slshbench
+
+go 1.20
+
+require (
+	github.com/aliyun/aliyun-log-go-sdk v0.1.83
+	github.com/golang/protobuf v1.5.3
+	github.com/kyochou/go-logrus-aliyun-log-hook v0.1.0
+)
+
+require google.golang.org/protobuf v1.33.0 // indirect
+
+// 本地开发时使用仓库中的核心包, 作为依赖被引用时 replace 不生效, 使用上面发布的版本
+replace github.com/kyochou/go-logrus-aliyun-log-hook => ../
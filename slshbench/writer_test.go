@@ -0,0 +1,104 @@
+package slshbench
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/golang/protobuf/proto"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+)
+
+// BenchmarkWriter 对比单次同步写入时 Writer 与 SDK PutLogs 的 CPU 与内存分配
+func BenchmarkWriter(b *testing.B) {
+	startServer := func(b *testing.B) *httptest.Server {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			b.StopTimer()
+			defer b.StartTimer()
+
+			switch req.Method {
+			case "GET":
+				if strings.Contains(req.URL.Path, "logstores") {
+					_, _ = w.Write([]byte(`{"logstoreName":""}`))
+				} else {
+					_, _ = w.Write([]byte(`{"projectName":"", "region":""}`))
+				}
+			case "POST":
+				w.WriteHeader(http.StatusOK)
+			}
+		})
+		return httptest.NewServer(handler)
+	}
+
+	b.Run("hook", func(b *testing.B) {
+		srv := startServer(b)
+		defer srv.Close()
+
+		msg := slsh.Message{
+			Time: time.Now(),
+			Contents: map[string]string{
+				"aaaaaaaaaaaa": "bbbbbbbbbbbb",
+				"cccccccccccc": "dddddddddddd",
+			},
+		}
+
+		uri, _ := url.Parse(srv.URL)
+		writer := slsh.NewWriter(uri, "any", "any", "any", slsh.Secret("any"), http.DefaultClient)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := writer.WriteMessage(msg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("sls", func(b *testing.B) {
+		srv := startServer(b)
+		defer srv.Close()
+
+		group := &sls.LogGroup{
+			Source: proto.String("any"),
+			Topic:  proto.String("any"),
+			Logs: []*sls.Log{{
+				Time: proto.Uint32(uint32(time.Now().Unix())),
+				Contents: []*sls.LogContent{{
+					Key:   proto.String("aaaaaaaaaaaa"),
+					Value: proto.String("bbbbbbbbbbbb"),
+				}, {
+					Key:   proto.String("cccccccccccc"),
+					Value: proto.String("dddddddddddd"),
+				}},
+			}},
+		}
+
+		client := &sls.Client{
+			Endpoint:        srv.URL,
+			AccessKeyID:     "any",
+			AccessKeySecret: "any",
+		}
+		defer func() { _ = client.Close() }()
+
+		project, err := client.GetProject("any")
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		store, err := project.GetLogStore("any")
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := store.PutLogs(group); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,32 @@
+// Package slshecho 将 slshhttp 访问日志中间件适配为 echo.MiddlewareFunc
+package slshecho
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/slshhttp"
+)
+
+// Middleware 返回使用默认配置的访问日志中间件
+func Middleware(pusher slshhttp.Pusher) echo.MiddlewareFunc {
+	return New(&slshhttp.Logger{Pusher: pusher})
+}
+
+func New(l *slshhttp.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				// 交由 echo 写入错误响应, 以便记录最终的状态码
+				c.Error(err)
+			}
+
+			res := c.Response()
+			l.Log(c.Request(), res.Status, int(res.Size), start)
+			return err
+		}
+	}
+}
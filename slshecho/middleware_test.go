@@ -0,0 +1,38 @@
+package slshecho
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+	"github.com/kyochou/go-logrus-aliyun-log-hook/slshhttp"
+)
+
+func TestMiddleware(t *testing.T) {
+	var messages []slsh.Message
+	e := echo.New()
+	e.Use(Middleware(PusherFunc(func(ctx context.Context, message slsh.Message) error {
+		messages = append(messages, message)
+		return nil
+	})))
+	e.GET("/ok", func(c echo.Context) error { return c.String(http.StatusOK, "ok") })
+	e.GET("/missing", func(c echo.Context) error { return echo.ErrNotFound })
+
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, "200", messages[0].Contents[slshhttp.KeyStatus])
+		assert.Equal(t, "2", messages[0].Contents[slshhttp.KeyBytes])
+		assert.Equal(t, "404", messages[1].Contents[slshhttp.KeyStatus])
+	}
+}
+
+type PusherFunc func(ctx context.Context, message slsh.Message) error
+
+func (f PusherFunc) Push(ctx context.Context, message slsh.Message) error { return f(ctx, message) }
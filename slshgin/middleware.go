@@ -0,0 +1,28 @@
+// Package slshgin 将 slshhttp 访问日志中间件适配为 gin.HandlerFunc
+package slshgin
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/slshhttp"
+)
+
+// Middleware 返回使用默认配置的访问日志中间件
+func Middleware(pusher slshhttp.Pusher) gin.HandlerFunc {
+	return New(&slshhttp.Logger{Pusher: pusher})
+}
+
+func New(l *slshhttp.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		l.Log(c.Request, c.Writer.Status(), size, start)
+	}
+}
@@ -0,0 +1,40 @@
+package slshgin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+	"github.com/kyochou/go-logrus-aliyun-log-hook/slshhttp"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var messages []slsh.Message
+	engine := gin.New()
+	engine.Use(Middleware(PusherFunc(func(ctx context.Context, message slsh.Message) error {
+		messages = append(messages, message)
+		return nil
+	})))
+	engine.GET("/users/:id", func(c *gin.Context) { c.String(http.StatusAccepted, "ok") })
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	if assert.Len(t, messages, 1) {
+		contents := messages[0].Contents
+		assert.Equal(t, "GET", contents[slshhttp.KeyMethod])
+		assert.Equal(t, "/users/1", contents[slshhttp.KeyPath])
+		assert.Equal(t, "202", contents[slshhttp.KeyStatus])
+		assert.Equal(t, "2", contents[slshhttp.KeyBytes])
+	}
+}
+
+type PusherFunc func(ctx context.Context, message slsh.Message) error
+
+func (f PusherFunc) Push(ctx context.Context, message slsh.Message) error { return f(ctx, message) }
@@ -0,0 +1,26 @@
+module github.com/kyochou/go-logrus-aliyun-log-hook/slshgrpc
+
+go 1.19
+
+require (
+	github.com/kyochou/go-logrus-aliyun-log-hook v0.1.0
+	github.com/stretchr/testify v1.8.4
+	google.golang.org/grpc v1.64.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/protobuf v1.5.4 // indirect
+	github.com/pierrec/lz4 v2.4.0+incompatible // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/sirupsen/logrus v1.9.3 // indirect
+	golang.org/x/net v0.22.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// 本地开发时使用仓库中的核心包, 作为依赖被引用时 replace 不生效, 使用上面发布的版本
+replace github.com/kyochou/go-logrus-aliyun-log-hook => ../
@@ -0,0 +1,82 @@
+// Package slshgrpc 提供将 gRPC 请求日志写入阿里云日志的服务端拦截器
+package slshgrpc
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+)
+
+const (
+	KeyMethod  = "grpc_method"
+	KeyCode    = "grpc_code"
+	KeyLatency = "latency_ms"
+	KeyPeer    = "peer"
+	KeyStream  = "stream"
+)
+
+// 日志写入接口, *slsh.Hook 实现了该接口
+type Pusher = slsh.Pusher
+
+// 请求日志记录器
+type Logger struct {
+	Pusher  Pusher
+	Timeout time.Duration     // 写缓存最大等待时间, 可选, 默认为 slsh.DefaultTimeout
+	Extra   map[string]string // 日志附加字段, 可选
+}
+
+// UnaryServerInterceptor 返回使用默认配置的一元拦截器
+func UnaryServerInterceptor(pusher Pusher) grpc.UnaryServerInterceptor {
+	return (&Logger{Pusher: pusher}).Unary
+}
+
+// StreamServerInterceptor 返回使用默认配置的流式拦截器
+func StreamServerInterceptor(pusher Pusher) grpc.StreamServerInterceptor {
+	return (&Logger{Pusher: pusher}).Stream
+}
+
+func (l *Logger) Unary(ctx context.Context, req interface{},
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	l.log(ctx, info.FullMethod, false, err, start)
+	return resp, err
+}
+
+func (l *Logger) Stream(srv interface{}, ss grpc.ServerStream,
+	info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	l.log(ss.Context(), info.FullMethod, true, err, start)
+	return err
+}
+
+func (l *Logger) log(ctx context.Context, method string, stream bool, err error, start time.Time) {
+	contents := make(map[string]string, len(l.Extra)+5)
+	for k, v := range l.Extra {
+		contents[k] = v
+	}
+	contents[KeyMethod] = method
+	contents[KeyCode] = status.Code(err).String()
+	contents[KeyLatency] = strconv.FormatInt(int64(time.Since(start)/time.Millisecond), 10)
+	contents[KeyStream] = strconv.FormatBool(stream)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		contents[KeyPeer] = slsh.NormalizeIP(p.Addr.String())
+	}
+
+	timeout := l.Timeout
+	if timeout <= 0 {
+		timeout = slsh.DefaultTimeout
+	}
+	// 请求结束后其 context 可能已取消, 写缓存使用独立的超时
+	pushCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_ = l.Pusher.Push(pushCtx, slsh.Message{Time: start, Contents: contents})
+}
@@ -0,0 +1,71 @@
+package slshgrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var messages []slsh.Message
+	interceptor := UnaryServerInterceptor(PusherFunc(func(ctx context.Context, message slsh.Message) error {
+		messages = append(messages, message)
+		return nil
+	}))
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000},
+	})
+	info := &grpc.UnaryServerInfo{FullMethod: "/demo.Service/Get"}
+
+	_, err := interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "missing")
+	})
+	assert.Error(t, err)
+
+	if assert.Len(t, messages, 1) {
+		contents := messages[0].Contents
+		assert.Equal(t, "/demo.Service/Get", contents[KeyMethod])
+		assert.Equal(t, codes.NotFound.String(), contents[KeyCode])
+		assert.Equal(t, "10.0.0.1", contents[KeyPeer])
+		assert.Equal(t, "false", contents[KeyStream])
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	var messages []slsh.Message
+	interceptor := StreamServerInterceptor(PusherFunc(func(ctx context.Context, message slsh.Message) error {
+		messages = append(messages, message)
+		return nil
+	}))
+
+	info := &grpc.StreamServerInfo{FullMethod: "/demo.Service/Watch"}
+	err := interceptor(nil, &mockStream{ctx: context.Background()}, info,
+		func(srv interface{}, stream grpc.ServerStream) error { return errors.New("any") })
+	assert.Error(t, err)
+
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, codes.Unknown.String(), messages[0].Contents[KeyCode])
+		assert.Equal(t, "true", messages[0].Contents[KeyStream])
+	}
+}
+
+type PusherFunc func(ctx context.Context, message slsh.Message) error
+
+func (f PusherFunc) Push(ctx context.Context, message slsh.Message) error { return f(ctx, message) }
+
+type mockStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *mockStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,176 @@
+// Package slshhttp 提供将 HTTP 访问日志直接写入阿里云日志的 net/http 中间件
+package slshhttp
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+)
+
+const (
+	KeyMethod   = "method"
+	KeyPath     = "path"
+	KeyStatus   = "status"
+	KeyLatency  = "latency_ms"
+	KeyBytes    = "bytes"
+	KeyClientIP = "client_ip"
+)
+
+// 日志写入接口, *slsh.Hook 实现了该接口
+type Pusher = slsh.Pusher
+
+// 访问日志记录器
+type Logger struct {
+	Pusher    Pusher
+	Timeout   time.Duration     // 写缓存最大等待时间, 可选, 默认为 slsh.DefaultTimeout
+	Extra     map[string]string // 日志附加字段, 可选
+	ProxyHops int               // 可信反向代理层数, 可选, 为 0 时客户端 IP 取 RemoteAddr, 不读取可被伪造的 X-Forwarded-For
+}
+
+// Middleware 返回使用默认配置的访问日志中间件
+func Middleware(pusher Pusher) func(http.Handler) http.Handler {
+	l := &Logger{Pusher: pusher}
+	return l.Handler
+}
+
+func (l *Logger) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw.wrap(), req)
+		l.Log(req, rw.status, rw.bytes, start)
+	})
+}
+
+// Log 记录一次请求, 供框架适配层复用
+func (l *Logger) Log(req *http.Request, status, bytes int, start time.Time) {
+	contents := make(map[string]string, len(l.Extra)+6)
+	for k, v := range l.Extra {
+		contents[k] = v
+	}
+	contents[KeyMethod] = req.Method
+	contents[KeyPath] = req.URL.Path
+	contents[KeyStatus] = strconv.Itoa(status)
+	contents[KeyLatency] = strconv.FormatInt(int64(time.Since(start)/time.Millisecond), 10)
+	contents[KeyBytes] = strconv.Itoa(bytes)
+	contents[KeyClientIP] = ClientIP(req, l.ProxyHops)
+
+	timeout := l.Timeout
+	if timeout <= 0 {
+		timeout = slsh.DefaultTimeout
+	}
+	// 请求结束后其 context 可能已取消, 写缓存使用独立的超时
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_ = l.Pusher.Push(ctx, slsh.Message{Time: start, Contents: contents})
+}
+
+// ClientIP 返回客户端 IP. hops 为可信反向代理层数, 为 0 时直接取 RemoteAddr;
+// 大于 0 时取 X-Forwarded-For 中由最外层代理追加的地址 (自右向左第 hops 个), 其次为 X-Real-Ip
+func ClientIP(req *http.Request, hops int) string {
+	if hops > 0 {
+		ips := strings.Split(strings.Join(req.Header["X-Forwarded-For"], ","), ",")
+		if len(ips) >= hops {
+			if ip := slsh.NormalizeIP(ips[len(ips)-hops]); ip != "" {
+				return ip
+			}
+		}
+		if ip := slsh.NormalizeIP(req.Header.Get("X-Real-Ip")); ip != "" {
+			return ip
+		}
+	}
+	return slsh.NormalizeIP(req.RemoteAddr)
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// wrap 返回与底层 ResponseWriter 支持相同可选接口 (http.Flusher, http.Hijacker, http.Pusher) 的包装,
+// 使处理函数对可选接口的类型断言结果不因中间件而改变
+func (w *responseWriter) wrap() http.ResponseWriter {
+	f, isFlusher := w.ResponseWriter.(http.Flusher)
+	h, isHijacker := w.ResponseWriter.(http.Hijacker)
+	p, isPusher := w.ResponseWriter.(http.Pusher)
+	if isFlusher {
+		f = flusher{w, f}
+	}
+
+	switch {
+	case isFlusher && isHijacker && isPusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, f, h, p}
+	case isFlusher && isHijacker:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+		}{w, f, h}
+	case isFlusher && isPusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Pusher
+		}{w, f, p}
+	case isHijacker && isPusher:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			http.Pusher
+		}{w, h, p}
+	case isFlusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+		}{w, f}
+	case isHijacker:
+		return struct {
+			*responseWriter
+			http.Hijacker
+		}{w, h}
+	case isPusher:
+		return struct {
+			*responseWriter
+			http.Pusher
+		}{w, p}
+	}
+	return w
+}
+
+// flusher 在 Flush 时记录响应头已写出 (未调用 WriteHeader 时状态码为 200)
+type flusher struct {
+	w *responseWriter
+	f http.Flusher
+}
+
+func (f flusher) Flush() {
+	f.w.wroteHeader = true
+	f.f.Flush()
+}
@@ -0,0 +1,167 @@
+package slshhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+)
+
+func TestMiddleware(t *testing.T) {
+	var messages []slsh.Message
+	pusher := PusherFunc(func(ctx context.Context, message slsh.Message) error {
+		_, ok := ctx.Deadline()
+		assert.True(t, ok)
+		messages = append(messages, message)
+		return nil
+	})
+
+	handler := Middleware(pusher)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/users?id=1", nil)
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if assert.Len(t, messages, 1) {
+		contents := messages[0].Contents
+		assert.Equal(t, "POST", contents[KeyMethod])
+		assert.Equal(t, "/users", contents[KeyPath])
+		assert.Equal(t, "201", contents[KeyStatus])
+		assert.Equal(t, "5", contents[KeyBytes])
+		assert.Equal(t, "192.0.2.1", contents[KeyClientIP])
+		assert.NotEmpty(t, contents[KeyLatency])
+		assert.WithinDuration(t, time.Now(), messages[0].Time, time.Second)
+	}
+}
+
+func TestMiddlewareCanceled(t *testing.T) {
+	var pushErr error
+	pusher := PusherFunc(func(ctx context.Context, message slsh.Message) error {
+		pushErr = ctx.Err()
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := Middleware(pusher)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// 客户端断开连接
+		cancel()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	assert.NoError(t, pushErr)
+}
+
+func TestResponseWriterInterfaces(t *testing.T) {
+	pusher := PusherFunc(func(ctx context.Context, message slsh.Message) error { return nil })
+	check := func(w http.ResponseWriter, flusher, hijacker, pusher bool) {
+		_, ok := w.(http.Flusher)
+		assert.Equal(t, flusher, ok, "Flusher")
+		_, ok = w.(http.Hijacker)
+		assert.Equal(t, hijacker, ok, "Hijacker")
+		_, ok = w.(http.Pusher)
+		assert.Equal(t, pusher, ok, "Pusher")
+	}
+
+	t.Run("recorder", func(t *testing.T) {
+		var flushed bool
+		recorder := httptest.NewRecorder()
+		Middleware(pusher)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			check(w, true, false, false)
+			w.(http.Flusher).Flush()
+			flushed = recorder.Flushed
+		})).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.True(t, flushed)
+	})
+
+	t.Run("server", func(t *testing.T) {
+		srv := httptest.NewServer(Middleware(pusher)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			check(w, true, true, false)
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if assert.NoError(t, err) {
+				_, _ = conn.Write([]byte("HTTP/1.1 204 No Content\r\n\r\n"))
+				_ = conn.Close()
+			}
+		})))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		if assert.NoError(t, err) {
+			_ = resp.Body.Close()
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		}
+	})
+
+	t.Run("plain", func(t *testing.T) {
+		Middleware(pusher)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			check(w, false, false, false)
+		})).ServeHTTP(plainWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, "192.0.2.1", ClientIP(req, 0))
+
+	t.Run("untrusted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", "1.2.3.4")
+		req.Header.Set("X-Real-Ip", "1.2.3.4")
+		assert.Equal(t, "192.0.2.1", ClientIP(req, 0))
+	})
+
+	t.Run("hops", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.2:1234"
+		// 客户端伪造的最左侧地址不会被采用
+		req.Header.Set("X-Forwarded-For", "1.2.3.4, 192.0.2.3")
+		req.Header.Add("X-Forwarded-For", "10.0.0.1")
+		assert.Equal(t, "10.0.0.1", ClientIP(req, 1))
+		assert.Equal(t, "192.0.2.3", ClientIP(req, 2))
+		assert.Equal(t, "10.0.0.2", ClientIP(req, 4))
+	})
+
+	t.Run("real ip", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.2:1234"
+		req.Header.Set("X-Real-Ip", "192.0.2.2")
+		assert.Equal(t, "192.0.2.2", ClientIP(req, 1))
+	})
+}
+
+func TestLoggerProxyHops(t *testing.T) {
+	var messages []slsh.Message
+	pusher := PusherFunc(func(ctx context.Context, message slsh.Message) error {
+		messages = append(messages, message)
+		return nil
+	})
+
+	l := &Logger{Pusher: pusher, ProxyHops: 1}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 192.0.2.3")
+	l.Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, "192.0.2.3", messages[0].Contents[KeyClientIP])
+	}
+}
+
+type PusherFunc func(ctx context.Context, message slsh.Message) error
+
+func (f PusherFunc) Push(ctx context.Context, message slsh.Message) error { return f(ctx, message) }
+
+// plainWriter 仅实现 http.ResponseWriter
+type plainWriter struct {
+	w http.ResponseWriter
+}
+
+func (w plainWriter) Header() http.Header         { return w.w.Header() }
+func (w plainWriter) Write(b []byte) (int, error) { return w.w.Write(b) }
+func (w plainWriter) WriteHeader(status int)      { w.w.WriteHeader(status) }
@@ -0,0 +1,157 @@
+package slshooktest
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 注入的故障类型
+const (
+	FaultLatency   = "latency"   // 请求前等待随机时长
+	FaultError     = "error"     // 不发送请求, 直接返回 503
+	FaultReset     = "reset"     // 不发送请求, 返回连接被重置的错误
+	FaultPartial   = "partial"   // 请求已送达服务端, 但响应丢失并返回连接被重置的错误, 重试会导致重复写入
+	FaultMalformed = "malformed" // 不发送请求, 返回内容被截断的 500 响应
+)
+
+// Chaos 按概率向 HTTP 请求注入延迟, 服务端错误, 连接重置及异常响应, 用于在上线前验证重试与降级配置.
+// 各概率取值 [0, 1], 延迟与其余故障独立判定, 其余故障按 Error, Reset, Partial, Malformed 的顺序至多注入一种
+type Chaos struct {
+	LatencyRate   float64       // 注入延迟的概率
+	MaxLatency    time.Duration // 延迟上限, 实际延迟在 [0, MaxLatency) 内随机
+	ErrorRate     float64       // 返回 503 的概率
+	ResetRate     float64       // 连接被重置的概率
+	PartialRate   float64       // 请求送达后响应丢失的概率
+	MalformedRate float64       // 返回截断响应的概率
+	Seed          int64         // 随机种子, 为 0 时使用当前时间
+
+	once     sync.Once
+	mu       sync.Mutex
+	rand     *rand.Rand
+	injected map[string]int
+}
+
+// Client 返回经过故障注入的 client 副本, client 为空时使用 http.DefaultClient, 可与 Server.Client 组合使用
+func (c *Chaos) Client(client *http.Client) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	wrapped := *client
+	wrapped.Transport = c.Transport(client.Transport)
+	return &wrapped
+}
+
+// Transport 返回在 next 之上注入故障的 RoundTripper, next 为空时使用 http.DefaultTransport
+func (c *Chaos) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		latency, fault := c.roll()
+		if latency > 0 {
+			timer := time.NewTimer(latency)
+			select {
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			case <-timer.C:
+			}
+		}
+
+		if fault != FaultPartial && fault != "" && req.Body != nil {
+			// 未转发的请求同样需按 RoundTripper 约定关闭请求体
+			_ = req.Body.Close()
+		}
+		switch fault {
+		case FaultError:
+			return newResponse(req, http.StatusServiceUnavailable,
+				`{"errorCode":"ServerBusy","errorMessage":"injected by chaos"}`), nil
+		case FaultReset:
+			return nil, resetError()
+		case FaultPartial:
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			_ = resp.Body.Close()
+			return nil, resetError()
+		case FaultMalformed:
+			return newResponse(req, http.StatusInternalServerError, `{"errorCode":"Internal`), nil
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// Injected 返回各类故障的注入次数
+func (c *Chaos) Injected() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	injected := make(map[string]int, len(c.injected))
+	for k, v := range c.injected {
+		injected[k] = v
+	}
+	return injected
+}
+
+func (c *Chaos) roll() (time.Duration, string) {
+	c.once.Do(func() {
+		seed := c.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		c.rand = rand.New(rand.NewSource(seed))
+		c.injected = make(map[string]int)
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var latency time.Duration
+	if c.MaxLatency > 0 && c.rand.Float64() < c.LatencyRate {
+		latency = time.Duration(c.rand.Int63n(int64(c.MaxLatency)))
+		c.injected[FaultLatency]++
+	}
+
+	p := c.rand.Float64()
+	for _, f := range []struct {
+		name string
+		rate float64
+	}{
+		{FaultError, c.ErrorRate},
+		{FaultReset, c.ResetRate},
+		{FaultPartial, c.PartialRate},
+		{FaultMalformed, c.MalformedRate},
+	} {
+		if p < f.rate {
+			c.injected[f.name]++
+			return latency, f.name
+		}
+		p -= f.rate
+	}
+	return latency, ""
+}
+
+func newResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func resetError() error {
+	return &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+}
@@ -0,0 +1,105 @@
+package slshooktest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+)
+
+func TestChaos(t *testing.T) {
+	send := func(chaos *Chaos) (*Server, error) {
+		srv := NewServer(nil)
+		defer srv.Close()
+
+		hook, err := slsh.New(slsh.Config{
+			Endpoint:     "example.com",
+			AccessKey:    "key",
+			AccessSecret: "secret",
+			Project:      "project",
+			Store:        "store",
+			Topic:        "topic",
+			HttpClient:   chaos.Client(srv.Client()),
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.NoError(t, hook.Push(context.TODO(), slsh.Message{}))
+		assert.NoError(t, hook.Close())
+
+		if records := hook.RecentErrors(); len(records) > 0 {
+			return srv, records[0].Err
+		}
+		return srv, nil
+	}
+
+	t.Run("error", func(t *testing.T) {
+		srv, err := send(&Chaos{ErrorRate: 1})
+		var aErr *slsh.AliyunError
+		if assert.True(t, errors.As(err, &aErr)) {
+			assert.Equal(t, int32(http.StatusServiceUnavailable), aErr.HTTPCode)
+		}
+		assert.Equal(t, 0, srv.Logs())
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		srv, err := send(&Chaos{ResetRate: 1})
+		var nErr net.Error
+		assert.True(t, errors.As(err, &nErr))
+		assert.Equal(t, 0, srv.Logs())
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		srv, err := send(&Chaos{PartialRate: 1})
+		var nErr net.Error
+		assert.True(t, errors.As(err, &nErr))
+		assert.Equal(t, 1, srv.Logs())
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := send(&Chaos{MalformedRate: 1})
+		var hErr *slsh.HTTPError
+		assert.True(t, errors.As(err, &hErr))
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		chaos := &Chaos{LatencyRate: 1, MaxLatency: time.Hour, Seed: 1}
+		client := chaos.Client(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return newResponse(req, http.StatusOK, "{}"), nil
+		})})
+
+		ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+		defer cancel()
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		_, err := client.Do(req.WithContext(ctx))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Equal(t, map[string]int{FaultLatency: 1}, chaos.Injected())
+	})
+
+	t.Run("rates", func(t *testing.T) {
+		chaos := &Chaos{ErrorRate: 0.2, ResetRate: 0.2, MalformedRate: 0.2, Seed: 1}
+		client := chaos.Client(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return newResponse(req, http.StatusOK, "{}"), nil
+		})})
+
+		const total = 1000
+		for i := 0; i < total; i++ {
+			resp, err := client.Post("http://example.com", "text/plain", strings.NewReader("x"))
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+		}
+		injected := chaos.Injected()
+		for _, fault := range []string{FaultError, FaultReset, FaultMalformed} {
+			assert.InDelta(t, 0.2*total, injected[fault], 0.05*total, fault)
+		}
+		assert.Zero(t, injected[FaultPartial])
+	})
+}
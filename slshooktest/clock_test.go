@@ -0,0 +1,110 @@
+package slshooktest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("timers", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		t1 := clock.NewTimer(2 * time.Second)
+		t2 := clock.NewTimer(time.Second)
+		assert.Equal(t, 2, clock.Timers())
+
+		assert.True(t, t2.Stop())
+		assert.False(t, t2.Stop())
+
+		clock.Advance(time.Second)
+		select {
+		case <-t1.C():
+			assert.Fail(t, "timer fired early")
+		default:
+		}
+
+		clock.Advance(time.Second)
+		assert.Equal(t, start.Add(2*time.Second), <-t1.C())
+		assert.Equal(t, start.Add(2*time.Second), clock.Now())
+		assert.False(t, t1.Stop())
+		assert.Equal(t, 0, clock.Timers())
+
+		select {
+		case <-clock.NewTimer(0).C():
+		default:
+			assert.Fail(t, "zero timer not fired")
+		}
+	})
+
+	t.Run("batcher", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		b := slsh.NewBatcher(3, time.Second, clock)
+		b.MinGap = 100 * time.Millisecond
+
+		b.Add(slsh.Message{})
+		b.Add(slsh.Message{})
+		assert.Nil(t, b.Ready(false))
+		assert.Len(t, b.Ready(true), 2)
+
+		clock.Advance(time.Second)
+		assert.Len(t, b.Ready(false), 2)
+		b.Done()
+		assert.Equal(t, 0, b.Len())
+
+		// 缓存已满, 但距上次发送不足 MinGap
+		for i := 0; i < 3; i++ {
+			b.Add(slsh.Message{})
+		}
+		assert.Nil(t, b.Ready(false))
+		clock.Advance(100 * time.Millisecond)
+		assert.Len(t, b.Ready(false), 3)
+		b.Done()
+	})
+
+	t.Run("idle flush", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		b := slsh.NewBatcher(10, time.Second, clock)
+		b.IdleFlush = true
+
+		b.Add(slsh.Message{})
+		assert.Len(t, b.Ready(false), 1)
+		b.Done()
+
+		clock.Advance(time.Millisecond)
+		b.Add(slsh.Message{})
+		assert.Nil(t, b.Ready(false))
+	})
+
+	t.Run("service", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		chFlush := make(chan int, 1)
+		s := slsh.NewService(10, time.Second, func(messages ...slsh.Message) error {
+			chFlush <- len(messages)
+			return nil
+		})
+		s.Clock = clock
+		s.MinGap = 0
+
+		go s.Start()
+		defer func() { _ = s.Stop(context.TODO()) }()
+
+		assert.NoError(t, s.Push(context.TODO(), slsh.Message{}))
+		for {
+			clock.BlockUntil(1)
+			clock.Advance(100 * time.Millisecond)
+			select {
+			case n := <-chFlush:
+				assert.Equal(t, 1, n)
+				assert.False(t, clock.Now().Sub(start) < time.Second)
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	})
+}
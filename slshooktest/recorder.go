@@ -0,0 +1,223 @@
+package slshooktest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Recorder 的工作模式
+const (
+	ModeRecord = "record" // 转发请求并记录请求与响应
+	ModeReplay = "replay" // 不访问网络, 按顺序回放记录的响应
+)
+
+// RecordEnv 为 "1" 时 ModeFromEnv 返回 ModeRecord, 用于在本地使用真实凭证重新生成 golden 文件
+const RecordEnv = "SLSH_RECORD"
+
+// ModeFromEnv 按 RecordEnv 返回工作模式, 默认回放, CI 无需网络与凭证
+func ModeFromEnv() string {
+	if os.Getenv(RecordEnv) == "1" {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// 记录的请求, 请求体为原始字节 (通常经过压缩), AccessKey ID 与安全令牌已脱敏
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// 记录的响应, 文本内容保存在 Body 中便于审阅, 二进制内容以 base64 保存在 Base64 中
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body,omitempty"`
+	Base64     []byte      `json:"base64,omitempty"`
+}
+
+// 一次请求与响应
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Recorder 将真实的请求与响应记录到 golden 文件, 之后离线按顺序回放, 使 CI 能覆盖真实的请求头, 签名与错误响应.
+// 回放时默认只校验请求的 method 与 URL, 可通过 Match 追加校验 (例如请求体)
+type Recorder struct {
+	Path  string                                                               // golden 文件路径
+	Mode  string                                                               // ModeRecord 或 ModeReplay
+	Match func(recorded RecordedRequest, req *http.Request, body []byte) error // 回放时的额外校验, 可选
+
+	mu           sync.Mutex
+	interactions []Interaction
+	next         int
+}
+
+// NewRecorder 创建 Recorder, 回放模式下读取 golden 文件
+func NewRecorder(path, mode string) (*Recorder, error) {
+	r := &Recorder{Path: path, Mode: mode}
+	switch mode {
+	case ModeRecord:
+	case ModeReplay:
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &r.interactions); err != nil {
+			return nil, fmt.Errorf("slshooktest: malformed golden file %s: %v", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("slshooktest: unknown recorder mode %q", mode)
+	}
+	return r, nil
+}
+
+// Client 返回经过记录或回放的 client 副本, client 为空时使用 http.DefaultClient
+func (r *Recorder) Client(client *http.Client) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	wrapped := *client
+	wrapped.Transport = r.Transport(client.Transport)
+	return &wrapped
+}
+
+// Transport 返回记录或回放的 RoundTripper, 记录模式下将请求转发给 next, next 为空时使用 http.DefaultTransport
+func (r *Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		var body []byte
+		if req.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(req.Body)
+			_ = req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if r.Mode == ModeReplay {
+			return r.replay(req, body)
+		}
+		return r.record(next, req, body)
+	})
+}
+
+func (r *Recorder) record(next http.RoundTripper, req *http.Request, body []byte) (*http.Response, error) {
+	forwarded := req.Clone(req.Context())
+	forwarded.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp, err := next.RoundTrip(forwarded)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := RecordedResponse{StatusCode: resp.StatusCode, Header: resp.Header}
+	if utf8.Valid(data) {
+		recorded.Body = string(data)
+	} else {
+		recorded.Base64 = data
+	}
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Request:  RecordedRequest{Method: req.Method, URL: req.URL.String(), Header: redact(req.Header), Body: body},
+		Response: recorded,
+	})
+	r.mu.Unlock()
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.interactions) {
+		return nil, fmt.Errorf("slshooktest: no recorded interaction left for %s %s", req.Method, req.URL)
+	}
+	i := r.interactions[r.next]
+	if i.Request.Method != req.Method || i.Request.URL != req.URL.String() {
+		return nil, fmt.Errorf("slshooktest: interaction %d: recorded %s %s, got %s %s",
+			r.next, i.Request.Method, i.Request.URL, req.Method, req.URL)
+	}
+	if r.Match != nil {
+		if err := r.Match(i.Request, req, body); err != nil {
+			return nil, fmt.Errorf("slshooktest: interaction %d: %v", r.next, err)
+		}
+	}
+	r.next++
+
+	data := []byte(i.Response.Body)
+	if i.Response.Base64 != nil {
+		data = i.Response.Base64
+	}
+	status := i.Response.StatusCode
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        i.Response.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
+
+// Interactions 返回已记录或已加载的请求与响应
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// Remaining 返回回放模式下尚未使用的记录数, 可在测试结束时断言为 0
+func (r *Recorder) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.interactions) - r.next
+}
+
+// Save 在记录模式下将请求与响应写入 golden 文件, 回放模式下不做任何事
+func (r *Recorder) Save() error {
+	if r.Mode != ModeRecord {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.Interactions(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.Path, append(data, '\n'), 0644)
+}
+
+// redact 复制请求头, 隐去 Authorization 中的 AccessKey ID 与 STS 安全令牌, 签名本身不泄露密钥, 予以保留
+func redact(header http.Header) http.Header {
+	h := header.Clone()
+	if auth := h.Get("Authorization"); strings.HasPrefix(auth, "LOG ") {
+		if i := strings.LastIndex(auth, ":"); i > 0 {
+			h.Set("Authorization", "LOG REDACTED"+auth[i:])
+		}
+	}
+	if h.Get("X-Acs-Security-Token") != "" {
+		h.Set("X-Acs-Security-Token", "REDACTED")
+	}
+	return h
+}
@@ -0,0 +1,99 @@
+package slshooktest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+)
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "golden.json")
+
+	message := slsh.Message{Time: time.Unix(1577836800, 0), Contents: map[string]string{"msg": "hi"}}
+	send := func(client *http.Client) []error {
+		u, _ := url.Parse("http://project.example.com/logstores/store/shards/lb")
+		w := slsh.NewWriter(u, "topic", "source", "key", slsh.Secret("secret"), client)
+		return []error{w.WriteMessage(message), w.WriteMessage(message)}
+	}
+
+	requests := 0
+	srv := NewServer(func(group *api.LogGroup) error {
+		if requests++; requests > 1 {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	rec, err := NewRecorder(path, ModeRecord)
+	if !assert.NoError(t, err) {
+		return
+	}
+	recorded := send(rec.Client(srv.Client()))
+	srv.Close()
+	assert.NoError(t, recorded[0])
+	assert.Error(t, recorded[1])
+	if !assert.NoError(t, rec.Save()) {
+		return
+	}
+
+	interactions := rec.Interactions()
+	if assert.NotEmpty(t, interactions) {
+		header := interactions[0].Request.Header
+		assert.Regexp(t, `^LOG REDACTED:`, header.Get("Authorization"))
+		assert.NotEmpty(t, interactions[0].Request.Body)
+		assert.Equal(t, http.StatusOK, interactions[0].Response.StatusCode)
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		rec, err := NewRecorder(path, ModeReplay)
+		if !assert.NoError(t, err) {
+			return
+		}
+		replayed := send(rec.Client(nil))
+		assert.NoError(t, replayed[0])
+		var aErr *slsh.AliyunError
+		if assert.True(t, errors.As(replayed[1], &aErr)) {
+			assert.Equal(t, int32(http.StatusInternalServerError), aErr.HTTPCode)
+			assert.Equal(t, "InternalServerError", aErr.Code)
+		}
+		assert.Equal(t, 0, rec.Remaining())
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		rec, err := NewRecorder(path, ModeReplay)
+		if !assert.NoError(t, err) {
+			return
+		}
+		client := rec.Client(nil)
+		send(client)
+		_, err = client.Get("http://project.example.com/")
+		assert.Error(t, err)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		rec, err := NewRecorder(path, ModeReplay)
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, err = rec.Client(nil).Get("http://other.example.com/")
+		assert.Error(t, err)
+		assert.Equal(t, len(interactions), rec.Remaining())
+	})
+
+	_, err = NewRecorder(path, "invalid")
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package slshooktest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pierrec/lz4"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+)
+
+// Server 模拟阿里云日志服务的 PutLogs 接口, 解压并解码请求, 统计收到的日志, 用于集成测试与压力测试.
+// 不校验签名, GET 与 HEAD 请求 (Ping, WarmUp) 直接返回成功
+type Server struct {
+	srv      *httptest.Server
+	handle   func(group *api.LogGroup) error
+	mu       sync.Mutex
+	requests int
+	logs     int
+}
+
+// NewServer 启动模拟服务, handle 在每次收到日志组时调用 (可为空), 返回错误时以 500 响应, 可用于模拟服务端故障
+func NewServer(handle func(group *api.LogGroup) error) *Server {
+	s := &Server{handle: handle}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *Server) Close() { s.srv.Close() }
+
+// URL 返回模拟服务地址, 例如: "http://127.0.0.1:8080", 用于无法自定义 HTTP 客户端的 SDK
+func (s *Server) URL() string { return s.srv.URL }
+
+// Client 返回将所有请求转发到模拟服务的 HTTP 客户端, 用作 slsh.Config.HttpClient, 接入点可任意填写
+func (s *Server) Client() *http.Client {
+	target, _ := url.Parse(s.srv.URL)
+	transport := s.srv.Client().Transport
+	return &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+		return transport.RoundTrip(req)
+	})}
+}
+
+// Requests 返回成功处理的 PutLogs 请求数
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Logs 返回成功处理的日志条数
+func (s *Server) Logs() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logs
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		_, _ = w.Write([]byte("{}"))
+		return
+	}
+
+	group, err := decode(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "PostBodyInvalid", err.Error())
+		return
+	}
+	if s.handle != nil {
+		if err := s.handle(group); err != nil {
+			writeError(w, http.StatusInternalServerError, "InternalServerError", err.Error())
+			return
+		}
+	}
+
+	s.mu.Lock()
+	s.requests++
+	s.logs += len(group.Logs)
+	s.mu.Unlock()
+}
+
+func decode(req *http.Request) (*api.LogGroup, error) {
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Header.Get("X-Log-Compresstype") == "lz4" {
+		rawSize, err := strconv.Atoi(req.Header.Get("X-Log-Bodyrawsize"))
+		if err != nil {
+			return nil, err
+		}
+		raw := make([]byte, rawSize)
+		n, err := lz4.UncompressBlock(data, raw)
+		if err != nil {
+			return nil, err
+		}
+		data = raw[:n]
+	}
+
+	group := &api.LogGroup{}
+	if err := proto.Unmarshal(data, group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"errorCode": code, "errorMessage": message})
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -0,0 +1,65 @@
+package slshooktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+)
+
+func TestServer(t *testing.T) {
+	newHook := func(t *testing.T, srv *Server) *slsh.Hook {
+		hook, err := slsh.New(slsh.Config{
+			Endpoint:     "example.com",
+			AccessKey:    "key",
+			AccessSecret: "secret",
+			Project:      "project",
+			Store:        "store",
+			Topic:        "topic",
+			HttpClient:   srv.Client(),
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return hook
+	}
+
+	t.Run("delivery", func(t *testing.T) {
+		var topics []string
+		srv := NewServer(func(group *api.LogGroup) error {
+			topics = append(topics, group.GetTopic())
+			return nil
+		})
+		defer srv.Close()
+
+		hook := newHook(t, srv)
+		assert.NoError(t, hook.Ping(context.TODO()))
+		for i := 0; i < 250; i++ {
+			assert.NoError(t, hook.Push(context.TODO(), slsh.Message{Contents: map[string]string{"i": "1"}}))
+		}
+		assert.NoError(t, hook.Close())
+
+		assert.Equal(t, 250, srv.Logs())
+		assert.Equal(t, srv.Requests(), len(topics))
+		assert.Equal(t, "topic", topics[0])
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		srv := NewServer(func(group *api.LogGroup) error { return errors.New("unavailable") })
+		defer srv.Close()
+
+		hook := newHook(t, srv)
+		assert.NoError(t, hook.Push(context.TODO(), slsh.Message{}))
+		assert.NoError(t, hook.Close())
+
+		assert.Equal(t, 0, srv.Logs())
+		if records := hook.RecentErrors(); assert.Len(t, records, 1) {
+			var aErr *slsh.AliyunError
+			assert.True(t, errors.As(records[0].Err, &aErr))
+		}
+	})
+}
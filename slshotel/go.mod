@@ -0,0 +1,27 @@
+module github.com/kyochou/go-logrus-aliyun-log-hook/slshotel
+
+go 1.20
+
+require (
+	github.com/kyochou/go-logrus-aliyun-log-hook v0.1.0
+	github.com/stretchr/testify v1.8.4
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/sdk v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/golang/protobuf v1.3.2 // indirect
+	github.com/pierrec/lz4 v2.4.0+incompatible // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/sirupsen/logrus v1.9.3 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// 本地开发时使用仓库中的核心包, 作为依赖被引用时 replace 不生效, 使用上面发布的版本
+replace github.com/kyochou/go-logrus-aliyun-log-hook => ../
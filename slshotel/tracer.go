@@ -0,0 +1,66 @@
+// Package slshotel 将日志发送链路的 span 接入 OpenTelemetry
+package slshotel
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+)
+
+// NewTracer 返回基于 OpenTelemetry 的 slsh.Tracer, 用法:
+//
+//	slsh.Config{Tracer: slshotel.NewTracer(otel.Tracer("slsh"))}
+func NewTracer(tracer trace.Tracer) slsh.Tracer {
+	return otelTracer{tracer: tracer}
+}
+
+type otelTracer struct {
+	tracer trace.Tracer
+}
+
+func (t otelTracer) StartSpan(ctx context.Context, name string) (context.Context, slsh.Span) {
+	kind := trace.SpanKindInternal
+	if name == slsh.SpanSend {
+		kind = trace.SpanKindClient
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(kind))
+	return ctx, otelSpan{span: span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) SetAttribute(key string, value interface{}) {
+	s.span.SetAttributes(attr(key, value))
+}
+
+func (s otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+func attr(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,44 @@
+package slshotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	slsh "github.com/kyochou/go-logrus-aliyun-log-hook"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := NewTracer(provider.Tracer("slsh"))
+
+	ctx, write := tracer.StartSpan(context.Background(), slsh.SpanWrite)
+	write.SetAttribute(slsh.AttrBatchSize, 2)
+
+	_, send := tracer.StartSpan(ctx, slsh.SpanSend)
+	send.SetAttribute(slsh.AttrStatusCode, 503)
+	send.End(errors.New("unavailable"))
+	write.End(nil)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		s, w := spans[0], spans[1]
+		assert.Equal(t, slsh.SpanSend, s.Name())
+		assert.Equal(t, trace.SpanKindClient, s.SpanKind())
+		assert.Equal(t, codes.Error, s.Status().Code)
+		assert.Contains(t, s.Attributes(), attribute.Int(slsh.AttrStatusCode, 503))
+		assert.Equal(t, w.SpanContext().SpanID(), s.Parent().SpanID())
+
+		assert.Equal(t, slsh.SpanWrite, w.Name())
+		assert.Contains(t, w.Attributes(), attribute.Int(slsh.AttrBatchSize, 2))
+		assert.Equal(t, codes.Unset, w.Status().Code)
+	}
+}
@@ -0,0 +1,270 @@
+package slsh
+
+import (
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/wal"
+)
+
+const (
+	spillFile    = "slsh.wal"
+	snapshotFile = "slsh.snapshot" // 关闭时保存的发送缓存, 下次启动时恢复
+)
+
+// spill 在发送失败时将日志写入本地文件, 在之后发送成功时重新发送
+type spill struct {
+	mu      sync.Mutex
+	path    string
+	metrics Metrics
+	aead    cipher.AEAD
+}
+
+// newSpill 创建落盘目录, keyFunc 不为空时使用 AES-GCM 加密每条记录
+func newSpill(dir string, keyFunc KeyFunc, metrics Metrics) (*spill, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	s := &spill{path: filepath.Join(dir, spillFile), metrics: metrics}
+	if keyFunc != nil {
+		aead, err := newAEAD(keyFunc)
+		if err != nil {
+			return nil, err
+		}
+		s.aead = aead
+	}
+	return s, nil
+}
+
+func (s *spill) wrap(flush func(...Message) error) func(...Message) error {
+	return func(messages ...Message) error {
+		if err := flush(messages...); err != nil {
+			var pErr *partialError
+			if errors.As(err, &pErr) {
+				messages = pErr.pending
+			}
+			if sErr := s.append(messages); sErr != nil {
+				return sErr
+			}
+			return &spilledError{err: err}
+		}
+		return s.replay(flush)
+	}
+}
+
+// lock 获取进程内及跨进程的锁, 同一落盘目录可能被多个进程共用
+func (s *spill) lock() (func(), error) {
+	s.mu.Lock()
+	l, err := wal.Lock(s.path + ".lock")
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		_ = l.Unlock()
+		s.mu.Unlock()
+	}, nil
+}
+
+func (s *spill) append(messages []Message) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.appendLocked(messages)
+}
+
+func (s *spill) appendLocked(messages []Message) error {
+	if err := s.write(s.path, messages); err != nil {
+		return err
+	}
+	s.metrics.Count(MetricSpilled, int64(len(messages)))
+	return nil
+}
+
+// write 将日志作为一条记录追加到 path, 需持有锁
+func (s *spill) write(path string, messages []Message) error {
+	payload, err := json.Marshal(messages)
+	if err != nil {
+		return err
+	}
+	if s.aead != nil {
+		if payload, err = seal(s.aead, payload); err != nil {
+			return err
+		}
+	}
+
+	w, err := wal.Open(path)
+	if err != nil {
+		return err
+	}
+	if err := w.Append(payload); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// read 读取并解码记录, 无法解密或解码的记录计入 MetricCorrupted
+func (s *spill) read(record []byte) ([]Message, bool) {
+	if s.aead != nil {
+		var err error
+		if record, err = unseal(s.aead, record); err != nil {
+			s.metrics.Count(MetricCorrupted, 1)
+			return nil, false
+		}
+	}
+
+	var messages []Message
+	if err := json.Unmarshal(record, &messages); err != nil {
+		s.metrics.Count(MetricCorrupted, 1)
+		return nil, false
+	}
+	return messages, true
+}
+
+// snapshot 保存关闭时尚未发送的日志, 由下次启动时的 restore 读取
+func (s *spill) snapshot(messages ...Message) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.write(filepath.Join(filepath.Dir(s.path), snapshotFile), messages); err != nil {
+		return err
+	}
+	s.metrics.Count(MetricSnapshot, int64(len(messages)))
+	return nil
+}
+
+// restore 读取并删除上次关闭时保存的日志, 多个进程共用落盘目录时由先启动的进程恢复
+func (s *spill) restore() ([]Message, error) {
+	path := filepath.Join(filepath.Dir(s.path), snapshotFile)
+	if !exists(path) {
+		return nil, nil
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	records, corrupted, err := wal.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if corrupted > 0 {
+		s.metrics.Count(MetricCorrupted, int64(corrupted))
+	}
+
+	var restored []Message
+	for _, record := range records {
+		if messages, ok := s.read(record); ok {
+			restored = append(restored, messages...)
+		}
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// replay 重新发送落盘的日志, 发送失败的记录重新写回文件
+func (s *spill) replay(flush func(...Message) error) error {
+	// 无落盘文件时跳过加锁, 每次发送成功都会调用
+	if !exists(s.path) && !exists(s.path+".replay") {
+		return nil
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// 上次重放中断时遗留的文件优先处理, 其中已发送成功的记录摘要保存在 .sent 文件中, 不再重复发送
+	pending, sentPath := s.path+".replay", s.path+".sent"
+	seen := make(map[string]bool)
+	if exists(pending) {
+		hashes, _, err := wal.ReadFile(sentPath)
+		if err != nil {
+			return err
+		}
+		for _, hash := range hashes {
+			seen[string(hash)] = true
+		}
+	} else {
+		if !exists(s.path) {
+			return nil
+		}
+		if err := os.Remove(sentPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Rename(s.path, pending); err != nil {
+			return err
+		}
+	}
+
+	records, corrupted, err := wal.ReadFile(pending)
+	if err != nil {
+		return err
+	}
+	if corrupted > 0 {
+		s.metrics.Count(MetricCorrupted, int64(corrupted))
+	}
+
+	sent, err := wal.Open(sentPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = sent.Close() }()
+
+	var failed error
+	for _, record := range records {
+		messages, ok := s.read(record)
+		if !ok {
+			continue
+		}
+
+		// 只跳过上次重放中已记录发送成功的批次, 同一文件中内容相同的批次可能是正常的重复日志
+		hash := HashMessages(messages...)
+		if seen[hash] {
+			s.metrics.Count(MetricDuplicated, 1)
+			continue
+		}
+
+		if failed == nil {
+			if failed = flush(messages...); failed == nil {
+				s.metrics.Count(MetricReplayed, int64(len(messages)))
+				if err := sent.Append([]byte(hash)); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := s.appendLocked(messages); err != nil {
+			return err
+		}
+	}
+	// Windows 上无法删除仍打开的文件
+	if err := sent.Close(); err != nil {
+		return err
+	}
+	if err := os.Remove(pending); err != nil {
+		return err
+	}
+	return os.Remove(sentPath)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return !os.IsNotExist(err)
+}
@@ -0,0 +1,70 @@
+package slsh
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// 落盘加密密钥来源, 返回 16/24/32 字节的 AES 密钥, 可对接 KMS 等密钥管理服务
+type KeyFunc func() ([]byte, error)
+
+// KeyFromEnv 从环境变量读取 base64 编码的密钥
+func KeyFromEnv(name string) KeyFunc {
+	return func() ([]byte, error) {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("spill key: environment variable %q not set", name)
+		}
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	}
+}
+
+// KeyFromFile 从文件读取 base64 编码的密钥
+func KeyFromFile(path string) KeyFunc {
+	return func() ([]byte, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	}
+}
+
+var errCiphertext = errors.New("spill: ciphertext too short")
+
+func newAEAD(keyFunc KeyFunc) (cipher.AEAD, error) {
+	key, err := keyFunc()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 加密后的格式为 nonce | ciphertext
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func unseal(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errCiphertext
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,174 @@
+package slsh
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/wal"
+)
+
+func TestSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	metrics := &MockMetrics{}
+	s, err := newSpill(dir, nil, metrics)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var delivered []Message
+	failing := true
+	flush := s.wrap(func(messages ...Message) error {
+		if failing {
+			return errors.New("unavailable")
+		}
+		delivered = append(delivered, messages...)
+		return nil
+	})
+
+	assert.Error(t, flush(ShortMessage))
+	assert.Error(t, flush(LongMessage))
+	assert.Equal(t, int64(2), metrics.counter(MetricSpilled))
+
+	// 在落盘文件尾部追加损坏的数据
+	f, err := os.OpenFile(filepath.Join(dir, spillFile), os.O_APPEND|os.O_WRONLY, 0600)
+	if assert.NoError(t, err) {
+		_, _ = f.Write([]byte("garbage"))
+		_ = f.Close()
+	}
+
+	failing = false
+	assert.NoError(t, flush(ShortMessage))
+	if assert.Len(t, delivered, 3) {
+		assert.Equal(t, ShortMessage.Contents, delivered[1].Contents)
+		assert.Equal(t, LongMessage.Contents, delivered[2].Contents)
+		assert.True(t, LongMessage.Time.Equal(delivered[2].Time))
+	}
+	assert.Equal(t, int64(2), metrics.counter(MetricReplayed))
+	assert.Equal(t, int64(1), metrics.counter(MetricCorrupted))
+
+	_, err = os.Stat(filepath.Join(dir, spillFile))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSpillEncrypted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	keyFile := filepath.Join(dir, "key")
+	if !assert.NoError(t, ioutil.WriteFile(keyFile, []byte(key+"\n"), 0600)) {
+		return
+	}
+
+	s, err := newSpill(dir, KeyFromFile(keyFile), &MockMetrics{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, s.append([]Message{ShortMessage}))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, spillFile))
+	if assert.NoError(t, err) {
+		assert.NotContains(t, string(data), "value")
+	}
+
+	var delivered []Message
+	assert.NoError(t, s.replay(func(messages ...Message) error {
+		delivered = append(delivered, messages...)
+		return nil
+	}))
+	if assert.Len(t, delivered, 1) {
+		assert.Equal(t, ShortMessage.Contents, delivered[0].Contents)
+	}
+
+	_, err = newSpill(dir, KeyFromEnv("SLSH_TEST_MISSING_KEY"), &MockMetrics{})
+	assert.Error(t, err)
+}
+
+func TestSpillInterruptedReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	metrics := &MockMetrics{}
+	s, err := newSpill(dir, nil, metrics)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// 模拟上次重放中断: 第一条记录已发送, 内容相同的后两条记录均未发送
+	assert.NoError(t, s.append([]Message{ShortMessage}))
+	assert.NoError(t, s.append([]Message{LongMessage}))
+	assert.NoError(t, s.append([]Message{LongMessage}))
+	path := filepath.Join(dir, spillFile)
+	assert.NoError(t, os.Rename(path, path+".replay"))
+	sent, err := wal.Open(path + ".sent")
+	if assert.NoError(t, err) {
+		assert.NoError(t, sent.Append([]byte(HashMessages(ShortMessage))))
+		assert.NoError(t, sent.Close())
+	}
+
+	var delivered []Message
+	assert.NoError(t, s.replay(func(messages ...Message) error {
+		delivered = append(delivered, messages...)
+		return nil
+	}))
+	if assert.Len(t, delivered, 2) {
+		assert.Equal(t, LongMessage.Contents, delivered[0].Contents)
+		assert.Equal(t, LongMessage.Contents, delivered[1].Contents)
+	}
+	assert.Equal(t, int64(1), metrics.counter(MetricDuplicated))
+
+	for _, suffix := range []string{"", ".replay", ".sent"} {
+		assert.False(t, exists(path+suffix), suffix)
+	}
+}
+
+func TestSpillSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	metrics := &MockMetrics{}
+	s, err := newSpill(dir, KeyFromEnv("SLSH_TEST_SNAPSHOT_KEY"), metrics)
+	assert.Error(t, err)
+	_ = os.Setenv("SLSH_TEST_SNAPSHOT_KEY", key)
+	defer func() { _ = os.Unsetenv("SLSH_TEST_SNAPSHOT_KEY") }()
+	if s, err = newSpill(dir, KeyFromEnv("SLSH_TEST_SNAPSHOT_KEY"), metrics); !assert.NoError(t, err) {
+		return
+	}
+
+	restored, err := s.restore()
+	assert.NoError(t, err)
+	assert.Empty(t, restored)
+
+	assert.NoError(t, s.snapshot(ShortMessage, LongMessage))
+	assert.Equal(t, int64(2), metrics.counter(MetricSnapshot))
+	assert.Zero(t, metrics.counter(MetricSpilled))
+
+	restored, err = s.restore()
+	if assert.NoError(t, err) && assert.Len(t, restored, 2) {
+		assert.Equal(t, ShortMessage.Contents, restored[0].Contents)
+		assert.Equal(t, LongMessage.Contents, restored[1].Contents)
+	}
+	assert.False(t, exists(filepath.Join(dir, snapshotFile)))
+}
@@ -0,0 +1,70 @@
+package slsh
+
+import (
+	"sort"
+	"strconv"
+)
+
+// 拆分日志的关联字段
+const (
+	SplitIDKey   = "split_id"   // 同一条日志拆分出的各部分共享的随机 ID
+	SplitPartKey = "split_part" // 当前部分序号, 格式: "<n>/<total>"
+)
+
+// Splitter 将字段过多的日志拆分为多条共享 split_id 的日志, 避免单条日志字段过多影响索引, 且不丢弃数据
+type Splitter struct {
+	MaxFields int         // 每条日志最多包含的字段数 (不含 Keep 与关联字段)
+	Keep      []string    // 在每个部分中都保留的字段, 例如 message, level
+	IDs       IDGenerator // split_id 生成器, 可选, 默认为 RandomID
+}
+
+func (s *Splitter) Split(message Message) []Message {
+	if s.MaxFields <= 0 || len(message.Contents) <= s.MaxFields+len(s.Keep) {
+		return []Message{message}
+	}
+
+	keep := make(map[string]string, len(s.Keep))
+	for _, k := range s.Keep {
+		if v, ok := message.Contents[k]; ok {
+			keep[k] = v
+		}
+	}
+	keys := make([]string, 0, len(message.Contents))
+	for k := range message.Contents {
+		if _, ok := keep[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) <= s.MaxFields {
+		return []Message{message}
+	}
+	sort.Strings(keys)
+
+	ids := s.IDs
+	if ids == nil {
+		ids = RandomID
+	}
+	id := ids.NewID()
+	total := (len(keys) + s.MaxFields - 1) / s.MaxFields
+	parts := make([]Message, 0, total)
+	for i := 0; i < len(keys); i += s.MaxFields {
+		end := i + s.MaxFields
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		contents := make(map[string]string, end-i+len(keep)+2)
+		for k, v := range keep {
+			contents[k] = v
+		}
+		for _, k := range keys[i:end] {
+			contents[k] = message.Contents[k]
+		}
+		contents[SplitIDKey] = id
+		contents[SplitPartKey] = strconv.Itoa(len(parts)+1) + "/" + strconv.Itoa(total)
+		part := message
+		part.Contents = contents
+		parts = append(parts, part)
+	}
+	return parts
+}
@@ -0,0 +1,67 @@
+package slsh
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitter(t *testing.T) {
+	newMessage := func(n int) Message {
+		contents := map[string]string{"message": "event", "level": "6"}
+		for i := 0; i < n; i++ {
+			contents["f"+strconv.Itoa(i)] = strconv.Itoa(i)
+		}
+		return Message{Contents: contents}
+	}
+	s := &Splitter{MaxFields: 4, Keep: []string{"message", "level"}}
+
+	t.Run("small", func(t *testing.T) {
+		parts := s.Split(newMessage(4))
+		assert.Len(t, parts, 1)
+		assert.NotContains(t, parts[0].Contents, SplitIDKey)
+	})
+
+	t.Run("split", func(t *testing.T) {
+		m := newMessage(10)
+		parts := s.Split(m)
+		if !assert.Len(t, parts, 3) {
+			return
+		}
+
+		merged := make(map[string]string)
+		for i, part := range parts {
+			assert.Equal(t, parts[0].Contents[SplitIDKey], part.Contents[SplitIDKey])
+			assert.Equal(t, strconv.Itoa(i+1)+"/3", part.Contents[SplitPartKey])
+			assert.Equal(t, "event", part.Contents["message"])
+			for k, v := range part.Contents {
+				if k != SplitIDKey && k != SplitPartKey {
+					merged[k] = v
+				}
+			}
+		}
+		assert.Equal(t, m.Contents, merged)
+		assert.Len(t, parts[2].Contents, 2+2+2)
+	})
+
+	t.Run("hook", func(t *testing.T) {
+		var pushed []Message
+		service := &MockService{
+			onPush:  func(ctx context.Context, message Message) error { pushed = append(pushed, message); return nil },
+			onStart: func() {},
+			onStop:  func(ctx context.Context) error { return nil },
+		}
+		hook := NewCustom(DefaultTimeout, logrus.AllLevels, &MockConverter{
+			onMessage: func(entry *logrus.Entry) Message { return newMessage(10) },
+		}, &MockWriter{}, service)
+		hook.splitter = s
+
+		logger := logrus.New()
+		logger.AddHook(hook)
+		logger.Info("event")
+		assert.Len(t, pushed, 3)
+	})
+}
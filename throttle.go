@@ -0,0 +1,72 @@
+package slsh
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 限流的配额类型
+const (
+	QuotaInflow = "inflow" // 项目写入流量
+	QuotaQPS    = "qps"    // 项目写入次数
+	QuotaShard  = "shard"  // 分片写入能力
+	QuotaWrite  = "write"  // 未能识别的写入配额
+)
+
+// 自适应限流调整的发送间隔范围
+const (
+	throttleMinGap = time.Second
+	throttleMaxGap = time.Minute
+)
+
+// throttleQuota 根据错误码与错误信息判断触发限流的配额类型, 非限流错误返回空
+func throttleQuota(code, message string) string {
+	switch code {
+	case "ShardWriteQuotaExceed":
+		return QuotaShard
+	case "WriteQuotaExceed", "ProjectQuotaExceed", "ExceedQuota":
+		message = strings.ToLower(message)
+		switch {
+		case strings.Contains(message, "inflow"):
+			return QuotaInflow
+		case strings.Contains(message, "qps"):
+			return QuotaQPS
+		}
+		return QuotaWrite
+	}
+	return ""
+}
+
+func throttled(err error) bool {
+	var aErr *AliyunError
+	if errors.As(err, &aErr) {
+		return aErr.Throttled() || aErr.HTTPCode == http.StatusTooManyRequests
+	}
+	var hErr *HTTPError
+	return errors.As(err, &hErr) && hErr.StatusCode == http.StatusTooManyRequests
+}
+
+// throttle 在持续限流时加大两次发送的最小间隔: 每次限流加倍 (从 throttleMinGap 开始, 不超过 throttleMaxGap),
+// 每次发送成功减半, 低于 throttleMinGap 后恢复为不限制
+type throttle struct {
+	gap time.Duration
+}
+
+// observe 根据发送结果调整并返回当前的额外间隔
+func (t *throttle) observe(err error) time.Duration {
+	switch {
+	case throttled(err):
+		if t.gap *= 2; t.gap < throttleMinGap {
+			t.gap = throttleMinGap
+		} else if t.gap > throttleMaxGap {
+			t.gap = throttleMaxGap
+		}
+	case err == nil:
+		if t.gap /= 2; t.gap < throttleMinGap {
+			t.gap = 0
+		}
+	}
+	return t.gap
+}
@@ -0,0 +1,59 @@
+package slsh
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottle(t *testing.T) {
+	t.Run("quota", func(t *testing.T) {
+		assert.Equal(t, QuotaShard, throttleQuota("ShardWriteQuotaExceed", "shard write quota exceed"))
+		assert.Equal(t, QuotaInflow, throttleQuota("WriteQuotaExceed", "Project write quota exceed: inflow: 100"))
+		assert.Equal(t, QuotaQPS, throttleQuota("WriteQuotaExceed", "Project write quota exceed: qps: 10"))
+		assert.Equal(t, QuotaWrite, throttleQuota("WriteQuotaExceed", ""))
+		assert.Equal(t, "", throttleQuota("Unauthorized", "inflow"))
+	})
+
+	t.Run("response", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errorCode":"WriteQuotaExceed","errorMessage":"Project write quota exceed: inflow: 100"}`))
+		}))
+		defer srv.Close()
+
+		u, _ := url.Parse(srv.URL)
+		w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient)
+		err := w.WriteMessage(ShortMessage)
+		var aErr *AliyunError
+		if assert.True(t, errors.As(err, &aErr)) {
+			assert.True(t, aErr.Throttled())
+			assert.Equal(t, QuotaInflow, aErr.Quota)
+		}
+		assert.True(t, throttled(err))
+		assert.True(t, retryable(err))
+	})
+
+	t.Run("adaptive", func(t *testing.T) {
+		quotaErr := &AliyunError{HTTPCode: http.StatusForbidden, Code: "WriteQuotaExceed", Quota: QuotaWrite}
+		var th throttle
+
+		assert.Equal(t, throttleMinGap, th.observe(quotaErr))
+		assert.Equal(t, 2*throttleMinGap, th.observe(quotaErr))
+		assert.Equal(t, 2*throttleMinGap, th.observe(errors.New("other")))
+		for i := 0; i < 10; i++ {
+			th.observe(&HTTPError{StatusCode: http.StatusTooManyRequests})
+		}
+		assert.Equal(t, throttleMaxGap, th.gap)
+
+		for th.gap > throttleMinGap {
+			th.observe(nil)
+		}
+		assert.Equal(t, time.Duration(0), th.observe(nil))
+	})
+}
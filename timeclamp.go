@@ -0,0 +1,31 @@
+package slsh
+
+import "time"
+
+// 被调整时间戳的日志中保存原始时间的字段
+const OriginalTimeKey = "original_time"
+
+// TimeClamp 将超出接收时间窗口的日志时间戳调整为窗口边界, 避免补发或回放的历史日志导致整批写入失败,
+// 原始时间以 RFC3339Nano 格式保存在 OriginalTimeKey 字段
+type TimeClamp struct {
+	Past   time.Duration // 允许早于当前时间的最大时长, 0 表示不限制
+	Future time.Duration // 允许晚于当前时间的最大时长, 0 表示不限制
+}
+
+func (c TimeClamp) enabled() bool { return c.Past > 0 || c.Future > 0 }
+
+// clamp 返回调整后的日志, 需要调整时复制 Contents, 不修改原日志
+func (c TimeClamp) clamp(now time.Time, message Message) Message {
+	t := message.Time
+	if c.Past > 0 && t.Before(now.Add(-c.Past)) {
+		t = now.Add(-c.Past)
+	} else if c.Future > 0 && t.After(now.Add(c.Future)) {
+		t = now.Add(c.Future)
+	} else {
+		return message
+	}
+
+	clamped := withContents(message, map[string]string{OriginalTimeKey: message.Time.Format(time.RFC3339Nano)})
+	clamped.Time = t
+	return clamped
+}
@@ -0,0 +1,38 @@
+package slsh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeClamp(t *testing.T) {
+	now := time.Date(2020, 1, 8, 0, 0, 0, 0, time.UTC)
+	c := TimeClamp{Past: 24 * time.Hour, Future: time.Minute}
+
+	t.Run("in window", func(t *testing.T) {
+		m := Message{Time: now.Add(-time.Hour), Contents: map[string]string{"k": "v"}}
+		assert.Equal(t, m, c.clamp(now, m))
+	})
+
+	t.Run("past", func(t *testing.T) {
+		m := Message{Time: now.Add(-48 * time.Hour), Contents: map[string]string{"k": "v"}}
+		clamped := c.clamp(now, m)
+		assert.Equal(t, now.Add(-24*time.Hour), clamped.Time)
+		assert.Equal(t, "2020-01-06T00:00:00Z", clamped.Contents[OriginalTimeKey])
+		assert.Equal(t, "v", clamped.Contents["k"])
+		assert.NotContains(t, m.Contents, OriginalTimeKey)
+	})
+
+	t.Run("future", func(t *testing.T) {
+		m := Message{Time: now.Add(time.Hour)}
+		assert.Equal(t, now.Add(time.Minute), c.clamp(now, m).Time)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		m := Message{Time: now.Add(-48 * time.Hour)}
+		assert.False(t, TimeClamp{}.enabled())
+		assert.Equal(t, m, TimeClamp{}.clamp(now, m))
+	})
+}
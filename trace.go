@@ -0,0 +1,61 @@
+package slsh
+
+import (
+	"context"
+	"errors"
+)
+
+// 发送链路的 span 名称
+const (
+	SpanWrite    = "slsh.write"    // 一次批量写入, 包含以下各阶段
+	SpanEncode   = "slsh.encode"   // protobuf 编码
+	SpanCompress = "slsh.compress" // lz4 压缩
+	SpanSign     = "slsh.sign"     // 构造请求并签名
+	SpanSend     = "slsh.send"     // 发送请求, 重试时每次发送一个 span
+)
+
+// span 属性名称
+const (
+	AttrBatchSize       = "slsh.batch.size"      // 日志条数
+	AttrRawBytes        = "slsh.batch.raw_bytes" // 编码后字节数
+	AttrCompressedBytes = "slsh.batch.bytes"     // 压缩后字节数
+	AttrAttempt         = "slsh.attempt"         // 第几次发送
+	AttrStatusCode      = "http.status_code"     // 响应状态码, 网络错误时为 0
+)
+
+// 链路追踪接口, 可对接 OpenTelemetry (参考 slshotel), 实现需并发安全
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End(err error)
+}
+
+type nopTracer struct{}
+
+func (nopTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(string, interface{}) {}
+func (nopSpan) End(error)                        {}
+
+// statusCode 从发送结果中提取响应状态码
+func statusCode(err error) int {
+	if err == nil {
+		return 200
+	}
+	var aErr *AliyunError
+	if errors.As(err, &aErr) {
+		return int(aErr.HTTPCode)
+	}
+	var hErr *HTTPError
+	if errors.As(err, &hErr) {
+		return hErr.StatusCode
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package slsh
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type MockSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *MockSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *MockSpan) End(err error)                              { s.err, s.ended = err, true }
+
+type MockTracer struct {
+	mu    sync.Mutex
+	spans []*MockSpan
+}
+
+func (t *MockTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &MockSpan{name: name, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func TestWriterTracer(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	tracer := &MockTracer{}
+	writer := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+		WithTracer(tracer), WithRetry(2, time.Millisecond))
+	assert.NoError(t, writer.WriteMessage(Messages...))
+
+	var names []string
+	for _, span := range tracer.spans {
+		names = append(names, span.name)
+		assert.True(t, span.ended, span.name)
+	}
+	assert.Equal(t, []string{SpanWrite, SpanEncode, SpanCompress, SpanSign, SpanSend, SpanSign, SpanSend}, names)
+
+	write := tracer.spans[0]
+	assert.Equal(t, len(Messages), write.attrs[AttrBatchSize])
+	assert.NotZero(t, write.attrs[AttrRawBytes])
+	assert.NoError(t, write.err)
+
+	first, second := tracer.spans[4], tracer.spans[6]
+	assert.Equal(t, http.StatusServiceUnavailable, first.attrs[AttrStatusCode])
+	assert.Error(t, first.err)
+	assert.Equal(t, 2, second.attrs[AttrAttempt])
+	assert.Equal(t, http.StatusOK, second.attrs[AttrStatusCode])
+}
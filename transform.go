@@ -0,0 +1,92 @@
+package slsh
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// 发送前处理日志的阶段, 返回 nil 时丢弃该日志
+type Transformer interface {
+	Transform(message *Message) *Message
+}
+
+type TransformerFunc func(*Message) *Message
+
+func (f TransformerFunc) Transform(message *Message) *Message { return f(message) }
+
+// 按顺序依次执行的 Transformer 组合, 任一阶段返回 nil 时停止
+type Transformers []Transformer
+
+func (ts Transformers) Transform(message *Message) *Message {
+	for _, t := range ts {
+		if message = t.Transform(message); message == nil {
+			return nil
+		}
+	}
+	return message
+}
+
+// ModifyContents 将 ContentModifier 适配为 Transformer
+func ModifyContents(modifier ContentModifier) Transformer {
+	return TransformerFunc(func(message *Message) *Message {
+		modifier.Modify(message.Contents)
+		return message
+	})
+}
+
+// RenameKeys 按映射重命名字段, 例如 {"msg": "message"}
+func RenameKeys(mapping map[string]string) Transformer {
+	return TransformerFunc(func(message *Message) *Message {
+		for from, to := range mapping {
+			if v, ok := message.Contents[from]; ok {
+				delete(message.Contents, from)
+				message.Contents[to] = v
+			}
+		}
+		return message
+	})
+}
+
+// 截断标记字段的后缀, 例如 message 字段被截断时附加 message_truncated=true 与 message_original_length=<原始字节数>
+const (
+	TruncatedSuffix      = "_truncated"
+	OriginalLengthSuffix = "_original_length"
+)
+
+// TruncateValues 将超过 max 字节的字段值截断, 不截断多字节字符
+func TruncateValues(max int) Transformer {
+	return truncateValues(max, false)
+}
+
+// TruncateValuesMarked 与 TruncateValues 相同, 并为被截断的字段附加截断标记与原始长度, 便于分析时识别不完整的数据
+func TruncateValuesMarked(max int) Transformer {
+	return truncateValues(max, true)
+}
+
+func truncateValues(max int, marked bool) Transformer {
+	return TransformerFunc(func(message *Message) *Message {
+		var truncated map[string]int
+		for k, v := range message.Contents {
+			if len(v) <= max {
+				continue
+			}
+			n := max
+			for n > 0 && !utf8.RuneStart(v[n]) {
+				n--
+			}
+			message.Contents[k] = v[:n]
+			if marked {
+				if truncated == nil {
+					truncated = make(map[string]int)
+				}
+				truncated[k] = len(v)
+			}
+		}
+		// 遍历结束后再附加标记, 标记字段本身不截断
+		for k, n := range truncated {
+			message.Contents[k+TruncatedSuffix] = "true"
+			message.Contents[k+OriginalLengthSuffix] = strconv.Itoa(n)
+		}
+		return message
+	})
+}
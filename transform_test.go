@@ -0,0 +1,73 @@
+package slsh
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransformers(t *testing.T) {
+	t.Run("chain", func(t *testing.T) {
+		chain := Transformers{
+			RenameKeys(map[string]string{"msg": "message"}),
+			ModifyContents(ContentModifierFunc(func(contents map[string]string) { contents["env"] = "prod" })),
+			TruncateValues(4),
+		}
+
+		m := chain.Transform(&Message{Contents: map[string]string{"msg": "hello", "k": "你好"}})
+		if assert.NotNil(t, m) {
+			assert.Equal(t, map[string]string{"message": "hell", "env": "prod", "k": "你"}, m.Contents)
+		}
+	})
+
+	t.Run("truncate marked", func(t *testing.T) {
+		m := TruncateValuesMarked(4).Transform(&Message{Contents: map[string]string{"message": "hello world", "k": "v"}})
+		assert.Equal(t, map[string]string{
+			"message":                        "hell",
+			"message" + TruncatedSuffix:      "true",
+			"message" + OriginalLengthSuffix: "11",
+			"k":                              "v",
+		}, m.Contents)
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		called := false
+		chain := Transformers{
+			TransformerFunc(func(message *Message) *Message { return nil }),
+			TransformerFunc(func(message *Message) *Message { called = true; return message }),
+		}
+		assert.Nil(t, chain.Transform(&Message{}))
+		assert.False(t, called)
+	})
+
+	t.Run("hook", func(t *testing.T) {
+		var pushed []Message
+		service := &MockService{
+			onPush:  func(ctx context.Context, message Message) error { pushed = append(pushed, message); return nil },
+			onStart: func() {},
+			onStop:  func(ctx context.Context) error { return nil },
+		}
+		converter := &MockConverter{onMessage: func(entry *logrus.Entry) Message {
+			return Message{Contents: map[string]string{"message": entry.Message}}
+		}}
+		hook := NewCustom(DefaultTimeout, logrus.AllLevels, converter, &MockWriter{}, service)
+		hook.Use(TransformerFunc(func(message *Message) *Message {
+			if message.Contents["message"] == "noise" {
+				return nil
+			}
+			return message
+		}))
+
+		logger := logrus.New()
+		logger.AddHook(hook)
+		logger.Info("noise")
+		logger.Info("signal")
+		assert.NoError(t, hook.Push(context.TODO(), Message{Contents: map[string]string{"message": "noise"}}))
+
+		if assert.Len(t, pushed, 1) {
+			assert.Equal(t, "signal", pushed[0].Contents["message"])
+		}
+	})
+}
@@ -3,6 +3,7 @@ package slsh
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -17,8 +18,12 @@ type AliyunError struct {
 	Code      string `json:"errorCode"`
 	Message   string `json:"errorMessage"`
 	RequestID string `json:"-"`
+	Quota     string `json:"-"` // 触发限流的配额类型, 参考 QuotaXXX, 非限流错误时为空
 }
 
+// Throttled 返回是否为写入配额限流错误
+func (a *AliyunError) Throttled() bool { return a.Quota != "" }
+
 func (a AliyunError) Error() string {
 	if data, err := json.Marshal(a); err != nil {
 		return err.Error()
@@ -27,21 +32,101 @@ func (a AliyunError) Error() string {
 	}
 }
 
+// 无法解析为阿里云错误格式的响应, 例如代理或网关返回的 HTML, 纯文本错误页
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	RequestID  string
+	Body       string // 响应内容片段, 超出读取上限的部分被截断
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("unexpected response %s: %q", e.Status, e.Body)
+}
+
+// 服务端签名校验失败, 通常由密钥错误或中间代理改写了参与签名的请求头 (Content-Type, Content-MD5, Date, x-log-*) 导致
+type SignatureError struct {
+	Err        *AliyunError
+	SignString string // 本地待签名字符串, STS token 已隐去, 可与服务端期望值通过 DiffSignString 比较
+	Proxied    bool   // 响应中存在 Via 头, 请求经过了代理
+}
+
+func (e *SignatureError) Error() string {
+	if e.Proxied {
+		return fmt.Sprintf("signature rejected, request passed through a proxy that may have modified signed headers "+
+			"(use https to avoid this): %v", e.Err)
+	}
+	return fmt.Sprintf("signature rejected: %v", e.Err)
+}
+
+func (e *SignatureError) Unwrap() error { return e.Err }
+
+// Message 为单条日志, 编码时 Contents 按键排序, 相同的 Message 总是得到逐字节相同的请求内容
 type Message struct {
 	Time     time.Time
 	Contents map[string]string
+	Topic    string `json:",omitempty"` // 日志组主题, 可选, 为空时使用 Writer 的主题, 不同主题的日志分别发送
+	Source   string `json:",omitempty"` // 日志组来源, 可选, 为空时使用 Writer 的来源, 不同来源的日志分别发送
+}
+
+// withContents 返回 m 的副本, Contents 为原字段加上 extra (同名时以 extra 为准), 其余字段保持不变, 不修改原日志
+func withContents(m Message, extra map[string]string) Message {
+	contents := make(map[string]string, len(m.Contents)+len(extra))
+	for k, v := range m.Contents {
+		contents[k] = v
+	}
+	for k, v := range extra {
+		contents[k] = v
+	}
+	m.Contents = contents
+	return m
 }
 
 type Writer interface {
 	WriteMessage(messages ...Message) error
 }
 
+// 支持 context 与单次请求参数的 Writer, NewWriter 返回的实现满足该接口, ctx 结束时中止请求并停止重试
+type OptionsWriter interface {
+	WriteMessageOptions(ctx context.Context, opts RequestOptions, messages ...Message) error
+}
+
 type Service interface {
 	Push(ctx context.Context, message Message) error
 	Start()
 	Stop(ctx context.Context) error
 }
 
+// 直接写入日志的接口, *Hook 实现了该接口, 供 HTTP, gRPC 等适配模块共用, 适配模块只依赖该接口与 Message
+type Pusher interface {
+	Push(ctx context.Context, message Message) error
+}
+
+type PriorityPusher interface {
+	PushPriority(ctx context.Context, message Message) error
+}
+
+// 支持非阻塞写入的 Service, 缓存已满时返回 ErrQueueFull
+type TryPusher interface {
+	TryPush(message Message) error
+}
+
+type Resetter interface {
+	Reset() error
+}
+
+type Warmer interface {
+	WarmUp(ctx context.Context) error
+}
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type Connector interface {
+	Connect(ctx context.Context) error
+}
+
 type Converter interface {
 	Message(entry *logrus.Entry) Message
 }
@@ -0,0 +1,9 @@
+package slsh
+
+// 当前版本, 发布时更新
+const Version = "0.1.0"
+
+// 附加在日志组上的客户端版本标签
+const VersionTagKey = "__client_version__"
+
+var hUserAgent = []string{"go-logrus-aliyun-log-hook/" + Version}
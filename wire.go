@@ -0,0 +1,126 @@
+package slsh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LogGroup 的 protobuf 编码, 字段定义见 api/sls.proto:
+//
+//	LogGroup.Logs     = 1 (bytes)
+//	Log.Time          = 1 (varint)
+//	Log.Contents      = 2 (bytes)
+//	Log_Content.Key   = 1 (bytes)
+//	Log_Content.Value = 2 (bytes)
+const (
+	tagGroupLogs   = 1<<3 | 2
+	tagLogTime     = 1<<3 | 0
+	tagLogContents = 2<<3 | 2
+	tagContentKey  = 1<<3 | 2
+	tagContentVal  = 2<<3 | 2
+)
+
+// 超过该容量的缓冲区不放回池中, 避免偶发的大批次长期占用内存
+const maxPooledBuffer = 1 << 20
+
+var rawPool = sync.Pool{New: func() interface{} { return new([]byte) }}
+
+// appendGroup 直接按 protobuf 编码格式将日志写入 b, 不构造中间的 api.Log 对象,
+// 并拼接预先编码的固定字段. 字段按编号升序写入, 与 proto.Marshal 的输出一致
+func (w *writer) appendGroup(b []byte, messages ...Message) []byte {
+	now := time.Now()
+	var keys []string
+	for _, message := range messages {
+		if w.fields.enabled() {
+			message = w.fields.limit(message)
+		}
+		if w.clamp.enabled() {
+			message = w.clamp.clamp(now, message)
+		}
+		b, keys = appendLog(b, keys, message)
+	}
+	return append(b, w.static...)
+}
+
+// appendLog 编码单条日志, Contents 按键排序, 相同的日志总是得到逐字节相同的编码,
+// keys 为复用的排序缓冲区
+func appendLog(b []byte, keys []string, message Message) ([]byte, []string) {
+	ts := uint64(wireTime(message.Time))
+
+	keys = keys[:0]
+	size := 1 + varintLen(ts)
+	for k, v := range message.Contents {
+		keys = append(keys, k)
+		n := contentLen(k, v)
+		size += 1 + varintLen(uint64(n)) + n
+	}
+	sort.Strings(keys)
+
+	b = append(b, tagGroupLogs)
+	b = appendVarint(b, uint64(size))
+	b = append(b, tagLogTime)
+	b = appendVarint(b, ts)
+	for _, k := range keys {
+		v := message.Contents[k]
+		b = append(b, tagLogContents)
+		b = appendVarint(b, uint64(contentLen(k, v)))
+		b = appendString(b, tagContentKey, k)
+		b = appendString(b, tagContentVal, v)
+	}
+	return b, keys
+}
+
+// HashMessages 返回日志稳定编码 (与请求中的 protobuf 编码一致, 时间精确到秒) 的 SHA-256 十六进制摘要,
+// 相同的日志总是得到相同的摘要, 可用作去重或幂等键
+func HashMessages(messages ...Message) string {
+	var b []byte
+	var keys []string
+	for _, message := range messages {
+		b, keys = appendLog(b, keys, message)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// wireTime 将时间转换为协议中的 uint32 秒数, 超出范围 (1970 年之前或 2106 年之后) 时取边界值而非回绕
+func wireTime(t time.Time) uint32 {
+	sec := t.Unix()
+	if sec < 0 {
+		return 0
+	}
+	if sec > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(sec)
+}
+
+func contentLen(k, v string) int {
+	return 1 + varintLen(uint64(len(k))) + len(k) + 1 + varintLen(uint64(len(v))) + len(v)
+}
+
+func appendString(b []byte, tag byte, s string) []byte {
+	b = append(b, tag)
+	b = appendVarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func varintLen(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
@@ -0,0 +1,50 @@
+//go:build slsh_generic
+// +build slsh_generic
+
+package slsh
+
+import (
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+)
+
+// encodeGeneric 使用 proto.Marshal 编码, 仅用于校验 appendGroup 的输出
+func (w *writer) encodeGeneric(messages ...Message) ([]byte, error) {
+	group := &api.LogGroup{
+		Topic:   &w.topic,
+		Source:  &w.source,
+		Logs:    make([]*api.Log, len(messages)),
+		LogTags: w.tags,
+	}
+
+	now := time.Now()
+	for i, message := range messages {
+		if w.fields.enabled() {
+			message = w.fields.limit(message)
+		}
+		if w.clamp.enabled() {
+			message = w.clamp.clamp(now, message)
+		}
+		keys := make([]string, 0, len(message.Contents))
+		for k := range message.Contents {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		contents := make([]*api.Log_Content, 0, len(keys))
+		for _, k := range keys {
+			contents = append(contents, &api.Log_Content{
+				Key:   proto.String(k),
+				Value: proto.String(message.Contents[k]),
+			})
+		}
+		group.Logs[i] = &api.Log{
+			Time:     proto.Uint32(wireTime(message.Time)),
+			Contents: contents,
+		}
+	}
+	return proto.Marshal(group)
+}
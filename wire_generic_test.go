@@ -0,0 +1,53 @@
+//go:build slsh_generic
+// +build slsh_generic
+
+package slsh
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+)
+
+// go test -tags slsh_generic -run TestWireGeneric
+func TestWireGeneric(t *testing.T) {
+	decode := func(t *testing.T, raw []byte) *api.LogGroup {
+		group := &api.LogGroup{}
+		assert.NoError(t, proto.Unmarshal(raw, group))
+		for _, log := range group.Logs {
+			sort.Slice(log.Contents, func(i, j int) bool { return log.Contents[i].GetKey() < log.Contents[j].GetKey() })
+		}
+		return group
+	}
+
+	r := rand.New(rand.NewSource(1))
+	randString := func() string { return strings.Repeat("x", r.Intn(300)) + string(rune(r.Intn(0x4e00))) }
+
+	w := NewWriter(&url.URL{}, DefaultTopic, DefaultSource, "any", Secret("any"), http.DefaultClient, WithVersionTag())
+	for round := 0; round < 100; round++ {
+		messages := make([]Message, 1+r.Intn(10))
+		for i := range messages {
+			contents := make(map[string]string)
+			for j := r.Intn(20); j > 0; j-- {
+				contents[randString()] = randString()
+			}
+			messages[i] = Message{Time: time.Unix(r.Int63n(1<<32), 0), Contents: contents}
+		}
+
+		raw, err := w.encode(messages...)
+		assert.NoError(t, err)
+		generic, err := w.encodeGeneric(messages...)
+		assert.NoError(t, err)
+		assert.True(t, proto.Equal(decode(t, generic), decode(t, raw)))
+		assert.Equal(t, generic, raw)
+	}
+}
@@ -0,0 +1,40 @@
+package slsh
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStableEncoding(t *testing.T) {
+	contents := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		contents["key"+strconv.Itoa(i)] = strconv.Itoa(i)
+	}
+	message := Message{Time: time.Unix(1577836800, 0), Contents: contents}
+
+	w := NewWriter(&url.URL{}, "topic", "source", "", nil, nil)
+	first, _ := w.encode(message)
+	for i := 0; i < 10; i++ {
+		raw, _ := w.encode(message)
+		assert.Equal(t, first, raw)
+	}
+
+	t.Run("hash", func(t *testing.T) {
+		copied := make(map[string]string)
+		for i := 49; i >= 0; i-- {
+			copied["key"+strconv.Itoa(i)] = strconv.Itoa(i)
+		}
+		hash := HashMessages(message)
+		assert.Len(t, hash, 64)
+		assert.Equal(t, hash, HashMessages(Message{Time: time.Unix(1577836800, 999), Contents: copied}))
+
+		copied["key0"] = "changed"
+		assert.NotEqual(t, hash, HashMessages(Message{Time: message.Time, Contents: copied}))
+		assert.NotEqual(t, hash, HashMessages(Message{Time: message.Time.Add(time.Second), Contents: contents}))
+		assert.NotEqual(t, hash, HashMessages(message, message))
+	})
+}
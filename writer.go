@@ -2,12 +2,12 @@ package slsh
 
 import (
 	"bytes"
-	"crypto/hmac"
+	"context"
 	"crypto/md5"
-	"crypto/sha1"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"sort"
@@ -16,109 +16,460 @@ import (
 	"time"
 
 	"github.com/golang/protobuf/proto"
-	"github.com/pierrec/lz4"
 
 	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/signer"
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/validator"
 )
 
 var (
 	hContentType     = []string{"application/x-protobuf"}
 	hApiVersion      = []string{"0.6.0"}
-	hCompressType    = []string{"lz4"}
 	hSignatureMethod = []string{"hmac-sha1"}
 )
 
-var loc = time.FixedZone("GMT", 0)
+const DefaultRetryBackoff = 100 * time.Millisecond
 
-func gmtNow() string { return time.Now().In(loc).Format(time.RFC1123) }
+const errSignatureNotMatch = "SignatureNotMatch"
+
+// 响应内容最多读取的字节数, 超出部分不再读取, 连接也不会被复用
+const DefaultMaxResponseBody int64 = 64 << 10
+
+var dates signer.DateCache
+
+func gmtNow() string { return dates.Format(time.Now()) }
 
 type writer struct {
-	client    *http.Client
-	method    string
-	appKey    string
-	appSecret Secret
-	uri       *url.URL
-	hHost     []string
-	topic     string
-	source    string
+	client      *http.Client
+	method      string
+	credentials CredentialsProvider
+	uri         *url.URL
+	hHost       []string
+	topic       string
+	source      string
+	inFlight    chan struct{}
+	metrics     Metrics
+	level       int
+	compressor  Compressor
+	skip        float64 // 跳过压缩的已压缩内容占比, 参考 WithCompressionSkip
+	signDebug   func(signString string)
+	slow        time.Duration // 慢日志阈值, 参考 WithSlowWrite
+	onSlow      func(SlowWrite)
+	maxBody     int64
+	clamp       TimeClamp
+	fields      fieldLimit // 字段数限制, 参考 WithMaxFields
+	maxAttempts int
+	backoff     time.Duration
+	maxElapsed  time.Duration // 单批次重试总时长, 参考 WithMaxRetryElapsed
+	tracer      Tracer
+	tags        []*api.LogTag
+	attemptTags bool        // 重试时附加尝试次数标签, 参考 WithAttemptTags
+	packs       *packIDs    // 日志组 __pack_id__ 标签, 参考 WithPackID
+	dict        *dictionary // 字典压缩, 参考 WithDictionary
+	dictAccept  string      // 声明支持的字典的响应头, 参考 WithDictionaryHeaders
+	dictHeader  string      // 标注所用字典的请求头
+	headers     http.Header
+	prefixes    []string // 参与签名的请求头前缀
+	static      []byte   // 预先编码的 Topic, Source 与 LogTags
+}
+
+// Writer 可选配置
+type WriterOption func(w *writer)
+
+// WithCredentialsProvider 使用凭证提供者代替固定密钥对
+func WithCredentialsProvider(provider CredentialsProvider) WriterOption {
+	return func(w *writer) { w.credentials = provider }
 }
 
-func NewWriter(uri *url.URL, topic, source, accessKey string, accessSecret Secret, client *http.Client) *writer {
-	return &writer{
-		client:    client,
-		method:    "POST",
-		uri:       uri,
-		hHost:     []string{uri.Host},
-		topic:     topic,
-		source:    source,
-		appKey:    accessKey,
-		appSecret: accessSecret,
+// WithMaxInFlight 限制同时进行中的请求数, n <= 0 时不限制
+func WithMaxInFlight(n int) WriterOption {
+	return func(w *writer) {
+		if n > 0 {
+			w.inFlight = make(chan struct{}, n)
+		}
 	}
 }
 
-func (w *writer) WriteMessage(messages ...Message) error {
-	if len(messages) == 0 {
-		return nil
+// WithCompressionLevel 设置 lz4 压缩级别, 以 CPU 换取更高压缩率, 参考 CompressionXXX 预设
+func WithCompressionLevel(level int) WriterOption {
+	return func(w *writer) { w.level = level }
+}
+
+// WithCompressor 使用自定义压缩实现, 设置后忽略 WithCompressionLevel
+func WithCompressor(compressor Compressor) WriterOption {
+	return func(w *writer) { w.compressor = compressor }
+}
+
+// WithSignatureDebug 在每次签名后回调待签名字符串 (不含密钥, STS token 已隐去), 用于排查签名不匹配问题, 参考 DiffSignString
+func WithSignatureDebug(fn func(signString string)) WriterOption {
+	return func(w *writer) { w.signDebug = fn }
+}
+
+// WithMaxResponseBody 设置响应内容最多读取的字节数, n <= 0 时使用 DefaultMaxResponseBody
+func WithMaxResponseBody(n int64) WriterOption {
+	return func(w *writer) {
+		if n > 0 {
+			w.maxBody = n
+		}
 	}
+}
 
-	raw, err := w.encode(messages...)
-	if err != nil {
-		return err
+// WithRetry 发送失败且可重试 (网络错误, 限流, 服务端错误) 时复用已编码压缩的内容重新发送,
+// 每次重试前等待 backoff 并加倍, maxAttempts <= 1 时不重试
+func WithRetry(maxAttempts int, backoff time.Duration) WriterOption {
+	return func(w *writer) {
+		if maxAttempts > 1 {
+			w.maxAttempts, w.backoff = maxAttempts, validator.CoalesceDur(backoff, DefaultRetryBackoff)
+		}
+	}
+}
+
+// WithTimeClamp 发送前将超出时间窗口的日志时间戳调整为窗口边界
+func WithTimeClamp(clamp TimeClamp) WriterOption {
+	return func(w *writer) { w.clamp = clamp }
+}
+
+// WithVersionTag 在日志组中附加 __client_version__ 标签, 便于从日志中统计各服务使用的版本
+func WithVersionTag() WriterOption {
+	return func(w *writer) {
+		w.tags = append(w.tags, &api.LogTag{Key: proto.String(VersionTagKey), Value: proto.String(Version)})
+	}
+}
+
+// 重试成功的日志组附加的标签
+const (
+	AttemptTagKey    = "__attempt__"        // 第几次发送
+	RetryDelayTagKey = "__retry_delay_ms__" // 距首次发送的毫秒数
+)
+
+// WithAttemptTags 重试时在日志组中附加 AttemptTagKey 与 RetryDelayTagKey 标签, 可从写入的日志本身分析发送链路的健康状况,
+// 首次发送不附加 (即未附加标签的日志组一次发送成功). 附加标签需重新压缩载荷, 仅在重试时产生开销,
+// 不适用于 SendPayload 发送的预先编码的载荷
+func WithAttemptTags() WriterOption {
+	return func(w *writer) { w.attemptTags = true }
+}
+
+// WithLogTags 在日志组中附加标签 (按键排序), 每个日志组只发送一次而非每条日志重复发送,
+// 适合主机名, IP 等对所有日志相同的元数据, 在 SLS 中以 __tag__:<key> 字段查询
+func WithLogTags(tags map[string]string) WriterOption {
+	return func(w *writer) {
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			w.tags = append(w.tags, &api.LogTag{Key: proto.String(k), Value: proto.String(tags[k])})
+		}
 	}
+}
+
+// WithExtraHeaders 在每个请求中附加自定义请求头, 例如私有网关所需的租户或路由信息.
+// 匹配签名前缀 (默认 X-Log-, X-Acs-) 的请求头会参与签名, 与内置请求头同名时以内置为准
+func WithExtraHeaders(headers map[string]string) WriterOption {
+	return func(w *writer) {
+		if len(headers) == 0 {
+			return
+		}
+		w.headers = make(http.Header, len(headers))
+		for k, v := range headers {
+			w.headers[http.CanonicalHeaderKey(k)] = []string{v}
+		}
+	}
+}
+
+// WithSignHeaderPrefixes 替换参与签名的请求头前缀, 用于规范化规则不同的 SLS 兼容网关,
+// 默认为 X-Log- 与 X-Acs-, 如需保留需一并传入
+func WithSignHeaderPrefixes(prefixes ...string) WriterOption {
+	return func(w *writer) {
+		if len(prefixes) == 0 {
+			return
+		}
+		w.prefixes = make([]string, len(prefixes))
+		for i, prefix := range prefixes {
+			w.prefixes[i] = http.CanonicalHeaderKey(prefix)
+		}
+	}
+}
 
-	data, err := w.compress(raw)
+// WithTracer 为编码, 压缩, 签名, 发送各阶段创建 span
+func WithTracer(tracer Tracer) WriterOption {
+	return func(w *writer) {
+		if tracer != nil {
+			w.tracer = tracer
+		}
+	}
+}
+
+// WithMetrics 设置指标上报
+func WithMetrics(metrics Metrics) WriterOption {
+	return func(w *writer) { w.metrics = metrics }
+}
+
+func NewWriter(uri *url.URL, topic, source, accessKey string, accessSecret Secret, client *http.Client,
+	opts ...WriterOption) *writer {
+	w := &writer{
+		client:      client,
+		method:      "POST",
+		credentials: StaticCredentials(accessKey, accessSecret),
+		uri:         uri,
+		hHost:       []string{uri.Host},
+		topic:       topic,
+		source:      source,
+		metrics:     nopMetrics{},
+		maxBody:     DefaultMaxResponseBody,
+		maxAttempts: 1,
+		tracer:      nopTracer{},
+		prefixes:    signer.DefaultPrefixes,
+		dictAccept:  DefaultAcceptDictHeader,
+		dictHeader:  DefaultCompressDictHeader,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.compressor == nil {
+		w.compressor = lz4Compressor{level: w.level}
+	}
+	w.static, _ = proto.Marshal(&api.LogGroup{Topic: &w.topic, Source: &w.source, LogTags: w.tags})
+	return w
+}
+
+// Reset 关闭空闲连接, 使后续请求重新解析接入点, 并刷新凭证
+func (w *writer) Reset() error {
+	w.client.CloseIdleConnections()
+	if r, ok := w.credentials.(Refresher); ok {
+		return r.Refresh()
+	}
+	return nil
+}
+
+// WarmUp 向接入点发送 HEAD 请求, 建立连接与 TLS 会话并放回连接池供后续请求复用,
+// 响应状态码不作检查
+func (w *writer) WarmUp(ctx context.Context) error {
+	u := *w.uri
+	u.Path, u.RawQuery = "/", ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
 	if err != nil {
 		return err
 	}
 
-	req, err := w.buildRequest(raw, data)
+	resp, err := w.client.Do(req)
 	if err != nil {
 		return err
 	}
+	closeBody(resp.Body, w.maxBody)
+	return nil
+}
 
-	return w.fire(req)
+func (w *writer) WriteMessage(messages ...Message) error {
+	return w.WriteMessageOptions(context.Background(), RequestOptions{}, messages...)
 }
 
-func (w *writer) encode(messages ...Message) ([]byte, error) {
-	group := &api.LogGroup{
-		Topic:  &w.topic,
-		Source: &w.source,
-		Logs:   make([]*api.Log, len(messages)),
+// WriteMessageOptions 与 WriteMessage 相同, 可指定单次请求的可选参数, ctx 结束时停止重试
+func (w *writer) WriteMessageOptions(ctx context.Context, opts RequestOptions, messages ...Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	if w.hasGroups(messages) {
+		return w.writeTopics(ctx, opts, messages)
 	}
+	return w.writeGroup(ctx, opts, messages, func(b []byte) []byte { return w.appendGroup(b, messages...) })
+}
 
-	for i, message := range messages {
-		contents := make([]*api.Log_Content, 0, len(message.Contents))
-		for k, v := range message.Contents {
-			contents = append(contents, &api.Log_Content{
-				Key:   proto.String(k),
-				Value: proto.String(v),
-			})
+// writeGroup 编码, 压缩并发送一个日志组, encode 将日志组编码追加到复用的缓冲区
+func (w *writer) writeGroup(ctx context.Context, opts RequestOptions, messages []Message, encode func([]byte) []byte) (err error) {
+	start := time.Now()
+	timing := SlowWrite{Messages: len(messages)}
+	defer func() { w.reportSlow(&timing, start, err) }()
+
+	ctx, span := w.tracer.StartSpan(ctx, SpanWrite)
+	span.SetAttribute(AttrBatchSize, len(messages))
+	defer func() { span.End(err) }()
+
+	_, encodeSpan := w.tracer.StartSpan(ctx, SpanEncode)
+	buf := rawPool.Get().(*[]byte)
+	raw := encode((*buf)[:0])
+	if w.packs != nil {
+		raw = append(raw, w.packs.next()...)
+	}
+	defer func() {
+		if cap(raw) <= maxPooledBuffer && !underMemoryPressure() {
+			*buf = raw
+			rawPool.Put(buf)
 		}
-		group.Logs[i] = &api.Log{
-			Time:     proto.Uint32(uint32(message.Time.Unix())),
-			Contents: contents,
+	}()
+	encodeSpan.End(nil)
+	span.SetAttribute(AttrRawBytes, len(raw))
+	timing.Encode = time.Since(start)
+
+	st := time.Now()
+	_, compressSpan := w.tracer.StartSpan(ctx, SpanCompress)
+	data, rawSize, compressType, dictID, err := w.compressBatch(raw, messages)
+	compressSpan.End(err)
+	if err != nil {
+		return err
+	}
+	p := newPayload(data, rawSize, compressType, dictID)
+	span.SetAttribute(AttrCompressedBytes, len(p.data))
+	timing.Compress = time.Since(st)
+	timing.RawBytes, timing.CompressedBytes = rawSize, len(data)
+
+	var retag func(int, time.Duration) (payload, error)
+	if w.attemptTags {
+		retag = func(attempt int, delay time.Duration) (payload, error) {
+			tagged := append(raw[:len(raw):len(raw)], attemptTags(attempt, delay)...)
+			data, rawSize, compressType, dictID, err := w.compressBatch(tagged, messages)
+			if err != nil {
+				return payload{}, err
+			}
+			return newPayload(data, rawSize, compressType, dictID), nil
 		}
 	}
-	return proto.Marshal(group)
+	return w.send(ctx, p, opts, &timing, retag)
+}
+
+// attemptTags 返回重试标签的编码, LogTags 为 repeated 字段, 可直接追加到已编码的日志组之后
+func attemptTags(attempt int, delay time.Duration) []byte {
+	b, _ := proto.Marshal(&api.LogGroup{LogTags: []*api.LogTag{
+		{Key: proto.String(AttemptTagKey), Value: proto.String(strconv.Itoa(attempt))},
+		{Key: proto.String(RetryDelayTagKey), Value: proto.String(strconv.FormatInt(int64(delay/time.Millisecond), 10))},
+	}})
+	return b
+}
+
+// Send 发送 EncodeBatch 生成的载荷, 与 WriteMessage 相同按配置重试
+func (w *writer) Send(p Payload) error { return w.SendPayload(context.Background(), p) }
+
+// SendPayload 发送 EncodeBatch 生成的载荷并按配置重试, ctx 结束时停止重试
+func (w *writer) SendPayload(ctx context.Context, p Payload) (err error) {
+	start := time.Now()
+	timing := SlowWrite{RawBytes: p.RawSize, CompressedBytes: len(p.Data)}
+	defer func() { w.reportSlow(&timing, start, err) }()
+
+	ctx, span := w.tracer.StartSpan(ctx, SpanWrite)
+	span.SetAttribute(AttrCompressedBytes, len(p.Data))
+	defer func() { span.End(err) }()
+
+	return w.send(ctx, p.payload(), RequestOptions{}, &timing, nil)
 }
 
-func (w *writer) compress(data []byte) ([]byte, error) {
-	out := make([]byte, lz4.CompressBlockBound(len(data)))
-	var hashTable [1 << 16]int
-	n, err := lz4.CompressBlock(data, out, hashTable[:])
+// SignPayload 返回已签名的 PutLogs 请求, 由其他组件 (或序列化后由其他进程) 执行, 实现编码与传输分离.
+// 签名包含 Date 请求头, 服务端只接受 15 分钟内签名的请求, 请求不经过重试与并发限制
+func (w *writer) SignPayload(ctx context.Context, p Payload) (*http.Request, error) {
+	req, err := w.buildRequest(p.payload(), RequestOptions{}, nil)
 	if err != nil {
 		return nil, err
 	}
-	if n == 0 {
-		if n, err = copyIncompressible(data, out); err != nil {
-			return nil, err
+	return req.WithContext(ctx), nil
+}
+
+// send 发送载荷并按配置重试, 各阶段耗时累加到 timing, retag 不为空时用于生成重试时附加标签的载荷
+func (w *writer) send(ctx context.Context, p payload, opts RequestOptions, timing *SlowWrite,
+	retag func(attempt int, delay time.Duration) (payload, error)) error {
+	backoff := w.backoff
+	var cache signCache
+	first := time.Now()
+	for attempt := 1; ; attempt++ {
+		timing.Attempts = attempt
+		if attempt > 1 && retag != nil {
+			tagged, err := retag(attempt, time.Since(first))
+			if err != nil {
+				return err
+			}
+			// 载荷变化后 Content-MD5 与原始大小随之变化, 不能复用签名缓存
+			p, cache = tagged, signCache{}
+		}
+		st := time.Now()
+		_, signSpan := w.tracer.StartSpan(ctx, SpanSign)
+		req, err := w.buildRequest(p, opts, &cache)
+		signSpan.End(err)
+		if err != nil {
+			return err
+		}
+		req = req.WithContext(ctx)
+		timing.Sign += time.Since(st)
+
+		st = time.Now()
+		_, sendSpan := w.tracer.StartSpan(ctx, SpanSend)
+		sendSpan.SetAttribute(AttrAttempt, attempt)
+		err = w.fire(req)
+		sendSpan.SetAttribute(AttrStatusCode, statusCode(err))
+		sendSpan.End(err)
+		timing.Network += time.Since(st)
+		if err == nil || attempt >= w.maxAttempts || !retryable(err) {
+			return err
+		}
+		if w.retryExpired(first, backoff) {
+			w.metrics.Count(MetricRetryElapsed, 1)
+			return &RetryElapsedError{Attempts: attempt, Elapsed: time.Since(first), Err: err}
 		}
+
+		w.metrics.Count(MetricRetried, 1)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// 已编码压缩的批次, 重试时复用, 仅重新生成 Date 与签名
+type payload struct {
+	data         []byte
+	rawSize      string
+	md5          string
+	compressType []string
+	dict         []string
+}
+
+func newPayload(data []byte, rawSize int, compressType, dictID string) payload {
+	p := payload{
+		data:    data,
+		rawSize: strconv.Itoa(rawSize),
+		md5:     fmt.Sprintf("%X", md5.Sum(data)),
+	}
+	if compressType != "" {
+		p.compressType = []string{compressType}
 	}
-	return out[:n], nil
+	if dictID != "" {
+		p.dict = []string{dictID}
+	}
+	return p
+}
+
+func (w *writer) encode(messages ...Message) ([]byte, error) {
+	return w.appendGroup(nil, messages...), nil
 }
 
-func (w *writer) buildRequest(raw, data []byte) (*http.Request, error) {
-	req, err := http.NewRequest(w.method, w.uri.String(), bytes.NewReader(data))
+func (w *writer) compress(raw []byte) (payload, error) {
+	data, rawSize, compressType, dictID, err := w.compressBatch(raw, nil)
+	if err != nil {
+		return payload{}, err
+	}
+	return newPayload(data, rawSize, compressType, dictID), nil
+}
+
+// signCache 在同一载荷的多次尝试间复用待签名字符串中与 Date 无关的部分, STS token 变化时重新计算
+type signCache struct {
+	token     string
+	presigned *signer.Presigned
+}
+
+// buildRequest 创建已签名的请求, cache 可为空
+func (w *writer) buildRequest(p payload, opts RequestOptions, cache *signCache) (*http.Request, error) {
+	data := p.data
+	req, err := http.NewRequest(w.method, opts.url(w.uri), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(data)), nil }
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	creds, err := w.credentials.Credentials()
 	if err != nil {
 		return nil, err
 	}
@@ -126,120 +477,194 @@ func (w *writer) buildRequest(raw, data []byte) (*http.Request, error) {
 	req.Header = http.Header{
 		"Content-Type":          hContentType,
 		"Content-Length":        []string{strconv.Itoa(len(data))},
-		"Content-Md5":           []string{fmt.Sprintf("%X", md5.Sum(data))},
+		"Content-Md5":           []string{p.md5},
 		"Date":                  []string{gmtNow()},
 		"Host":                  w.hHost,
+		"User-Agent":            hUserAgent,
 		"X-Log-Apiversion":      hApiVersion,
-		"X-Log-Bodyrawsize":     []string{strconv.Itoa(len(raw))},
-		"X-Log-Compresstype":    hCompressType,
+		"X-Log-Bodyrawsize":     []string{p.rawSize},
 		"X-Log-Signaturemethod": hSignatureMethod,
 	}
+	if p.compressType != nil {
+		req.Header["X-Log-Compresstype"] = p.compressType
+	}
+	if p.dict != nil {
+		req.Header[w.dictHeader] = p.dict
+	}
+	for k, v := range opts.Headers {
+		if k = http.CanonicalHeaderKey(k); req.Header[k] == nil {
+			req.Header[k] = []string{v}
+		}
+	}
 
-	sign, err := signature(w.appSecret, req)
-	if err != nil {
+	if err := w.signRequest(req, creds, cache); err != nil {
 		return nil, err
 	}
-
-	req.Header["Authorization"] = []string{fmt.Sprintf("LOG %s:%s", w.appKey, sign)}
 	return req, nil
 }
 
-func (w *writer) fire(req *http.Request) error {
-	resp, err := w.client.Do(req)
+// signRequest 补充自定义请求头与 STS token 后计算签名, cache 可为空
+func (w *writer) signRequest(req *http.Request, creds Credentials, cache *signCache) error {
+	for k, v := range w.headers {
+		if _, ok := req.Header[k]; !ok {
+			req.Header[k] = v
+		}
+	}
+
+	if creds.SecurityToken != "" {
+		req.Header["X-Acs-Security-Token"] = []string{creds.SecurityToken}
+	}
+
+	var digest string
+	var err error
+	if cache == nil {
+		signStr := signer.StringToSign(req, w.prefixes)
+		if w.signDebug != nil {
+			w.signDebug(redactSignString(signStr))
+		}
+		digest, err = signer.Sign(creds.AccessSecret, signStr)
+	} else {
+		if cache.presigned == nil || cache.token != creds.SecurityToken {
+			cache.token, cache.presigned = creds.SecurityToken, signer.NewPresigned(req, w.prefixes)
+		}
+		date := req.Header.Get("Date")
+		if w.signDebug != nil {
+			w.signDebug(redactSignString(cache.presigned.StringToSign(date)))
+		}
+		digest, err = cache.presigned.Sign(creds.AccessSecret, date)
+	}
 	if err != nil {
 		return err
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	return w.validateResponse(resp)
+	req.Header["Authorization"] = []string{fmt.Sprintf("LOG %s:%s", creds.AccessKey, digest)}
+	return nil
 }
 
-func (w writer) validateResponse(resp *http.Response) error {
-	if resp.StatusCode < http.StatusBadRequest {
-		return nil
-	}
-	aErr := AliyunError{
-		HTTPCode:  int32(resp.StatusCode),
-		RequestID: resp.Header.Get("X-Log-Requestid"),
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&aErr); err != nil {
+// Ping 发送签名的 GET 请求查询日志库信息, 同时验证网络, 凭证与日志库是否可用, 适合用于健康检查
+func (w *writer) Ping(ctx context.Context) error {
+	resp, err := w.get(ctx, "", nil, nil)
+	if err != nil {
 		return err
 	}
-	return &aErr
+	defer closeBody(resp.Body, w.maxBody)
+	w.dict.observe(resp.Header.Get(w.dictAccept))
+
+	return w.validateResponse(resp)
 }
 
-func signature(secret Secret, req *http.Request) (string, error) {
-	arr := make([]string, 0, 10)
-	arr = append(arr,
-		req.Method,
-		req.Header.Get("Content-MD5"),
-		req.Header.Get("Content-Type"),
-		req.Header.Get("Date"),
-	)
+// get 向日志库下的 sub 路径发送签名的 GET 请求, sub 为空时请求日志库本身, 调用方负责关闭响应
+func (w *writer) get(ctx context.Context, sub string, query url.Values, header http.Header) (*http.Response, error) {
+	return w.request(ctx, http.MethodGet, w.storePath()+sub, query, header, nil)
+}
 
-	// Calc CanonicalizedSLSHeaders
-	sections := make([]string, 0, 4)
-	for k, v := range req.Header {
-		if len(v) > 0 && (strings.HasPrefix(k, "X-Log-") || strings.HasPrefix(k, "X-Acs-")) {
-			str := fmt.Sprintf("%s:%s", strings.ToLower(k), strings.TrimSpace(strings.Join(v, ",")))
-			sections = append(sections, str)
-		}
+// postJSON 向日志库下的 sub 路径发送签名的 POST 请求, 请求内容为 JSON 编码的 body, 调用方负责关闭响应
+func (w *writer) postJSON(ctx context.Context, sub string, query url.Values, body interface{}) (*http.Response, error) {
+	return w.requestJSON(ctx, http.MethodPost, w.storePath()+sub, query, body)
+}
+
+// requestJSON 向项目下的 path 发送签名的请求, 请求内容为 JSON 编码的 body, 调用方负责关闭响应
+func (w *writer) requestJSON(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
 	}
-	sort.Strings(sections)
-	arr = append(arr, sections...)
+	return w.request(ctx, method, path, query, http.Header{
+		"Content-Type": {"application/json"},
+		"Content-Md5":  {fmt.Sprintf("%X", md5.Sum(data))},
+	}, data)
+}
 
-	// Calc CanonicalizedResource
-	canoResource := req.URL.EscapedPath()
+// storePath 返回日志库的路径, 例如 /logstores/<store>
+func (w *writer) storePath() string { return strings.TrimSuffix(w.uri.Path, "/shards/lb") }
 
-	// TODO: Enable GET support if necessary
-	// if req.URL.RawQuery != "" {
-	//	values := req.URL.Query()
-	//	queries := make([]string, 0, len(values))
-	//	for k, v := range values {
-	//		queries = append(queries, fmt.Sprintf("%s=%s", k, v))
-	//	}
-	//	sort.Strings(queries)
-	//
-	//	canoResource = fmt.Sprintf("%s?%s", canoResource, strings.Join(queries, "&"))
-	// }
+func (w *writer) request(ctx context.Context, method, path string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *w.uri
+	u.Path = path
+	u.RawQuery = query.Encode()
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
 
-	arr = append(arr, canoResource)
+	creds, err := w.credentials.Credentials()
+	if err != nil {
+		return nil, err
+	}
 
-	signStr := strings.Join(arr, "\n")
+	req.Header = http.Header{
+		"Date":                  []string{gmtNow()},
+		"Host":                  w.hHost,
+		"User-Agent":            hUserAgent,
+		"X-Log-Apiversion":      hApiVersion,
+		"X-Log-Bodyrawsize":     []string{strconv.Itoa(len(body))},
+		"X-Log-Signaturemethod": hSignatureMethod,
+	}
+	if body != nil {
+		req.Header["Content-Length"] = []string{strconv.Itoa(len(body))}
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := w.signRequest(req, creds, nil); err != nil {
+		return nil, err
+	}
 
-	// Signature = base64(hmac-sha1(UTF8-Encoding-Of(SignString)，AccessKeySecret))
-	mac := hmac.New(sha1.New, secret)
-	if _, err := mac.Write([]byte(signStr)); err != nil {
-		return "", err
+	return w.client.Do(req)
+}
+
+func (w *writer) fire(req *http.Request) error {
+	if w.inFlight != nil {
+		st := time.Now()
+		w.inFlight <- struct{}{}
+		defer func() { <-w.inFlight }()
+		w.metrics.Observe(MetricInFlightWait, time.Since(st).Seconds())
 	}
 
-	digest := base64.StdEncoding.EncodeToString(mac.Sum(nil))
-	return digest, nil
+	resp, err := w.client.Do(req)
+	if err != nil {
+		kind := classifyDelivery(err)
+		w.metrics.Count(MetricDeliveryErrorPrefix+string(kind), 1)
+		return &DeliveryError{Kind: kind, Err: err}
+	}
+	defer closeBody(resp.Body, w.maxBody)
+	w.dict.observe(resp.Header.Get(w.dictAccept))
+
+	return w.validateResponse(resp)
 }
 
-func copyIncompressible(src, dst []byte) (int, error) {
-	lLen, dn := len(src), len(dst)
+func (w writer) validateResponse(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	requestID := resp.Header.Get("X-Log-Requestid")
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, w.maxBody))
+	if err != nil {
+		return err
+	}
 
-	di := 0
-	if lLen < 0xF {
-		dst[di] = byte(lLen << 4)
-	} else {
-		dst[di] = 0xF0
-		if di++; di == dn {
-			return di, nil
-		}
-		lLen -= 0xF
-		for ; lLen >= 0xFF; lLen -= 0xFF {
-			dst[di] = 0xFF
-			if di++; di == dn {
-				return di, nil
-			}
-		}
-		dst[di] = byte(lLen)
+	aErr := AliyunError{HTTPCode: int32(resp.StatusCode), RequestID: requestID}
+	if err := json.Unmarshal(body, &aErr); err != nil || aErr.Code == "" {
+		return &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, RequestID: requestID, Body: string(body)}
 	}
-	if di++; di+len(src) > dn {
-		return di, nil
+	aErr.Quota = throttleQuota(aErr.Code, aErr.Message)
+	if aErr.Code == errSignatureNotMatch && resp.Request != nil {
+		w.metrics.Count(MetricSignatureMismatch, 1)
+		return &SignatureError{
+			Err:        &aErr,
+			SignString: redactSignString(signer.StringToSign(resp.Request, w.prefixes)),
+			Proxied:    resp.Header.Get("Via") != "",
+		}
 	}
-	di += copy(dst[di:], src)
-	return di, nil
+	return &aErr
+}
+
+// closeBody 读尽剩余响应内容 (最多 limit 字节) 后关闭, 以便复用 keep-alive 连接
+func closeBody(body io.ReadCloser, limit int64) {
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(body, limit))
+	_ = body.Close()
 }
@@ -1,19 +1,28 @@
 package slsh
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
-	sls "github.com/aliyun/aliyun-log-go-sdk"
 	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/kyochou/go-logrus-aliyun-log-hook/api"
+	"github.com/kyochou/go-logrus-aliyun-log-hook/internal/signer"
 )
 
 // {"errorCode":"ParameterInvalid","errorMessage":"http extend authorization : LOG :WL2xp3EYvKpsIGgwE3s5HHK7M/c= pair is invalid"}
@@ -28,11 +37,11 @@ const (
 var (
 	DefaultAccessSecret = Secret("321")
 	ShortMessage        = Message{
-		Time:     time.Date(2020, 1, 1, 0, 0, 0, 0, loc),
+		Time:     time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
 		Contents: map[string]string{"key": "value"},
 	}
 	LongMessage = Message{
-		Time: time.Date(2020, 1, 1, 0, 0, 0, 0, loc),
+		Time: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
 		Contents: map[string]string{
 			strings.Repeat("key1", 10): strings.Repeat("value2", 20),
 			strings.Repeat("key2", 10): strings.Repeat("value2", 20),
@@ -114,116 +123,641 @@ func TestWriter(t *testing.T) {
 			assert.JSONEq(t, DefaultErrorMessage, aErr.Error())
 		}
 	})
+
+	t.Run("non-json error", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("X-Log-Requestid", "456")
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte("<html><body>502 Bad Gateway</body></html>" + strings.Repeat(" ", 2*int(DefaultMaxResponseBody))))
+		}))
+		defer srv.Close()
+
+		writer := newWriter(t, srv.URL)
+		err := writer.WriteMessage(ShortMessage)
+
+		var hErr *HTTPError
+		if assert.True(t, errors.As(err, &hErr)) {
+			assert.Equal(t, http.StatusBadGateway, hErr.StatusCode)
+			assert.Equal(t, "456", hErr.RequestID)
+			assert.True(t, strings.HasPrefix(hErr.Body, "<html><body>502 Bad Gateway"))
+			assert.Len(t, hErr.Body, int(DefaultMaxResponseBody))
+			assert.True(t, retryable(err))
+		}
+	})
+}
+
+func TestWriterSignatureError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Via", "1.1 corporate-proxy")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errorCode":"SignatureNotMatch","errorMessage":"signature not match"}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	metrics := &MockMetrics{}
+	creds := Credentials{AccessKey: DefaultAccessKey, AccessSecret: DefaultAccessSecret, SecurityToken: "sts-token"}
+	writer := NewWriter(u, DefaultTopic, DefaultSource, "", nil, http.DefaultClient,
+		WithMetrics(metrics), WithCredentialsProvider(staticCredentials(creds)))
+
+	err := writer.WriteMessage(ShortMessage)
+	var sErr *SignatureError
+	if assert.True(t, errors.As(err, &sErr)) {
+		assert.True(t, sErr.Proxied)
+		assert.Contains(t, sErr.SignString, "x-log-apiversion:0.6.0")
+		assert.Contains(t, sErr.SignString, "x-acs-security-token:REDACTED")
+		assert.NotContains(t, sErr.SignString, "sts-token")
+		assert.Contains(t, err.Error(), "proxy")
+	}
+	var aErr *AliyunError
+	if assert.True(t, errors.As(err, &aErr)) {
+		assert.Equal(t, "SignatureNotMatch", aErr.Code)
+	}
+	assert.Equal(t, int64(1), metrics.counter(MetricSignatureMismatch))
+}
+
+func TestWriterResponseBody(t *testing.T) {
+	t.Run("connection reuse", func(t *testing.T) {
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = w.Write([]byte(strings.Repeat("x", 1024)))
+		}))
+		var conns int32
+		srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				atomic.AddInt32(&conns, 1)
+			}
+		}
+		srv.Start()
+		defer srv.Close()
+
+		u, _ := url.Parse(srv.URL)
+		writer := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret,
+			&http.Client{Transport: &http.Transport{}})
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, writer.WriteMessage(ShortMessage))
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(strings.Repeat("x", 1024)))
+		}))
+		defer srv.Close()
+
+		u, _ := url.Parse(srv.URL)
+		writer := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+			WithMaxResponseBody(16))
+
+		var hErr *HTTPError
+		if assert.True(t, errors.As(writer.WriteMessage(ShortMessage), &hErr)) {
+			assert.Equal(t, strings.Repeat("x", 16), hErr.Body)
+		}
+	})
+}
+
+func TestWriterWarmUp(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	writer := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, srv.Client())
+
+	assert.NoError(t, writer.WarmUp(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+
+	assert.NoError(t, writer.WriteMessage(ShortMessage))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+}
+
+func TestWriterRetry(t *testing.T) {
+	var bodies []string
+	var md5s []string
+	status := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(data))
+		md5s = append(md5s, req.Header.Get("Content-Md5"))
+		w.WriteHeader(status[len(bodies)-1])
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	metrics := &MockMetrics{}
+
+	t.Run("retryable", func(t *testing.T) {
+		writer := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+			WithRetry(3, time.Millisecond), WithMetrics(metrics))
+
+		assert.NoError(t, writer.WriteMessage(Messages...))
+		if assert.Len(t, bodies, 3) {
+			assert.Equal(t, bodies[0], bodies[2])
+			assert.Equal(t, md5s[0], md5s[2])
+		}
+		assert.Equal(t, int64(2), metrics.counter(MetricRetried))
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		bodies = nil
+		status = []int{http.StatusUnauthorized, http.StatusOK}
+		writer := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+			WithRetry(3, time.Millisecond))
+
+		assert.Error(t, writer.WriteMessage(ShortMessage))
+		assert.Len(t, bodies, 1)
+	})
+}
+
+func TestWriterAttemptTags(t *testing.T) {
+	var groups []*api.LogGroup
+	var signed []bool
+	status := []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, _ := ioutil.ReadAll(req.Body)
+		raw, err := uncompressLZ4(data, req.Header.Get("X-Log-Bodyrawsize"))
+		assert.NoError(t, err)
+		group := &api.LogGroup{}
+		assert.NoError(t, proto.Unmarshal(raw, group))
+		groups = append(groups, group)
+		sig, err := signature(DefaultAccessSecret, req)
+		signed = append(signed, err == nil && req.Header.Get("Authorization") == "LOG "+DefaultAccessKey+":"+sig)
+		w.WriteHeader(status[len(groups)-1])
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL + "/logstores/test-logstore/shards/lb")
+
+	writer := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+		WithRetry(3, time.Millisecond), WithVersionTag(), WithAttemptTags())
+	assert.NoError(t, writer.WriteMessage(Messages...))
+
+	tags := func(group *api.LogGroup) map[string]string {
+		m := make(map[string]string)
+		for _, tag := range group.LogTags {
+			m[tag.GetKey()] = tag.GetValue()
+		}
+		return m
+	}
+	if assert.Len(t, groups, 3) {
+		assert.Equal(t, []bool{true, true, true}, signed)
+		assert.Equal(t, map[string]string{VersionTagKey: Version}, tags(groups[0]))
+		for i, group := range groups[1:] {
+			assert.Equal(t, DefaultTopic, group.GetTopic())
+			assert.Len(t, group.Logs, len(Messages))
+			tags := tags(group)
+			assert.Equal(t, Version, tags[VersionTagKey])
+			assert.Equal(t, strconv.Itoa(i+2), tags[AttemptTagKey])
+			assert.Contains(t, tags, RetryDelayTagKey)
+		}
+	}
+}
+
+func TestWriterCredentials(t *testing.T) {
+	provider := &MockCredentials{creds: Credentials{AccessKey: "key", AccessSecret: Secret("secret"), SecurityToken: "token"}}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"), "LOG key:"))
+		assert.Equal(t, "token", req.Header.Get("X-Acs-Security-Token"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	writer := NewWriter(u, DefaultTopic, DefaultSource, "", nil, http.DefaultClient,
+		WithCredentialsProvider(provider))
+
+	assert.NoError(t, writer.WriteMessage(ShortMessage))
+	assert.NoError(t, writer.Reset())
+	assert.Equal(t, 1, provider.refreshed)
+}
+
+func TestWriterMaxInFlight(t *testing.T) {
+	const maxInFlight = 2
+
+	var current, peak int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&current, 1)
+		defer atomic.AddInt32(&current, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	metrics := &MockMetrics{}
+	u, _ := url.Parse(srv.URL)
+	writer := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+		WithMaxInFlight(maxInFlight), WithMetrics(metrics))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4*maxInFlight; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, writer.WriteMessage(ShortMessage))
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, atomic.LoadInt32(&peak) <= maxInFlight)
+	assert.Len(t, metrics.observed(MetricInFlightWait), 4*maxInFlight)
+}
+
+func TestWriterEncode(t *testing.T) {
+	w := NewWriter(&url.URL{}, DefaultTopic, DefaultSource, "any", Secret("any"), http.DefaultClient)
+
+	raw, err := w.encode(Messages...)
+	if assert.NoError(t, err) {
+		group := &api.LogGroup{}
+		assert.NoError(t, proto.Unmarshal(raw, group))
+		assert.Equal(t, DefaultTopic, group.GetTopic())
+		assert.Equal(t, DefaultSource, group.GetSource())
+		if assert.Len(t, group.Logs, len(Messages)) {
+			assert.Equal(t, uint32(ShortMessage.Time.Unix()), group.Logs[0].GetTime())
+			assert.Equal(t, "key", group.Logs[0].Contents[0].GetKey())
+			assert.Equal(t, "value", group.Logs[0].Contents[0].GetValue())
+			assert.Len(t, group.Logs[1].Contents, len(LongMessage.Contents))
+		}
+	}
+}
+
+func TestWireTime(t *testing.T) {
+	assert.Equal(t, uint32(1577836800), wireTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, uint32(0), wireTime(time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, uint32(math.MaxUint32), wireTime(time.Date(2106, 2, 7, 6, 28, 15, 0, time.UTC)))
+	assert.Equal(t, uint32(math.MaxUint32), wireTime(time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestWriterRequestBody(t *testing.T) {
+	w := NewWriter(&url.URL{}, DefaultTopic, DefaultSource, "any", Secret("any"), http.DefaultClient)
+	data := []byte("compressed")
+
+	req, err := w.buildRequest(newPayload(data, 3, CompressTypeLZ4, ""), RequestOptions{}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(len(data)), req.ContentLength)
+
+	body, err := ioutil.ReadAll(req.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, data, body)
+	assert.NoError(t, req.Body.Close())
+	assert.NoError(t, req.Body.Close())
+
+	for i := 0; i < 2; i++ {
+		rc, err := req.GetBody()
+		if assert.NoError(t, err) {
+			body, err := ioutil.ReadAll(rc)
+			assert.NoError(t, err)
+			assert.Equal(t, data, body)
+			assert.NoError(t, rc.Close())
+		}
+	}
+}
+
+func TestWriterVersion(t *testing.T) {
+	w := NewWriter(&url.URL{}, "any", "any", "any", Secret("any"), http.DefaultClient, WithVersionTag())
+
+	raw, err := w.encode(ShortMessage)
+	if assert.NoError(t, err) {
+		group := &api.LogGroup{}
+		assert.NoError(t, proto.Unmarshal(raw, group))
+		if assert.Len(t, group.LogTags, 1) {
+			assert.Equal(t, VersionTagKey, group.LogTags[0].GetKey())
+			assert.Equal(t, Version, group.LogTags[0].GetValue())
+		}
+	}
+
+	req, err := w.buildRequest(newPayload(raw, len(raw), CompressTypeLZ4, ""), RequestOptions{}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "go-logrus-aliyun-log-hook/"+Version, req.Header.Get("User-Agent"))
+	}
 }
 
-func TestSignature(t *testing.T) {
-	uri := "http://test-project.regionid.example.com/logstores/test-logstore"
-	req, err := http.NewRequest("POST", uri, nil)
+type identityCompressor struct{}
+
+func (identityCompressor) Compress(src []byte) ([]byte, int, string, error) {
+	return src, len(src), "identity", nil
+}
+
+func TestWriterCompressor(t *testing.T) {
+	var raw []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "identity", req.Header.Get("X-Log-Compresstype"))
+		assert.Equal(t, req.Header.Get("X-Log-Bodyrawsize"), req.Header.Get("Content-Length"))
+		raw, _ = ioutil.ReadAll(req.Body)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+		WithCompressor(identityCompressor{}))
+	if assert.NoError(t, w.WriteMessage(ShortMessage)) {
+		group := &api.LogGroup{}
+		assert.NoError(t, proto.Unmarshal(raw, group))
+		assert.Equal(t, DefaultTopic, group.GetTopic())
+	}
+}
+
+func TestWriterExtraHeaders(t *testing.T) {
+	u, _ := url.Parse("http://test-project.regionid.example.com/logstores/test-logstore/shards/lb")
+	w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+		WithExtraHeaders(map[string]string{
+			"x-log-tenant":     "t1",
+			"X-Route":          "cn-east",
+			"X-Log-Apiversion": "9.9.9",
+		}))
+
+	raw, err := w.encode(ShortMessage)
 	if !assert.NoError(t, err) {
 		return
 	}
+	req, err := w.buildRequest(newPayload(raw, len(raw), CompressTypeLZ4, ""), RequestOptions{}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "t1", req.Header.Get("X-Log-Tenant"))
+	assert.Equal(t, "cn-east", req.Header.Get("X-Route"))
+	assert.Equal(t, "0.6.0", req.Header.Get("X-Log-Apiversion"))
+
+	signStr := signer.StringToSign(req, w.prefixes)
+	assert.Contains(t, signStr, "x-log-tenant:t1")
+	assert.NotContains(t, signStr, "cn-east")
 
-	req.Header = http.Header{
-		"Date":                  []string{"Mon, 09 Nov 2015 06:03:03 GMT"},
-		"Host":                  []string{"test-project.regionid.example.com"},
-		"X-Log-Apiversion":      []string{"0.6.0"},
-		"X-Log-Signaturemethod": []string{"hmac-sha1"},
-		"Content-Md5":           []string{"1DD45FA4A70A9300CC9FE7305AF2C494"},
-		"Content-Length":        []string{"52"},
-		"X-Log-Bodyrawsize":     []string{"50"},
-		"X-Log-Compresstype":    []string{"lz4"},
+	sig, err := signature(DefaultAccessSecret, req)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "LOG "+DefaultAccessKey+":"+sig, req.Header.Get("Authorization"))
 	}
+}
+
+func TestWriterSignHeaderPrefixes(t *testing.T) {
+	u, _ := url.Parse("http://gateway.example.com/logstores/test-logstore/shards/lb")
+	w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient,
+		WithSignHeaderPrefixes("x-gw-", "X-Log-"),
+		WithExtraHeaders(map[string]string{"X-Gw-Tenant": "t1", "X-Acs-Region": "cn"}))
 
-	sig, err := signature(Secret("321"), req)
+	raw, err := w.encode(ShortMessage)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var signStr string
+	w.signDebug = func(s string) { signStr = s }
+	_, err = w.buildRequest(newPayload(raw, len(raw), CompressTypeLZ4, ""), RequestOptions{}, nil)
 	if assert.NoError(t, err) {
-		assert.Equal(t, "v/969+iSsYwGFtAXAy1xaK9rNDI=", sig)
+		assert.Contains(t, signStr, "x-gw-tenant:t1")
+		assert.Contains(t, signStr, "x-log-apiversion:0.6.0")
+		assert.NotContains(t, signStr, "x-acs-region")
 	}
 }
 
-func BenchmarkWriter(b *testing.B) {
-	startServer := func(b *testing.B) *httptest.Server {
-		handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			b.StopTimer()
-			defer b.StartTimer()
+func TestWriterPing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.MethodGet, req.Method)
+		assert.Equal(t, "/logstores/test-logstore", req.URL.Path)
+
+		sig, err := signature(DefaultAccessSecret, req)
+		if assert.NoError(t, err) && req.Header.Get("Authorization") != "LOG "+DefaultAccessKey+":"+sig {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"errorCode":"SignatureNotMatch","errorMessage":"signature not match"}`))
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL + "/logstores/test-logstore/shards/lb")
+	w := NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, DefaultAccessSecret, http.DefaultClient)
+	assert.NoError(t, w.Ping(context.TODO()))
+
+	w = NewWriter(u, DefaultTopic, DefaultSource, DefaultAccessKey, Secret("wrong"), http.DefaultClient)
+	var sErr *SignatureError
+	assert.True(t, errors.As(w.Ping(context.TODO()), &sErr))
+}
 
-			switch req.Method {
-			case "GET":
-				if strings.Contains(req.URL.Path, "logstores") {
-					_, _ = w.Write([]byte(`{"logstoreName":""}`))
-				} else {
-					_, _ = w.Write([]byte(`{"projectName":"", "region":""}`))
+type rotatingCredentials struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *rotatingCredentials) Credentials() (Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	return Credentials{AccessKey: DefaultAccessKey, AccessSecret: DefaultAccessSecret, SecurityToken: strconv.Itoa(c.calls / 2)}, nil
+}
+
+func TestWriterRetrySignature(t *testing.T) {
+	var tokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tokens = append(tokens, req.Header.Get("X-Acs-Security-Token"))
+		sig, err := signature(DefaultAccessSecret, req)
+		if !assert.NoError(t, err) || req.Header.Get("Authorization") != "LOG "+DefaultAccessKey+":"+sig {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"errorCode":"SignatureNotMatch","errorMessage":"signature not match"}`))
+			return
+		}
+		if len(tokens) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	// 重试间隔跨越秒边界, 且 STS token 在重试间变化, 每次尝试的签名均需有效
+	u, _ := url.Parse(srv.URL + "/logstores/test-logstore/shards/lb")
+	w := NewWriter(u, DefaultTopic, DefaultSource, "", nil, http.DefaultClient,
+		WithCredentialsProvider(&rotatingCredentials{}), WithRetry(3, 300*time.Millisecond))
+	assert.NoError(t, w.WriteMessage(ShortMessage))
+	assert.Equal(t, []string{"0", "1", "1"}, tokens)
+}
+
+// signature 使用默认的签名请求头前缀计算请求签名
+func signature(secret Secret, req *http.Request) (string, error) {
+	return signer.Sign(secret, signer.StringToSign(req, signer.DefaultPrefixes))
+}
+
+func BenchmarkEncode(b *testing.B) {
+	w := NewWriter(&url.URL{}, DefaultTopic, DefaultSource, "any", Secret("any"), http.DefaultClient)
+	messages := []Message{ShortMessage}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := w.encode(messages...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestWriterLogTags(t *testing.T) {
+	w := NewWriter(&url.URL{}, "any", "any", "any", Secret("any"), http.DefaultClient,
+		WithLogTags(map[string]string{"host": "h1", "ip": "10.0.0.1"}))
+
+	raw, err := w.encode(ShortMessage, ShortMessage)
+	if assert.NoError(t, err) {
+		group := &api.LogGroup{}
+		assert.NoError(t, proto.Unmarshal(raw, group))
+		assert.Len(t, group.Logs, 2)
+		if assert.Len(t, group.LogTags, 2) {
+			assert.Equal(t, "host", group.LogTags[0].GetKey())
+			assert.Equal(t, "h1", group.LogTags[0].GetValue())
+			assert.Equal(t, "ip", group.LogTags[1].GetKey())
+			assert.Equal(t, "10.0.0.1", group.LogTags[1].GetValue())
+		}
+	}
+}
+
+// BenchmarkLogTags 比较主机元数据作为每条日志的字段与作为日志组标签发送时的载荷大小
+func BenchmarkLogTags(b *testing.B) {
+	meta := map[string]string{
+		"host":    "web-7f9c4d8b6-x2k4p",
+		"ip":      "10.24.113.57",
+		"region":  "cn-hangzhou",
+		"service": "order-api",
+		"version": "v1.42.3",
+	}
+	messages := func(extra map[string]string) []Message {
+		messages := make([]Message, 100)
+		for i := range messages {
+			contents := map[string]string{
+				"message": fmt.Sprintf("request %d handled", i),
+				"level":   "6",
+				"path":    "/api/v1/orders/" + strconv.Itoa(i%7),
+				"status":  "200",
+			}
+			for k, v := range extra {
+				contents[k] = v
+			}
+			messages[i] = Message{Time: time.Now(), Contents: contents}
+		}
+		return messages
+	}
+
+	cases := []struct {
+		name     string
+		messages []Message
+		opts     []WriterOption
+	}{
+		{"fields", messages(meta), nil},
+		{"tags", messages(nil), []WriterOption{WithLogTags(meta)}},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			w := NewWriter(&url.URL{}, "any", "any", "any", Secret("any"), http.DefaultClient, c.opts...)
+
+			var raw []byte
+			var p payload
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var err error
+				if raw, err = w.encode(c.messages...); err != nil {
+					b.Fatal(err)
+				}
+				if p, err = w.compress(raw); err != nil {
+					b.Fatal(err)
 				}
-			case "POST":
-				w.WriteHeader(http.StatusOK)
 			}
+			b.ReportMetric(float64(len(raw)), "raw-bytes/batch")
+			b.ReportMetric(float64(len(p.data)), "bytes/batch")
 		})
-		return httptest.NewServer(handler)
 	}
+}
 
-	b.Run("hook", func(b *testing.B) {
-		srv := startServer(b)
-		defer srv.Close()
-
-		msg := Message{
+func BenchmarkCompress(b *testing.B) {
+	messages := make([]Message, 100)
+	for i := range messages {
+		messages[i] = Message{
 			Time: time.Now(),
 			Contents: map[string]string{
-				"aaaaaaaaaaaa": "bbbbbbbbbbbb",
-				"cccccccccccc": "dddddddddddd",
+				"message": fmt.Sprintf("request %d handled", i),
+				"level":   "6",
+				"path":    "/api/v1/users/" + strconv.Itoa(i%7),
+				"status":  "200",
 			},
 		}
+	}
 
-		uri, _ := url.Parse(srv.URL)
-		writer := NewWriter(uri, "any", "any", "any", Secret("any"), http.DefaultClient)
+	presets := []struct {
+		name  string
+		level int
+	}{
+		{"fastest", CompressionFastest},
+		{"balanced", CompressionBalanced},
+		{"best", CompressionBest},
+	}
 
-		b.ResetTimer()
-		for i := 0; i < b.N; i++ {
-			if err := writer.WriteMessage(msg); err != nil {
+	for _, preset := range presets {
+		b.Run(preset.name, func(b *testing.B) {
+			w := NewWriter(&url.URL{}, "any", "any", "any", Secret("any"), http.DefaultClient,
+				WithCompressionLevel(preset.level))
+
+			raw, err := w.encode(messages...)
+			if err != nil {
 				b.Fatal(err)
 			}
-		}
-	})
 
-	b.Run("sls", func(b *testing.B) {
-		srv := startServer(b)
-		defer srv.Close()
+			var p payload
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if p, err = w.compress(raw); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(raw))/float64(len(p.data)), "ratio")
+		})
+	}
+}
 
-		group := &sls.LogGroup{
-			Source: proto.String("any"),
-			Topic:  proto.String("any"),
-			Logs: []*sls.Log{{
-				Time: proto.Uint32(uint32(time.Now().Unix())),
-				Contents: []*sls.LogContent{{
-					Key:   proto.String("aaaaaaaaaaaa"),
-					Value: proto.String("bbbbbbbbbbbb"),
-				}, {
-					Key:   proto.String("cccccccccccc"),
-					Value: proto.String("dddddddddddd"),
-				}},
-			}},
-		}
-
-		client := &sls.Client{
-			Endpoint:        srv.URL,
-			AccessKeyID:     "any",
-			AccessKeySecret: "any",
-		}
-		defer func() { _ = client.Close() }()
-
-		project, err := client.GetProject("any")
-		if err != nil {
-			b.Fatal(err)
-		}
+type MockCredentials struct {
+	creds     Credentials
+	refreshed int
+}
 
-		store, err := project.GetLogStore("any")
-		if err != nil {
-			b.Fatal(err)
-		}
+func (m *MockCredentials) Credentials() (Credentials, error) { return m.creds, nil }
+func (m *MockCredentials) Refresh() error                    { m.refreshed++; return nil }
 
-		b.ResetTimer()
-		for i := 0; i < b.N; i++ {
-			if err := store.PutLogs(group); err != nil {
-				b.Fatal(err)
-			}
-		}
-	})
+type MockMetrics struct {
+	mu           sync.Mutex
+	counters     map[string]int64
+	observations map[string][]float64
+}
+
+func (m *MockMetrics) Count(name string, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counters == nil {
+		m.counters = make(map[string]int64)
+	}
+	m.counters[name] += delta
+}
+
+func (m *MockMetrics) Observe(name string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.observations == nil {
+		m.observations = make(map[string][]float64)
+	}
+	m.observations[name] = append(m.observations[name], value)
+}
+
+func (m *MockMetrics) counter(name string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name]
+}
+
+func (m *MockMetrics) observed(name string) []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.observations[name]
 }